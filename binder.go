@@ -0,0 +1,554 @@
+package zxgo
+
+/*
+BindByMap 是 ModifyByMap 的完整版, 规则如下:
+1. key默认是字段名, 可以用tag修改: `zx:"name"`, `zx:"-"`表示跳过该字段.
+2. 嵌套的结构体用"."连接: key为"Db.Port"的值写入 data.Db.Port; 匿名(嵌入)的结构体不加前缀.
+3. 指针字段(包括匿名的结构体指针)在找到对应的key时才分配内存.
+4. slice和array的值用Separator(默认",")分隔: "1,2,3"; 来自表单(多个值)时每个值是一个元素.
+5. map的值形如"k1:v1,k2:v2", 也可以用"Field.k1"这样的key逐个指定(UpperKey时map的key也保持原样).
+6. 支持 time.Time(见DefaultTimeLayouts), time.Duration(如"1m30s"), encoding.TextUnmarshaler.
+7. 不会panic, 所有失败的字段汇总在 *BindError 里面返回, 成功的字段照常写入.
+*/
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultTagName string = "zx"
+
+// time.Time字段依次尝试这些格式(按time.Local解析, 除非字符串里带有时区).
+var DefaultTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.000000", "2006-01-02 15:04:05", "2006-01-02"}
+
+type BindOptions struct {
+	TagName     string   //为空时使用DefaultTagName, 为"-"时忽略tag只使用字段名.
+	UpperKey    bool     //和ModifyByMap的upperKey一样, 比较key时不区分大小写.
+	Separator   string   //为空时使用",".
+	KVSeparator string   //map中key和value的分隔符, 为空时使用":".
+	TimeLayouts []string //为空时使用DefaultTimeLayouts.
+}
+
+type FieldError struct {
+	Path  string //字段的路径, 比如"Db.Port", "Tags[1]"
+	Key   string
+	Value string
+	Err   error
+}
+
+func (self *FieldError) Error() string {
+	return fmt.Sprintf("%v(key=%v,value=%v): %v", self.Path, self.Key, self.Value, self.Err)
+}
+
+type BindError struct {
+	Errors []*FieldError
+}
+
+func (self *BindError) Error() string {
+	messages := make([]string, 0, len(self.Errors))
+	for _, fieldErr := range self.Errors {
+		messages = append(messages, fieldErr.Error())
+	}
+	return fmt.Sprintf("bind fail (%v fields): %v", len(self.Errors), strings.Join(messages, "; "))
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// 用kvs修改data(必须是结构体指针)中的各个字段, opts为nil时使用默认值.
+func BindByMap(data interface{}, kvs map[string]string, opts *BindOptions) error {
+	values := make(map[string][]string, len(kvs))
+	for k, v := range kvs {
+		values[k] = []string{v}
+	}
+	return BindByValues(data, values, opts)
+}
+
+// 和BindByMap一样, 但是一个key可以有多个值(比如url.Values表单数据), 非slice字段使用最后一个值.
+func BindByValues(data interface{}, values map[string][]string, opts *BindOptions) error {
+	elem := reflect.ValueOf(data)
+	if elem.Kind() != reflect.Ptr || elem.IsNil() || elem.Elem().Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf("data must be a non-nil pointer to struct, type=%T", data))
+	}
+
+	b := newBinder(values, opts)
+	b.bindStruct(elem.Elem(), "", "")
+	return b.result()
+}
+
+// 把QueryData的结果绑定到slicePtr(指向[]T或者[]*T, T是结构体), 列名作为key.
+func BindQueryData(slicePtr interface{}, results map[int]map[string]string, opts *BindOptions) error {
+	slice := reflect.ValueOf(slicePtr)
+	if slice.Kind() != reflect.Ptr || slice.IsNil() || slice.Elem().Kind() != reflect.Slice {
+		return errors.New(fmt.Sprintf("slicePtr must be a pointer to slice, type=%T", slicePtr))
+	}
+	slice = slice.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf("slice element must be a struct, type=%T", slicePtr))
+	}
+
+	allErrors := make([]*FieldError, 0)
+	for i := 0; i < len(results); i++ {
+		values := make(map[string][]string, len(results[i]))
+		for k, v := range results[i] {
+			values[k] = []string{v}
+		}
+		item := reflect.New(elemType)
+		b := newBinder(values, opts)
+		b.bindStruct(item.Elem(), "", "["+strconv.Itoa(i)+"].")
+		allErrors = append(allErrors, b.errors...)
+		if isPtr {
+			slice.Set(reflect.Append(slice, item))
+		} else {
+			slice.Set(reflect.Append(slice, item.Elem()))
+		}
+	}
+
+	if len(allErrors) != 0 {
+		return &BindError{allErrors}
+	}
+	return nil
+}
+
+type binder struct {
+	values   map[string][]string //key已经按UpperKey处理过了
+	keys     map[string]string   //values的key => 原来的key
+	opts     BindOptions
+	errors   []*FieldError
+	visiting map[bindVisit]bool //正在绑定的结构体. type A struct{ *A } 里面的A的字段被外层遮住了, 不再分配和绑定
+}
+
+type bindVisit struct {
+	t         reflect.Type
+	keyPrefix string
+}
+
+func newBinder(values map[string][]string, opts *BindOptions) *binder {
+	b := &binder{errors: make([]*FieldError, 0), visiting: make(map[bindVisit]bool)}
+	if opts != nil {
+		b.opts = *opts
+	}
+	if len(b.opts.TagName) == 0 {
+		b.opts.TagName = DefaultTagName
+	}
+	if len(b.opts.Separator) == 0 {
+		b.opts.Separator = ","
+	}
+	if len(b.opts.KVSeparator) == 0 {
+		b.opts.KVSeparator = ":"
+	}
+	if len(b.opts.TimeLayouts) == 0 {
+		b.opts.TimeLayouts = DefaultTimeLayouts
+	}
+
+	b.values = make(map[string][]string, len(values))
+	b.keys = make(map[string]string, len(values))
+	for k, v := range values {
+		b.values[b.fold(k)] = v
+		b.keys[b.fold(k)] = k
+	}
+	return b
+}
+
+func (self *binder) result() error {
+	if len(self.errors) != 0 {
+		return &BindError{self.errors}
+	}
+	return nil
+}
+
+func (self *binder) fold(key string) string {
+	if self.opts.UpperKey {
+		return strings.ToUpper(key)
+	}
+	return key
+}
+
+func (self *binder) addError(path, key string, values []string, err error) {
+	self.errors = append(self.errors, &FieldError{Path: path, Key: key, Value: strings.Join(values, self.opts.Separator), Err: err})
+}
+
+// 解析字段的tag, 返回key的名字和其余的选项(比如omitempty).
+func parseFieldTag(field reflect.StructField, tagName string) (name string, options []string, skip bool) {
+	if tagName == "-" {
+		return field.Name, nil, false
+	}
+	tag := field.Tag.Get(tagName)
+	if tag == "-" {
+		return "", nil, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	options = parts[1:]
+	if len(name) == 0 {
+		name = field.Name
+	}
+	return
+}
+
+// 不需要展开成"A.B"形式的结构体类型(它们从一个字符串解析).
+func isScalarStruct(t reflect.Type) bool {
+	return t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func (self *binder) bindStruct(elem reflect.Value, keyPrefix, pathPrefix string) {
+	elemType := elem.Type()
+	visit := bindVisit{t: elemType, keyPrefix: keyPrefix}
+	self.visiting[visit] = true
+	defer delete(self.visiting, visit)
+
+	for i := 0; i < elem.NumField(); i++ {
+		structField := elemType.Field(i)
+		field := elem.Field(i)
+
+		name, _, skip := parseFieldTag(structField, self.opts.TagName)
+		if skip {
+			continue
+		}
+
+		fieldType := structField.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		//匿名的结构体(没有用tag指定名字时)不加前缀, 它的字段就像是外层结构体的字段.
+		if structField.Anonymous && fieldType.Kind() == reflect.Struct && !isScalarStruct(fieldType) && structField.Tag.Get(self.opts.TagName) == "" {
+			if field.Kind() == reflect.Ptr {
+				if !field.CanSet() || self.visiting[bindVisit{t: fieldType, keyPrefix: keyPrefix}] || !self.hasFieldKey(fieldType, keyPrefix, map[reflect.Type]bool{}) {
+					continue
+				}
+				if field.IsNil() {
+					field.Set(reflect.New(fieldType))
+				}
+				field = field.Elem()
+			}
+			self.bindStruct(field, keyPrefix, pathPrefix)
+			continue
+		}
+
+		if !field.CanSet() { //一般情况下,变量首字母是小写的,不可Set.
+			continue
+		}
+		self.bindField(field, keyPrefix+name, pathPrefix+structField.Name)
+	}
+}
+
+type FieldKey struct {
+	Key   string //BindByMap使用的key, 比如"Db.Port"
+	Path  string //字段的路径, 比如"Db.Port"
+	Field reflect.StructField
+}
+
+// 列出BindByMap能够写入的所有key(按字段定义的顺序), 嵌套的结构体会展开, tagName为空时使用DefaultTagName.
+func FieldKeys(data interface{}, tagName string) []*FieldKey {
+	if len(tagName) == 0 {
+		tagName = DefaultTagName
+	}
+	t := reflect.TypeOf(data)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	keys := make([]*FieldKey, 0)
+	if t.Kind() == reflect.Struct {
+		collectFieldKeys(t, tagName, "", "", &keys, map[reflect.Type]bool{})
+	}
+	return keys
+}
+
+func collectFieldKeys(t reflect.Type, tagName, keyPrefix, pathPrefix string, keys *[]*FieldKey, visiting map[reflect.Type]bool) {
+	if visiting[t] { //A.Next *A 这样的递归类型, 只展开一层.
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		name, _, skip := parseFieldTag(structField, tagName)
+		if skip {
+			continue
+		}
+		fieldType := structField.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		isNested := fieldType.Kind() == reflect.Struct && !isScalarStruct(fieldType)
+		if structField.Anonymous && isNested && structField.Tag.Get(tagName) == "" {
+			collectFieldKeys(fieldType, tagName, keyPrefix, pathPrefix, keys, visiting)
+			continue
+		}
+		if !structField.IsExported() {
+			continue
+		}
+		if isNested {
+			collectFieldKeys(fieldType, tagName, keyPrefix+name+".", pathPrefix+structField.Name+".", keys, visiting)
+			continue
+		}
+		*keys = append(*keys, &FieldKey{Key: keyPrefix + name, Path: pathPrefix + structField.Name, Field: structField})
+	}
+}
+
+func (self *binder) hasPrefix(prefix string) bool {
+	if len(prefix) == 0 {
+		return true
+	}
+	prefix = self.fold(prefix)
+	for k := range self.values {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 结构体t(它的字段的key以keyPrefix开头)是否有字段能找到对应的key.
+func (self *binder) hasFieldKey(t reflect.Type, keyPrefix string, visiting map[reflect.Type]bool) bool {
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		name, _, skip := parseFieldTag(structField, self.opts.TagName)
+		if skip {
+			continue
+		}
+		fieldType := structField.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if structField.Anonymous && fieldType.Kind() == reflect.Struct && !isScalarStruct(fieldType) && structField.Tag.Get(self.opts.TagName) == "" {
+			if self.hasFieldKey(fieldType, keyPrefix, visiting) {
+				return true
+			}
+			continue
+		}
+		if !structField.IsExported() {
+			continue
+		}
+		if _, ok := self.values[self.fold(keyPrefix+name)]; ok || self.hasPrefix(keyPrefix+name+".") { //"Db.Port"和"Labels.k1"
+			return true
+		}
+	}
+	return false
+}
+
+func (self *binder) bindField(field reflect.Value, key, path string) {
+	if values, ok := self.values[self.fold(key)]; ok {
+		if err := self.setValue(field, values); err != nil {
+			self.addError(path, key, values, err)
+		}
+		return
+	}
+
+	fieldType := field.Type()
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	switch {
+	case fieldType.Kind() == reflect.Struct && !isScalarStruct(fieldType):
+		if !self.hasPrefix(key + ".") {
+			return
+		}
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(fieldType))
+			}
+			field = field.Elem()
+		}
+		self.bindStruct(field, key+".", path+".")
+	case fieldType.Kind() == reflect.Map && field.Kind() == reflect.Map:
+		self.bindMapEntries(field, key, path)
+	}
+}
+
+// 处理"Field.k1"这样逐个指定的map元素. map的key取自原来的key(不受UpperKey影响).
+func (self *binder) bindMapEntries(field reflect.Value, key, path string) {
+	prefix := self.fold(key + ".")
+	dots := strings.Count(prefix, ".")
+	for k, values := range self.values {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		original := self.keys[k]
+		entry := original
+		for idx := 0; idx < dots; idx++ { //fold不会改变"."的个数, 按"."的个数找到map的key
+			entry = entry[strings.Index(entry, ".")+1:]
+		}
+		mapKey := reflect.New(field.Type().Key()).Elem()
+		if err := self.setValue(mapKey, []string{entry}); err != nil {
+			self.addError(path+"["+entry+"]", original, values, err)
+			continue
+		}
+		mapValue := reflect.New(field.Type().Elem()).Elem()
+		if err := self.setValue(mapValue, values); err != nil {
+			self.addError(path+"["+entry+"]", original, values, err)
+			continue
+		}
+		if field.IsNil() {
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+		field.SetMapIndex(mapKey, mapValue)
+	}
+}
+
+func (self *binder) splitItems(values []string) []string {
+	if len(values) != 1 {
+		return values
+	}
+	if len(strings.TrimSpace(values[0])) == 0 {
+		return []string{}
+	}
+	items := strings.Split(values[0], self.opts.Separator)
+	for idx := range items {
+		items[idx] = strings.TrimSpace(items[idx])
+	}
+	return items
+}
+
+func (self *binder) parseTime(s string) (t time.Time, err error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range self.opts.TimeLayouts {
+		if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+			return
+		}
+	}
+	err = errors.New(fmt.Sprintf("cannot parse time, layouts=%v", self.opts.TimeLayouts))
+	return
+}
+
+// 把values写入v. 除了slice/array/map之外, 只使用最后一个值.
+func (self *binder) setValue(v reflect.Value, values []string) (err error) {
+	s := ""
+	if 0 < len(values) {
+		s = values[len(values)-1]
+	}
+
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err = self.setValue(ptr.Elem(), values); err != nil {
+			return
+		}
+		v.Set(ptr)
+		return
+	}
+
+	switch v.Type() { //time.Time也实现了TextUnmarshaler(只支持RFC3339), 所以要先处理.
+	case timeType:
+		var t time.Time
+		if t, err = self.parseTime(s); err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return
+	case durationType:
+		var d time.Duration
+		if d, err = time.ParseDuration(strings.TrimSpace(s)); err == nil {
+			v.SetInt(int64(d))
+		}
+		return
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+			v.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(strings.TrimSpace(s), 10, v.Type().Bits()); err == nil {
+			v.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		if u, err = strconv.ParseUint(strings.TrimSpace(s), 10, v.Type().Bits()); err == nil {
+			v.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(strings.TrimSpace(s), v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		var c complex128
+		if c, err = strconv.ParseComplex(strings.TrimSpace(s), v.Type().Bits()); err == nil {
+			v.SetComplex(c)
+		}
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			err = errors.New(fmt.Sprintf("unsupported interface type=%v", v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(s))
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return
+		}
+		items := self.splitItems(values)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for idx, item := range items {
+			if err = self.setValue(slice.Index(idx), []string{item}); err != nil {
+				err = errors.New(fmt.Sprintf("[%v] %v", idx, err))
+				return
+			}
+		}
+		v.Set(slice)
+	case reflect.Array:
+		items := self.splitItems(values)
+		if v.Len() < len(items) {
+			err = errors.New(fmt.Sprintf("too many items, len=%v, cap=%v", len(items), v.Len()))
+			return
+		}
+		array := reflect.New(v.Type()).Elem()
+		for idx, item := range items {
+			if err = self.setValue(array.Index(idx), []string{item}); err != nil {
+				err = errors.New(fmt.Sprintf("[%v] %v", idx, err))
+				return
+			}
+		}
+		v.Set(array)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, item := range self.splitItems(values) {
+			kv := strings.SplitN(item, self.opts.KVSeparator, 2)
+			if len(kv) != 2 {
+				err = errors.New(fmt.Sprintf("map item without %q, item=%v", self.opts.KVSeparator, item))
+				return
+			}
+			mapKey := reflect.New(v.Type().Key()).Elem()
+			mapValue := reflect.New(v.Type().Elem()).Elem()
+			if err = self.setValue(mapKey, []string{strings.TrimSpace(kv[0])}); err != nil {
+				return
+			}
+			if err = self.setValue(mapValue, []string{strings.TrimSpace(kv[1])}); err != nil {
+				return
+			}
+			m.SetMapIndex(mapKey, mapValue)
+		}
+		v.Set(m)
+	default:
+		err = errors.New(fmt.Sprintf("unsupported type=%v", v.Type()))
+	}
+
+	return
+}
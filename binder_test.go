@@ -0,0 +1,182 @@
+package zxgo
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindDb struct {
+	Host string
+	Port uint16
+}
+
+type bindBase struct {
+	Id int64 `zx:"id"`
+}
+
+type BindExtra struct {
+	Memo string
+}
+
+type bindConfig struct {
+	bindBase
+	*BindExtra
+	Name     string `zx:"name"`
+	Enabled  bool
+	Ratio    float32
+	Timeout  time.Duration
+	Created  time.Time
+	Tags     []string
+	Ports    [2]int
+	Labels   map[string]int
+	Db       bindDb
+	DbPtr    *bindDb
+	Text     *upperText
+	Skipped  string `zx:"-"`
+	internal int
+}
+
+func TestBindByMap(t *testing.T) {
+	cfg := bindConfig{internal: 1}
+	err := BindByMap(&cfg, map[string]string{
+		"id":          "7",
+		"name":        "demo",
+		"Enabled":     "true",
+		"Ratio":       "0.5",
+		"Timeout":     "1m30s",
+		"Created":     "2024-05-06 07:08:09",
+		"Tags":        "a, b",
+		"Ports":       "80,443",
+		"Labels":      "x:1,y:2",
+		"Db.Host":     "localhost",
+		"Db.Port":     "5432",
+		"Text":        "HELLO",
+		"Skipped":     "x",
+		"internal":    "2",
+		"unknown.key": "x",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bindConfig{
+		bindBase: bindBase{Id: 7},
+		Name:     "demo",
+		Enabled:  true,
+		Ratio:    0.5,
+		Timeout:  90 * time.Second,
+		Created:  time.Date(2024, 5, 6, 7, 8, 9, 0, time.Local),
+		Tags:     []string{"a", "b"},
+		Ports:    [2]int{80, 443},
+		Labels:   map[string]int{"x": 1, "y": 2},
+		Db:       bindDb{Host: "localhost", Port: 5432},
+		Text:     &upperText{"hello"},
+		internal: 1,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got=%+v\nwant=%+v", cfg, want)
+	}
+	if cfg.BindExtra != nil || cfg.DbPtr != nil {
+		t.Fatalf("pointers without keys are allocated, BindExtra=%v, DbPtr=%v", cfg.BindExtra, cfg.DbPtr)
+	}
+
+	if err = BindByMap(&cfg, map[string]string{"Memo": "m", "DbPtr.Port": "1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BindExtra == nil || cfg.Memo != "m" || cfg.DbPtr == nil || cfg.DbPtr.Port != 1 {
+		t.Fatalf("BindExtra=%v, DbPtr=%v", cfg.BindExtra, cfg.DbPtr)
+	}
+}
+
+func TestBindByMapErrors(t *testing.T) {
+	cfg := bindConfig{}
+	err := BindByMap(&cfg, map[string]string{"id": "x", "Db.Port": "70000", "Ports": "1,2,3", "name": "ok"}, nil)
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("err=%v", err)
+	}
+	paths := make([]string, 0)
+	for _, fieldErr := range bindErr.Errors {
+		paths = append(paths, fieldErr.Path)
+	}
+	if got := strings.Join(paths, ","); got != "Id,Ports,Db.Port" {
+		t.Fatalf("paths=%v, err=%v", got, err)
+	}
+	if cfg.Name != "ok" {
+		t.Fatalf("other fields are not bound, Name=%v", cfg.Name)
+	}
+}
+
+type BindLevel2 struct {
+	Deep string
+}
+
+type BindLevel1 struct {
+	*BindLevel2
+}
+
+type bindNested struct {
+	*BindLevel1
+	Name string
+}
+
+// 匿名的结构体指针: 只有它(或者更深的匿名结构体)的字段有key时才分配. 类型没有导出时不能Set, 所以这里的类型是导出的.
+func TestBindByMapEmbeddedPointer(t *testing.T) {
+	nested := bindNested{}
+	if err := BindByMap(&nested, map[string]string{"Name": "x"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if nested.BindLevel1 != nil {
+		t.Fatalf("BindLevel1=%+v", nested.BindLevel1)
+	}
+	if err := BindByMap(&nested, map[string]string{"Deep": "d"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if nested.BindLevel1 == nil || nested.BindLevel2 == nil || nested.Deep != "d" {
+		t.Fatalf("BindLevel1=%+v", nested.BindLevel1)
+	}
+}
+
+type BindSelf struct {
+	*BindSelf
+	X int
+}
+
+func TestBindByMapRecursiveEmbed(t *testing.T) {
+	data := BindSelf{}
+	if err := BindByMap(&data, map[string]string{"X": "1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data.X != 1 || data.BindSelf != nil {
+		t.Fatalf("data=%+v", data)
+	}
+}
+
+func TestBindByMapUpperKeyMapEntries(t *testing.T) {
+	cfg := bindConfig{}
+	err := BindByMap(&cfg, map[string]string{"NAME": "demo", "labels.MixedCase": "1", "LABELS.a.B": "2"}, &BindOptions{UpperKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "demo" || !reflect.DeepEqual(cfg.Labels, map[string]int{"MixedCase": 1, "a.B": 2}) {
+		t.Fatalf("Name=%v, Labels=%v", cfg.Name, cfg.Labels)
+	}
+}
+
+func TestBindQueryData(t *testing.T) {
+	results := map[int]map[string]string{
+		0: {"id": "1", "name": "a"},
+		1: {"id": "x", "name": "b"},
+	}
+	items := make([]*bindConfig, 0)
+	err := BindQueryData(&items, results, nil)
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) || len(bindErr.Errors) != 1 || bindErr.Errors[0].Path != "[1].Id" {
+		t.Fatalf("err=%v", err)
+	}
+	if len(items) != 2 || items[0].Id != 1 || items[0].Name != "a" || items[1].Name != "b" {
+		t.Fatalf("items=%+v", items)
+	}
+}
@@ -16,6 +16,20 @@ import (
 
 func CalcHash(filename, style string, toUpper bool) (hexStr string, err error) {
 
+	if file, err1 := os.OpenFile(filename, os.O_RDONLY, 0); err1 != nil {
+		err = err1
+		return
+	} else {
+		hexStr, err = CalcHashByReader(file, style, toUpper)
+		file.Close()
+	}
+
+	return
+}
+
+// 和CalcHash一样,只是数据来自reader(比如embed.FS里面的文件).
+func CalcHashByReader(reader io.Reader, style string, toUpper bool) (hexStr string, err error) {
+
 	style = strings.ToLower(style)
 
 	var hs hash.Hash = nil
@@ -33,12 +47,8 @@ func CalcHash(filename, style string, toUpper bool) (hexStr string, err error) {
 		return
 	}
 
-	if file, err1 := os.OpenFile(filename, os.O_RDONLY, 0); err1 != nil {
-		err = err1
+	if _, err = io.Copy(hs, reader); err != nil {
 		return
-	} else {
-		io.Copy(hs, file)
-		file.Close()
 	}
 
 	hexStr = hex.EncodeToString(hs.Sum(nil))
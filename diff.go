@@ -0,0 +1,350 @@
+package zxgo
+
+/*
+比较同一个类型的两个值, 得到字段级别的变化, 可以用于审计日志或者只更新变化了的列:
+	changes, err := Diff(oldUser, newUser)
+	//[{"Kind":"Changed","Path":["Name"],"From":"a","To":"b"}, {"Kind":"Added","Path":["Tags","2"],"To":"x"}]
+	err = Patch(oldUser, changes) //之后oldUser和newUser相同
+Path的每一段是: 结构体的字段名, slice/array的下标, 或者map的key(转换成字符串, key是interface时带上类型, 比如"int:1").
+只比较导出的字段, 嵌套的结构体,指针,slice,array,map会递归比较, time.Time用Equal比较.
+Change里的From/To是深拷贝(规则同DeepCopy), Patch时也会再拷贝一次, 所以Diff和Patch之后, 修改from,to或者data不会互相影响.
+Change可以序列化成JSON, 反序列化之后仍然可以Patch(值会按目标字段的类型转换).
+不支持循环引用的数据.
+*/
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DiffAdded   string = "Added"   //slice追加的元素, map新增的key
+	DiffRemoved string = "Removed" //slice末尾删掉的元素, map删掉的key
+	DiffChanged string = "Changed"
+)
+
+type Change struct {
+	Kind string
+	Path []string
+	From interface{} `json:",omitempty"`
+	To   interface{} `json:",omitempty"`
+}
+
+// 返回"Items[2].Name"这种形式的路径.
+func (self *Change) PathString() string {
+	var builder strings.Builder
+	for idx, segment := range self.Path {
+		if _, err := strconv.Atoi(segment); err == nil && 0 < idx {
+			builder.WriteString("[" + segment + "]")
+			continue
+		}
+		if 0 < idx {
+			builder.WriteString(".")
+		}
+		builder.WriteString(segment)
+	}
+	return builder.String()
+}
+
+func (self *Change) String() string {
+	return fmt.Sprintf("%v %v [%v] => [%v]", self.Kind, self.PathString(), self.From, self.To)
+}
+
+// 计算从from变成to需要的变化, from和to的类型必须相同(可以是指针).
+func Diff(from, to interface{}) ([]*Change, error) {
+	fromValue, toValue := reflect.ValueOf(from), reflect.ValueOf(to)
+	if !fromValue.IsValid() || !toValue.IsValid() || fromValue.Type() != toValue.Type() {
+		return nil, errors.New(fmt.Sprintf("from and to must be the same type, %T != %T", from, to))
+	}
+	changes := make([]*Change, 0)
+	diffValue(fromValue, toValue, nil, &changes)
+	return changes, nil
+}
+
+func appendPath(path []string, segment string) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), segment)
+}
+
+func valueOf(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// 放进Change里的值: 深拷贝, 以免和from/to共享指针,slice,map.
+func changeValue(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return deepCopyValue(v).Interface()
+}
+
+func diffValue(from, to reflect.Value, path []string, changes *[]*Change) {
+	changed := func() {
+		*changes = append(*changes, &Change{Kind: DiffChanged, Path: path, From: changeValue(from), To: changeValue(to)})
+	}
+
+	switch from.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if from.IsNil() || to.IsNil() {
+			if from.IsNil() != to.IsNil() {
+				changed()
+			}
+			return
+		}
+		if from.Kind() == reflect.Interface && from.Elem().Type() != to.Elem().Type() {
+			changed()
+			return
+		}
+		diffValue(from.Elem(), to.Elem(), path, changes)
+	case reflect.Struct:
+		if from.Type() == timeType {
+			if !from.Interface().(time.Time).Equal(to.Interface().(time.Time)) {
+				changed()
+			}
+			return
+		}
+		for i := 0; i < from.NumField(); i++ {
+			if structField := from.Type().Field(i); structField.IsExported() {
+				diffValue(from.Field(i), to.Field(i), appendPath(path, structField.Name), changes)
+			}
+		}
+	case reflect.Slice:
+		if from.IsNil() != to.IsNil() && from.Len() == 0 && to.Len() == 0 {
+			return //nil和空slice看作相同
+		}
+		common := from.Len()
+		if to.Len() < common {
+			common = to.Len()
+		}
+		for i := 0; i < common; i++ {
+			diffValue(from.Index(i), to.Index(i), appendPath(path, strconv.Itoa(i)), changes)
+		}
+		for i := from.Len() - 1; common <= i; i-- { //从后往前删除, Patch时下标才不会错
+			*changes = append(*changes, &Change{Kind: DiffRemoved, Path: appendPath(path, strconv.Itoa(i)), From: changeValue(from.Index(i))})
+		}
+		for i := common; i < to.Len(); i++ {
+			*changes = append(*changes, &Change{Kind: DiffAdded, Path: appendPath(path, strconv.Itoa(i)), To: changeValue(to.Index(i))})
+		}
+	case reflect.Array:
+		for i := 0; i < from.Len(); i++ {
+			diffValue(from.Index(i), to.Index(i), appendPath(path, strconv.Itoa(i)), changes)
+		}
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, key := range from.MapKeys() {
+			keys[formatMapKey(key)] = key
+		}
+		for _, key := range to.MapKeys() {
+			keys[formatMapKey(key)] = key
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fromItem, toItem := from.MapIndex(keys[name]), to.MapIndex(keys[name])
+			switch {
+			case !toItem.IsValid():
+				*changes = append(*changes, &Change{Kind: DiffRemoved, Path: appendPath(path, name), From: changeValue(fromItem)})
+			case !fromItem.IsValid():
+				*changes = append(*changes, &Change{Kind: DiffAdded, Path: appendPath(path, name), To: changeValue(toItem)})
+			default:
+				diffValue(fromItem, toItem, appendPath(path, name), changes)
+			}
+		}
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		if from.Pointer() != to.Pointer() {
+			changed()
+		}
+	default:
+		if valueOf(from) != valueOf(to) {
+			changed()
+		}
+	}
+}
+
+// interface类型的key可能是不同类型的值(比如1和"1"), 所以带上动态类型, 比如"int:1", "string:1".
+func formatMapKey(key reflect.Value) string {
+	switch key.Kind() {
+	case reflect.Interface:
+		if key.IsNil() {
+			return nilMapKey
+		}
+		return key.Elem().Type().String() + ":" + formatMapKey(key.Elem())
+	case reflect.String:
+		return key.String()
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(key.Interface())
+	}
+	content, _ := json.Marshal(key.Interface())
+	return string(content)
+}
+
+const nilMapKey = "<nil>"
+
+// interface类型的key只支持基本类型的值(对应formatMapKey里的类型名).
+var mapKeyTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, value := range []interface{}{false, "", int(0), int8(0), int16(0), int32(0), int64(0),
+		uint(0), uint8(0), uint16(0), uint32(0), uint64(0), uintptr(0), float32(0), float64(0)} {
+		mapKeyTypes[reflect.TypeOf(value).String()] = reflect.TypeOf(value)
+	}
+}
+
+func parseMapKey(name string, keyType reflect.Type) (reflect.Value, error) {
+	key := reflect.New(keyType).Elem()
+	if keyType.Kind() == reflect.Interface {
+		if name == nilMapKey {
+			return key, nil
+		}
+		idx := strings.Index(name, ":")
+		if idx < 0 || mapKeyTypes[name[:idx]] == nil {
+			return key, errors.New(fmt.Sprintf("illegal map key=%v, type=%v", name, keyType))
+		}
+		elem, err := parseMapKey(name[idx+1:], mapKeyTypes[name[:idx]])
+		if err != nil {
+			return key, err
+		}
+		if !elem.Type().AssignableTo(keyType) {
+			return key, errors.New(fmt.Sprintf("illegal map key=%v, type=%v", name, keyType))
+		}
+		key.Set(elem)
+		return key, nil
+	}
+	if keyType.Kind() == reflect.String {
+		key.SetString(name)
+		return key, nil
+	}
+	if err := json.Unmarshal([]byte(name), key.Addr().Interface()); err != nil {
+		return key, errors.New(fmt.Sprintf("illegal map key=%v, type=%v", name, keyType))
+	}
+	return key, nil
+}
+
+// 按顺序把changes应用到data(必须是指针)上.
+func Patch(data interface{}, changes []*Change) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New(fmt.Sprintf("data must be a non-nil pointer, type=%T", data))
+	}
+	for _, change := range changes {
+		if err := patchValue(v.Elem(), change.Path, change); err != nil {
+			return errors.New(fmt.Sprintf("patch %v fail: %v", change.PathString(), err))
+		}
+	}
+	return nil
+}
+
+func patchValue(v reflect.Value, path []string, change *Change) error {
+	if len(path) == 0 {
+		if change.Kind == DiffRemoved {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return assignValue(v, change.To)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return patchValue(v.Elem(), path, change)
+	case reflect.Interface:
+		if v.IsNil() {
+			return errors.New("nil interface")
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		if err := patchValue(elem, path, change); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Struct:
+		structField, ok := v.Type().FieldByName(path[0])
+		if !ok || !structField.IsExported() {
+			return errors.New(fmt.Sprintf("no field %v in %v", path[0], v.Type()))
+		}
+		return patchValue(v.FieldByIndex(structField.Index), path[1:], change)
+	case reflect.Slice, reflect.Array:
+		idx, err := strconv.Atoi(path[0])
+		if err != nil || idx < 0 {
+			return errors.New(fmt.Sprintf("illegal index=%v", path[0]))
+		}
+		if v.Kind() == reflect.Slice && len(path) == 1 {
+			//底层数组可能和别的slice共享, 增删元素时使用新的数组, 不覆盖原来的元素
+			switch {
+			case change.Kind == DiffAdded && idx == v.Len():
+				v.Set(reflect.Append(v.Slice3(0, v.Len(), v.Len()), reflect.Zero(v.Type().Elem())))
+			case change.Kind == DiffRemoved && idx < v.Len():
+				s := reflect.MakeSlice(v.Type(), 0, v.Len()-1)
+				s = reflect.AppendSlice(s, v.Slice(0, idx))
+				v.Set(reflect.AppendSlice(s, v.Slice(idx+1, v.Len())))
+				return nil
+			}
+		}
+		if v.Len() <= idx {
+			return errors.New(fmt.Sprintf("index out of range, index=%v, len=%v", idx, v.Len()))
+		}
+		return patchValue(v.Index(idx), path[1:], change)
+	case reflect.Map:
+		key, err := parseMapKey(path[0], v.Type().Key())
+		if err != nil {
+			return err
+		}
+		if len(path) == 1 && change.Kind == DiffRemoved {
+			if !v.IsNil() {
+				v.SetMapIndex(key, reflect.Value{})
+			}
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		elem := reflect.New(v.Type().Elem()).Elem() //map的元素不能取地址, 修改副本之后再放回去
+		if item := v.MapIndex(key); item.IsValid() {
+			elem.Set(item)
+		}
+		if err = patchValue(elem, path[1:], change); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	}
+	return errors.New(fmt.Sprintf("can not walk into type=%v", v.Type()))
+}
+
+// 把value(的深拷贝)赋值给v, 类型不同时(比如JSON反序列化之后的float64,map)通过JSON转换.
+func assignValue(v reflect.Value, value interface{}) error {
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	src := reflect.ValueOf(value)
+	if src.Type().AssignableTo(v.Type()) {
+		v.Set(deepCopyValue(src))
+		return nil
+	}
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	target := reflect.New(v.Type())
+	if err = json.Unmarshal(content, target.Interface()); err != nil {
+		return err
+	}
+	v.Set(target.Elem())
+	return nil
+}
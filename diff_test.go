@@ -0,0 +1,190 @@
+package zxgo
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type diffItem struct {
+	Name  string
+	Count int
+}
+
+type diffUser struct {
+	Name    string
+	Age     int
+	Created time.Time
+	Tags    []string
+	Items   []*diffItem
+	Labels  map[string]string
+	Scores  map[interface{}]int
+	Db      *diffItem
+	Any     interface{}
+	Pair    [2]int
+	hidden  int
+}
+
+func newDiffUsers() (*diffUser, *diffUser) {
+	from := &diffUser{
+		Name:    "a",
+		Age:     1,
+		Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Tags:    []string{"x", "y", "z"},
+		Items:   []*diffItem{{"i1", 1}},
+		Labels:  map[string]string{"k1": "v1", "k2": "v2"},
+		Scores:  map[interface{}]int{1: 1, "1": 2},
+		Any:     1,
+		hidden:  1,
+	}
+	to := &diffUser{
+		Name:    "b",
+		Age:     1,
+		Created: time.Date(2024, 1, 2, 11, 4, 5, 0, time.FixedZone("CST", 8*3600)), //同一个时刻
+		Tags:    []string{"x"},
+		Items:   []*diffItem{{"i1", 2}, {"i2", 1}},
+		Labels:  map[string]string{"k1": "v1", "k3": "v3"},
+		Scores:  map[interface{}]int{1: 3, "1": 2, int64(1): 4},
+		Db:      &diffItem{"db", 1},
+		Any:     "1",
+		Pair:    [2]int{0, 5},
+		hidden:  2,
+	}
+	return from, to
+}
+
+func changesString(changes []*Change) string {
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestDiff(t *testing.T) {
+	from, to := newDiffUsers()
+	changes, err := Diff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"Changed Name [a] => [b]",
+		"Removed Tags[2] [z] => [<nil>]",
+		"Removed Tags[1] [y] => [<nil>]",
+		"Changed Items[0].Count [1] => [2]",
+		"Added Items[1] [<nil>] => [&{i2 1}]",
+		"Removed Labels.k2 [v2] => [<nil>]",
+		"Added Labels.k3 [<nil>] => [v3]",
+		"Added Scores.int64:1 [<nil>] => [4]",
+		"Changed Scores.int:1 [1] => [3]",
+		"Changed Db [<nil>] => [&{db 1}]",
+		"Changed Any [1] => [1]",
+		"Changed Pair[1] [0] => [5]",
+	}, "\n")
+	if got := changesString(changes); got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+
+	if _, err = Diff(from, *to); err == nil {
+		t.Fatal("different types are not rejected")
+	}
+	if changes, err = Diff([]int(nil), []int{}); err != nil || len(changes) != 0 {
+		t.Fatalf("nil and empty slice, changes=%v, err=%v", changesString(changes), err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	from, to := newDiffUsers()
+	changes, err := Diff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := json.Marshal(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	to.hidden = from.hidden   //未导出的字段不比较
+	to.Created = from.Created //同一个时刻, 没有变化
+	if err = Patch(from, changes); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(from, to) {
+		t.Fatalf("got %+v\nwant %+v", from, to)
+	}
+
+	//JSON反序列化之后的值(float64, map[string]interface{})按字段类型转换
+	jsonFrom, _ := newDiffUsers()
+	jsonChanges := make([]*Change, 0)
+	if err = json.Unmarshal(content, &jsonChanges); err != nil {
+		t.Fatal(err)
+	}
+	if err = Patch(jsonFrom, jsonChanges); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(jsonFrom.Items, to.Items) || !reflect.DeepEqual(jsonFrom.Scores, to.Scores) || jsonFrom.Name != "b" {
+		t.Fatalf("got %+v", jsonFrom)
+	}
+
+	if err = Patch(*from, changes); err == nil {
+		t.Fatal("non-pointer data is not rejected")
+	}
+	if err = Patch(from, []*Change{{Kind: DiffChanged, Path: []string{"Unknown"}, To: 1}}); err == nil {
+		t.Fatal("unknown field is not rejected")
+	}
+	if err = Patch(from, []*Change{{Kind: DiffAdded, Path: []string{"Scores", "diffItem:1"}, To: 1}}); err == nil {
+		t.Fatal("unsupported interface key is not rejected")
+	}
+}
+
+// Change和Patch之后的data都不能和from,to共享指针,slice,map.
+func TestDiffPatchNoAlias(t *testing.T) {
+	from, to := newDiffUsers()
+	changes, err := Diff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to.Db.Name = "changed"
+	to.Items[1].Name = "changed"
+	for _, change := range changes {
+		if change.PathString() == "Db" && change.To.(*diffItem).Name != "db" {
+			t.Fatalf("change shares data with to, %v", change)
+		}
+	}
+
+	if err = Patch(from, changes); err != nil {
+		t.Fatal(err)
+	}
+	if from.Db.Name != "db" || from.Items[1].Name != "i2" {
+		t.Fatalf("data shares data with to, Db=%+v, Items[1]=%+v", from.Db, from.Items[1])
+	}
+	from.Db.Name = "patched"
+	other, _ := newDiffUsers()
+	if err = Patch(other, changes); err != nil {
+		t.Fatal(err)
+	}
+	if other.Db.Name != "db" || other.Db == from.Db {
+		t.Fatalf("data shares data with change, Db=%+v", other.Db)
+	}
+}
+
+// 删除和追加元素不能覆盖共享的底层数组.
+func TestPatchSharedSlice(t *testing.T) {
+	shared := []string{"x", "y", "z", "w"}
+	data := &diffUser{Tags: shared[:3]}
+	changes := []*Change{
+		{Kind: DiffRemoved, Path: []string{"Tags", "0"}},
+		{Kind: DiffAdded, Path: []string{"Tags", "2"}, To: "n"},
+	}
+	if err := Patch(data, changes); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data.Tags, []string{"y", "z", "n"}) {
+		t.Fatalf("Tags=%v", data.Tags)
+	}
+	if !reflect.DeepEqual(shared, []string{"x", "y", "z", "w"}) {
+		t.Fatalf("shared array is modified, shared=%v", shared)
+	}
+}
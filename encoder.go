@@ -0,0 +1,258 @@
+package zxgo
+
+/*
+EncodeToMap 是 BindByMap 的反方向, 规则和 BindByMap 一一对应:
+	tag(`zx:"name,omitempty"`), 嵌套结构体的"A.B"前缀, 匿名结构体不加前缀, UpperKey,
+	slice/array用Separator连接, map写成"k1:v1,k2:v2"(按key排序), nil指针/slice/map不输出.
+使用默认选项时, 支持的类型经过 EncodeToMap + BindByMap 之后保持不变(nil和空的slice/map也能区分),
+前提: slice和map里的字符串不包含分隔符; interface{}字段不在此列, 它的值只能恢复成string.
+*/
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type EncodeOptions struct {
+	TagName     string //为空时使用DefaultTagName, 为"-"时忽略tag只使用字段名.
+	UpperKey    bool   //key全部转成大写.
+	OmitEmpty   bool   //所有字段都按omitempty处理.
+	Separator   string //为空时使用",".
+	KVSeparator string //为空时使用":".
+	TimeLayout  string //为空时使用time.RFC3339Nano(不丢失精度和时区).
+	//下面的格式化函数为nil时使用默认规则. float的默认规则是strconv.FormatFloat(f, 'g', -1, bits).
+	TimeFormatter  func(t time.Time) string
+	FloatFormatter func(f float64, bits int) string
+}
+
+type EncodeError struct {
+	Errors []*FieldError
+}
+
+func (self *EncodeError) Error() string {
+	messages := make([]string, 0, len(self.Errors))
+	for _, fieldErr := range self.Errors {
+		messages = append(messages, fieldErr.Error())
+	}
+	return fmt.Sprintf("encode fail (%v fields): %v", len(self.Errors), strings.Join(messages, "; "))
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// 把data(结构体或结构体指针)的各个字段转换成map, opts为nil时使用默认值.
+func EncodeToMap(data interface{}, opts *EncodeOptions) (map[string]string, error) {
+	elem := reflect.ValueOf(data)
+	for elem.Kind() == reflect.Ptr && !elem.IsNil() {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, errors.New(fmt.Sprintf("data must be a struct or a pointer to struct, type=%T", data))
+	}
+
+	if !elem.CanAddr() { //使MarshalText是指针方法的字段也可以取地址
+		addressable := reflect.New(elem.Type()).Elem()
+		addressable.Set(elem)
+		elem = addressable
+	}
+	e := newEncoder(opts)
+	e.encodeStruct(elem, "", "")
+	if len(e.errors) != 0 {
+		return e.kvs, &EncodeError{e.errors}
+	}
+	return e.kvs, nil
+}
+
+type encoder struct {
+	opts   EncodeOptions
+	kvs    map[string]string
+	errors []*FieldError
+}
+
+func newEncoder(opts *EncodeOptions) *encoder {
+	e := &encoder{kvs: make(map[string]string), errors: make([]*FieldError, 0)}
+	if opts != nil {
+		e.opts = *opts
+	}
+	if len(e.opts.TagName) == 0 {
+		e.opts.TagName = DefaultTagName
+	}
+	if len(e.opts.Separator) == 0 {
+		e.opts.Separator = ","
+	}
+	if len(e.opts.KVSeparator) == 0 {
+		e.opts.KVSeparator = ":"
+	}
+	if len(e.opts.TimeLayout) == 0 {
+		e.opts.TimeLayout = time.RFC3339Nano
+	}
+	return e
+}
+
+func hasTagOption(options []string, option string) bool {
+	for _, item := range options {
+		if item == option {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *encoder) encodeStruct(elem reflect.Value, keyPrefix, pathPrefix string) {
+	elemType := elem.Type()
+	for i := 0; i < elem.NumField(); i++ {
+		structField := elemType.Field(i)
+		field := elem.Field(i)
+
+		name, options, skip := parseFieldTag(structField, self.opts.TagName)
+		if skip {
+			continue
+		}
+
+		fieldType := structField.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if structField.Anonymous && fieldType.Kind() == reflect.Struct && !isScalarStruct(fieldType) && structField.Tag.Get(self.opts.TagName) == "" {
+			if field.Kind() == reflect.Ptr {
+				if field.IsNil() {
+					continue
+				}
+				field = field.Elem()
+			}
+			self.encodeStruct(field, keyPrefix, pathPrefix)
+			continue
+		}
+
+		if !structField.IsExported() {
+			continue
+		}
+		omitEmpty := self.opts.OmitEmpty || hasTagOption(options, "omitempty")
+		self.encodeField(field, keyPrefix+name, pathPrefix+structField.Name, omitEmpty)
+	}
+}
+
+func (self *encoder) encodeField(field reflect.Value, key, path string, omitEmpty bool) {
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		if field.IsNil() { //不输出, BindByMap之后仍然是nil
+			return
+		}
+		if field.Kind() == reflect.Ptr {
+			field = field.Elem()
+		}
+	}
+	if omitEmpty && field.IsZero() {
+		return
+	}
+
+	if field.Kind() == reflect.Struct && !isScalarStruct(field.Type()) && !reflect.PtrTo(field.Type()).Implements(textMarshalerType) {
+		self.encodeStruct(field, key+".", path+".")
+		return
+	}
+
+	content, err := self.format(field)
+	if err != nil {
+		self.errors = append(self.errors, &FieldError{Path: path, Key: key, Err: err})
+		return
+	}
+	if self.opts.UpperKey {
+		key = strings.ToUpper(key)
+	}
+	self.kvs[key] = content
+}
+
+func (self *encoder) format(v reflect.Value) (content string, err error) {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		return self.format(v.Elem())
+	}
+
+	switch v.Type() {
+	case timeType:
+		t := v.Interface().(time.Time)
+		if self.opts.TimeFormatter != nil {
+			return self.opts.TimeFormatter(t), nil
+		}
+		return t.Format(self.opts.TimeLayout), nil
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	}
+
+	var marshaler encoding.TextMarshaler
+	if v.Type().Implements(textMarshalerType) {
+		marshaler = v.Interface().(encoding.TextMarshaler)
+	} else if reflect.PtrTo(v.Type()).Implements(textMarshalerType) && v.CanInterface() { //指针方法
+		if !v.CanAddr() {
+			addressable := reflect.New(v.Type()).Elem()
+			addressable.Set(v)
+			v = addressable
+		}
+		marshaler = v.Addr().Interface().(encoding.TextMarshaler)
+	}
+	if marshaler != nil {
+		var data []byte
+		if data, err = marshaler.MarshalText(); err != nil {
+			return
+		}
+		return string(data), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		content = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		content = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		content = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		if self.opts.FloatFormatter != nil {
+			content = self.opts.FloatFormatter(v.Float(), v.Type().Bits())
+		} else {
+			content = strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+		}
+	case reflect.Complex64, reflect.Complex128:
+		content = strconv.FormatComplex(v.Complex(), 'g', -1, v.Type().Bits())
+	case reflect.String:
+		content = v.String()
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			return string(v.Bytes()), nil
+		}
+		items := make([]string, 0, v.Len())
+		for idx := 0; idx < v.Len(); idx++ {
+			var item string
+			if item, err = self.format(v.Index(idx)); err != nil {
+				err = errors.New(fmt.Sprintf("[%v] %v", idx, err))
+				return
+			}
+			items = append(items, item)
+		}
+		content = strings.Join(items, self.opts.Separator)
+	case reflect.Map:
+		items := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var k, val string
+			if k, err = self.format(iter.Key()); err != nil {
+				return
+			}
+			if val, err = self.format(iter.Value()); err != nil {
+				return
+			}
+			items = append(items, k+self.opts.KVSeparator+val)
+		}
+		sort.Strings(items)
+		content = strings.Join(items, self.opts.Separator)
+	default:
+		err = errors.New(fmt.Sprintf("unsupported type=%v", v.Type()))
+	}
+
+	return
+}
@@ -0,0 +1,110 @@
+package zxgo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MarshalText/UnmarshalText都是指针方法.
+type upperText struct {
+	s string
+}
+
+func (self *upperText) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(self.s)), nil
+}
+
+func (self *upperText) UnmarshalText(text []byte) error {
+	self.s = strings.ToLower(string(text))
+	return nil
+}
+
+type encodeDb struct {
+	Host    string
+	Port    int
+	Timeout time.Duration
+}
+
+type encodeBase struct {
+	Id int64 `zx:"id"`
+}
+
+type encodeConfig struct {
+	encodeBase
+	Name     string `zx:"name"`
+	Enabled  bool
+	Ratio    float64
+	Created  time.Time
+	Tags     []string
+	NilTags  []string
+	Empty    []int
+	Ports    [3]int
+	Labels   map[string]int
+	NilMap   map[string]int
+	Data     []byte
+	Db       encodeDb
+	DbPtr    *encodeDb
+	NilPtr   *encodeDb
+	Text     upperText
+	TextPtr  *upperText
+	Count    *int
+	skipped  int
+	Disabled string `zx:"-"`
+}
+
+func TestEncodeToMapRoundTrip(t *testing.T) {
+	count := 7
+	src := encodeConfig{
+		encodeBase: encodeBase{Id: 42},
+		Name:       "demo",
+		Enabled:    true,
+		Ratio:      0.1,
+		Created:    time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.FixedZone("X", 8*3600)),
+		Tags:       []string{"a", "b"},
+		Empty:      []int{},
+		Ports:      [3]int{80, 443, 0},
+		Labels:     map[string]int{"x": 1, "y": 2},
+		Data:       []byte("raw"),
+		Db:         encodeDb{Host: "localhost", Port: 5432, Timeout: 90 * time.Second},
+		DbPtr:      &encodeDb{Host: "remote"},
+		Text:       upperText{"hello"},
+		TextPtr:    &upperText{"world"},
+		Count:      &count,
+		skipped:    1,
+		Disabled:   "x",
+	}
+
+	for _, data := range []interface{}{src, &src} {
+		kvs, err := EncodeToMap(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kvs["Text"] != "HELLO" || kvs["TextPtr"] != "WORLD" {
+			t.Fatalf("pointer receiver MarshalText not used, kvs=%v", kvs)
+		}
+		for _, key := range []string{"NilTags", "NilMap", "NilPtr.Host", "skipped", "Disabled"} {
+			if _, ok := kvs[key]; ok {
+				t.Fatalf("unexpected key=%v, kvs=%v", key, kvs)
+			}
+		}
+
+		dst := encodeConfig{}
+		if err = BindByMap(&dst, kvs, nil); err != nil {
+			t.Fatal(err)
+		}
+		want := src
+		want.skipped, want.Disabled = 0, ""
+		if !want.Created.Equal(dst.Created) {
+			t.Fatalf("Created=%v, want %v", dst.Created, want.Created)
+		}
+		dst.Created = want.Created
+		if !reflect.DeepEqual(want, dst) {
+			t.Fatalf("round trip mismatch\nwant=%+v\n got=%+v", want, dst)
+		}
+		if dst.NilTags != nil || dst.Empty == nil || dst.NilMap != nil {
+			t.Fatalf("nil and empty are not preserved, NilTags=%#v, Empty=%#v, NilMap=%#v", dst.NilTags, dst.Empty, dst.NilMap)
+		}
+	}
+}
@@ -116,43 +116,32 @@ func (self *QtSqliteField) parseContent_1(line string) (matched bool, err error)
 
 func (self *QtSqliteField) parseContent_2(line string) (matched bool, err error) {
 	pattern := "^[ \t]*(?P<QtDataType>[a-zA-Z0-9_: ]+?)[ \t]+(?P<QtDataName>[a-zA-Z0-9_:]+);[ \t]*//`(?P<SqliteOptions>[a-zA-Z0-9_, \t]*?)`"
-	allGroupMap := zxre.CalcAllGroupDict(pattern, line)
-	if len(allGroupMap) != 1 {
-		matched = false
-		err = nil
-		return
+	var groups struct {
+		QtDataType    string `zx:",required"`
+		QtDataName    string `zx:",required"`
+		SqliteOptions string `zx:",required"`
 	}
-
-	matched = true
-
-	var ok bool
-
-	if self.QtDataType, ok = allGroupMap[0]["QtDataType"]; !ok || len(self.QtDataType) == 0 {
-		err = errors.New(fmt.Sprintf("can not find QtDataType, content=%v", line))
+	if matched, err = zxre.Unmarshal(pattern, line, &groups, nil); !matched {
 		return
 	}
-
-	if self.QtDataName, ok = allGroupMap[0]["QtDataName"]; !ok || len(self.QtDataName) == 0 {
-		err = errors.New(fmt.Sprintf("can not find QtDataName, content=%v", line))
+	if err != nil {
+		err = errors.New(fmt.Sprintf("%v, content=%v", err, line))
 		return
 	}
 
-	if SqliteOptions, ok := allGroupMap[0]["SqliteOptions"]; !ok {
-		err = errors.New(fmt.Sprintf("can not find SqliteOptions, content=%v", line))
-		return
+	self.QtDataType = groups.QtDataType
+	self.QtDataName = groups.QtDataName
+	if len(groups.SqliteOptions) == 0 {
+		self.SqliteValid = false
+		self.ObjectTableName = false
+	} else if 0 <= strings.Index(groups.SqliteOptions, "ZX_TABLENAME") {
+		self.SqliteValid = false
+		self.ObjectTableName = true
 	} else {
-		if len(SqliteOptions) == 0 {
-			self.SqliteValid = false
-			self.ObjectTableName = false
-		} else if 0 <= strings.Index(SqliteOptions, "ZX_TABLENAME") {
-			self.SqliteValid = false
-			self.ObjectTableName = true
-		} else {
-			self.SqliteValid = true
-			self.ObjectTableName = false
-			if err = self.parseSqliteOptions(SqliteOptions); err != nil {
-				err = errors.New(fmt.Sprintf("parseSqliteOptions, %v, content=%v", err, line))
-			}
+		self.SqliteValid = true
+		self.ObjectTableName = false
+		if err = self.parseSqliteOptions(groups.SqliteOptions); err != nil {
+			err = errors.New(fmt.Sprintf("parseSqliteOptions, %v, content=%v", err, line))
 		}
 	}
 
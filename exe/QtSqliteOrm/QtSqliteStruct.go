@@ -40,20 +40,15 @@ func (self *QtSqliteStruct) parseContent(line string) error {
 
 	if len(self.QtClassName) == 0 {
 		patternStruct := "^struct[ \t]+(?P<QtClassName>[a-zA-Z0-9_:]+)[ \t]*//`(?P<Tablename>[a-zA-Z0-9_]+)`"
-		allGroupMap := zxre.CalcAllGroupDict(patternStruct, line)
-		if len(allGroupMap) != 1 {
-			return errors.New(fmt.Sprintf("logical error, content=%v", line))
-		}
-
-		var ok bool
-
-		if self.QtClassName, ok = allGroupMap[0]["QtClassName"]; !ok || len(self.QtClassName) == 0 {
-			return errors.New(fmt.Sprintf("logical error, content=%v", line))
+		var groups struct {
+			QtClassName string `zx:",required"`
+			Tablename   string `zx:",required"`
 		}
-
-		if self.Tablename, ok = allGroupMap[0]["Tablename"]; !ok || len(self.Tablename) == 0 {
+		if matched, err := zxre.Unmarshal(patternStruct, line, &groups, nil); !matched || err != nil {
 			return errors.New(fmt.Sprintf("logical error, content=%v", line))
 		}
+		self.QtClassName = groups.QtClassName
+		self.Tablename = groups.Tablename
 
 	} else {
 		if strings.HasPrefix(line, "{") {
@@ -3,7 +3,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -15,12 +14,14 @@ func parseFileContent(filename string) []*QtSqliteStruct {
 
 	var currData *QtSqliteStruct = nil
 	var currDeal bool = false
-	var lineNum int = 0
 
-	var eOut string
-	for line, err, _, _, iter := file.ReadLine(filename, &eOut); err == nil; line, err, _, _ = iter.Next() {
-		lineNum += 1
-		line = strings.TrimRight(line, "\r\n")
+	reader, err := file.OpenLineReader(filename)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("[ERROR] %v", err))
+		return slice_
+	}
+	defer reader.Close()
+	for lineNum, line := range reader.All() {
 		if strings.HasPrefix(line, "struct ") {
 			currDeal = true
 			currData = newQtSqliteStruct()
@@ -35,8 +36,8 @@ func parseFileContent(filename string) []*QtSqliteStruct {
 			}
 		}
 	}
-	if 0 < len(eOut) {
-		fmt.Println(fmt.Sprintf("[ERROR] %v", eOut))
+	if err := reader.Err(); err != nil {
+		fmt.Println(fmt.Sprintf("[ERROR] lineNum=%v,err=%v", reader.LineNumber(), err))
 	}
 
 	return slice_
@@ -54,22 +55,20 @@ func main() {
 	}
 
 	allStruct := parseFileContent(*inputFilenamePtr)
-	if 0 < len(allStruct) {
-		if file, err := os.OpenFile(*outputFilenamePtr, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666); err == nil {
-			file.Close()
-		}
-	}
-
-	file.AppendLine(*outputFilenamePtr, "#include <QObject>", true)
-	file.AppendLine(*outputFilenamePtr, "#include <QString>", true)
-	file.AppendLine(*outputFilenamePtr, "#include <QVariant>", true)
-	file.AppendLine(*outputFilenamePtr, "#include <QSqlQuery>", true)
-	file.AppendLine(*outputFilenamePtr, "", true)
-	file.AppendLine(*outputFilenamePtr, "", true)
 
+	lines := []string{
+		"#include <QObject>",
+		"#include <QString>",
+		"#include <QVariant>",
+		"#include <QSqlQuery>",
+		"",
+		"",
+	}
 	for _, currStruct := range allStruct {
-		strData := currStruct.generate_cxx_definition()
-		file.AppendLine(*outputFilenamePtr, strData, true)
+		lines = append(lines, currStruct.generate_cxx_definition())
+	}
+	if err := file.WriteAllLines(*outputFilenamePtr, lines, file.UTF8); err != nil { //原子地写入, 不会留下写了一半的文件
+		panic(err)
 	}
 
 	fmt.Println("DONE.", time.Now().Format("2006-01-02 15:04:05"))
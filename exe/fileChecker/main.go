@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/zx9229/zxgo"
+	"github.com/zx9229/zxgo/zxmatch"
 )
 
 type commonConfigData struct {
@@ -19,7 +20,8 @@ type commonConfigData struct {
 	root    string
 	match   string
 	glob    string
-	pattern *regexp.Regexp
+	pattern *regexp.Regexp //-glob或-regexp编译之后的结果
+	ignore  *zxmatch.RuleSet
 	depth   int
 }
 
@@ -31,6 +33,7 @@ type flagConfigData struct {
 	matchPtr  *string
 	globPtr   *string
 	regexpPtr *string
+	ignorePtr *string
 	depthPtr  *int
 }
 
@@ -63,12 +66,11 @@ func (thls *flagConfigData) toCommon() (cfg *commonConfigData, err error) {
 		cfg.match = *thls.matchPtr
 
 		if *thls.globPtr != EmptyStr {
-			if _, err2 := filepath.Match(*thls.globPtr, ""); err2 != nil {
+			if cfg.pattern, err = zxmatch.CompileGlob(*thls.globPtr); err != nil {
 				err = errors.New("syntax error in glob")
 				break
 			}
 			cfg.glob = *thls.globPtr
-			cfg.pattern = nil
 		} else if *thls.regexpPtr != EmptyStr {
 			if cfg.pattern, err = regexp.Compile(*thls.regexpPtr); err != nil {
 				err = errors.New("syntax error in regexp")
@@ -79,6 +81,12 @@ func (thls *flagConfigData) toCommon() (cfg *commonConfigData, err error) {
 			cfg.glob = EmptyStr
 			cfg.pattern = nil
 		}
+
+		if *thls.ignorePtr != EmptyStr {
+			if cfg.ignore, err = zxmatch.LoadIgnoreFile(*thls.ignorePtr); err != nil {
+				break
+			}
+		}
 	}
 
 	if err != nil {
@@ -105,8 +113,9 @@ func main() {
 	flagCfg.namePtr = flag.String("name", "", "set file name")
 	flagCfg.rootPtr = flag.String("root", ".", "set root path")
 	flagCfg.matchPtr = flag.String("match", "NAME", "one of NAME,RELNAME,ABSNAME")
-	flagCfg.globPtr = flag.String("glob", "", "match with glob")
+	flagCfg.globPtr = flag.String("glob", "", "match with glob (support **, {a,b})")
 	flagCfg.regexpPtr = flag.String("regexp", "", "match with regexp")
+	flagCfg.ignorePtr = flag.String("ignore", "", "skip files in this .gitignore style file (relative to root)")
 	flagCfg.depthPtr = flag.Int("depth", 0, "set path maximum depth")
 	//所有标志都声明完成以后，调用 flag.Parse() 来执行命令行解析。
 	flag.Parse()
@@ -227,21 +236,17 @@ func calcRelName(rootDir string, absName string) string {
 
 func isMatch(rootDir, absName string, info os.FileInfo, glob string, pattern *regexp.Regexp, matchType string) bool {
 
-	if glob == EmptyStr && pattern == nil {
+	if pattern == nil {
 		return true
 	}
 
 	var matched bool
 
-	var err error
 	doMatchOperation := func(someName string) {
-		if pattern != nil {
-			matched = pattern.MatchString(someName)
-		} else {
-			if matched, err = filepath.Match(glob, someName); err != nil {
-				panic(err)
-			}
+		if glob != EmptyStr {
+			someName = filepath.ToSlash(someName) //glob使用"/"作为分隔符
 		}
+		matched = pattern.MatchString(someName)
 	}
 
 	switch matchType {
@@ -275,6 +280,15 @@ func _WalkCallbackFunc(path string, info os.FileInfo, errIn error) error {
 		}
 	}
 
+	if g_cfg.ignore != nil && path != g_cfg.root {
+		if relPath, err2 := filepath.Rel(g_cfg.root, path); err2 == nil && g_cfg.ignore.Ignored(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+	}
+
 	if info.IsDir() {
 		return err
 	}
@@ -15,34 +15,66 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/zx9229/zxgo/file"
+	"github.com/zx9229/zxgo/zxconfig"
+	"github.com/zx9229/zxgo/zxmatch"
+	"github.com/zx9229/zxgo/zxvalid"
 )
 
+type serverConfig struct {
+	Help bool     `zx:"help" help:"[M] show this help."`
+	Port int      `zx:"port" default:"9999" help:"[M] port" validate:"min=1,max=65535"`
+	Host string   `zx:"host" default:"localhost" help:"[M] host"`
+	Home string   `zx:"home" default:"." help:"[M] home directory" validate:"dir"`
+	Deny []string `zx:"deny" help:"[O] reject uploaded file names matching these .gitignore style rules, separated by ';' (rules may contain ',', e.g. *.{exe,bat};*.sh)"`
+	Log  string   `zx:"log" help:"[O] also write the log to this file, rotated daily"`
+	Keep int      `zx:"keep" default:"7" help:"[O] how many rotated log files to keep" validate:"min=0"`
+}
+
 var argHome string
+var uploadDeny *zxmatch.RuleSet
 
 func main() {
-	var (
-		argHelp bool
-		argHost string
-		argPort int
-	)
-	flag.BoolVar(&argHelp, "help", false, "[M] show this help.")
-	flag.IntVar(&argPort, "port", 9999, "[M] port")
-	flag.StringVar(&argHost, "host", "localhost", "[M] host")
-	flag.StringVar(&argHome, "home", ".", "[M] home directory")
-	flag.Parse()
+	cfg := new(serverConfig)
+	loader := zxconfig.NewLoader("httpFileServer")
+	loader.EnvPrefix = "HTTP_FILE_SERVER"
+	loader.Separator = ";" //-deny的规则里面可能有",", 比如"*.{exe,bat}"
+	err := loader.Load(cfg)
 
 	for range "1" {
-		if argHelp {
-			flag.Usage()
+		if err == flag.ErrHelp || cfg.Help {
+			fmt.Print(loader.Help(cfg))
+			break
+		}
+		if err != nil {
+			log.Println(err)
 			break
 		}
-		if argPort <= 0 || 65535 < argPort {
-			log.Printf("illegal port (%v)", argPort)
+		if err = zxvalid.Validate(cfg); err != nil {
+			log.Println(err)
 			break
 		}
 
+		if len(cfg.Log) != 0 {
+			logWriter, err := file.NewRotateWriter(cfg.Log, &file.RotateOptions{TimeLayout: "2006-01-02", MaxBackups: cfg.Keep, Compress: true,
+				OnError: func(err error) { log.Println(err) }})
+			if err != nil {
+				log.Println(err)
+				break
+			}
+			defer logWriter.Close()
+			log.SetOutput(io.MultiWriter(os.Stderr, logWriter))
+		}
+
+		if uploadDeny, err = zxmatch.NewRuleSet(cfg.Deny...); err != nil {
+			log.Println(err)
+			break
+		}
+
+		argHome = cfg.Home
 		log.Printf("argHome: [%v]", argHome)
-		addr := fmt.Sprintf("%s:%d", argHost, argPort)
+		addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
 
 		http.Handle("/", http.FileServer(http.Dir(argHome)))
 		http.HandleFunc("/upload", pageUpload)
@@ -145,24 +177,37 @@ func funcUpload(writer http.ResponseWriter, request *http.Request) {
 		defer multipartFile.Close()
 
 		message += fmt.Sprintf("<br>filename: [%v]\n", multipartFileHeader.Filename)
+		if uploadDeny.Ignored(filepath.Base(multipartFileHeader.Filename), false) {
+			err = errors.New("the filename is denied")
+			message += fmt.Sprintf("<br>error_message: [%v]\n", err)
+			log.Println(err)
+			break
+		}
 		svrFilename := multipartFileHeader.Filename
 		filenameWithTimestamp := strings.ToLower(request.FormValue("timestamp")) == "on" //"checkbox"(复选框)被选中为"on"
 		message += fmt.Sprintf("<br>timestamp: [%v]\n", request.FormValue("timestamp"))
 		if filenameWithTimestamp {
 			ext := path.Ext(svrFilename) //获取文件后缀
 			svrFilename = svrFilename + "." + string(time.Now().Format("20060102_150405")) + ext
-			svrFilename = filepath.Join(dirnameValue, svrFilename)
 		}
+		svrFilename = filepath.Join(dirnameValue, filepath.Base(svrFilename))
 
-		f, err := os.OpenFile(svrFilename, os.O_WRONLY|os.O_CREATE, 0666)
+		fileWriter, err := file.NewAtomicWriter(svrFilename, nil) //上传完成之前, 不会出现写了一半的文件
 		if err != nil {
 			message += fmt.Sprintf("<br>error_message: [%v]\n", err)
 			log.Println(err)
 			break
 		}
-		defer f.Close()
+		defer fileWriter.Close()
 
-		io.Copy(f, multipartFile)
+		if _, err = io.Copy(fileWriter, multipartFile); err == nil {
+			err = fileWriter.Commit()
+		}
+		if err != nil {
+			message += fmt.Sprintf("<br>error_message: [%v]\n", err)
+			log.Println(err)
+			break
+		}
 
 		log.Println(svrFilename)
 		message += fmt.Sprintf("<br>error_message: [%v]\n", "SUCCESS")
@@ -0,0 +1,129 @@
+package zxgo
+
+/*
+通过字段的指针找到字段, 不需要 unsafe.Offsetof, 也支持嵌套和匿名(嵌入)的结构体:
+	type Base struct {
+		Id         int64
+		CreateTime time.Time `zx:"create_time"`
+	}
+	type UserData struct {
+		Base
+		Name string
+	}
+	data := new(UserData)
+	info := MustResolveField(data, &data.CreateTime)
+	//info.Path="Base.CreateTime", info.Key="create_time", info.Column="create_time"
+只能找到结构体内部的字段(不能穿过指针字段), 结果按类型缓存.
+*/
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+type FieldInfo struct {
+	Path   string //Go语言的字段路径, 包含匿名字段的名字, 比如"Base.CreateTime"
+	Key    string //BindByMap使用的key(DefaultTagName), 比如"create_time", "Db.Port"
+	Column string //字段自己的tag名(没有tag时是字段名), 可以作为列名使用
+	Index  []int  //可以用于reflect.Value.FieldByIndex
+	Offset uintptr
+	Field  reflect.StructField
+}
+
+type fieldRefKey struct {
+	offset    uintptr
+	fieldType reflect.Type
+}
+
+var fieldRefCache sync.Map //map[reflect.Type]map[fieldRefKey]*FieldInfo
+
+func fieldInfosOf(t reflect.Type) map[fieldRefKey]*FieldInfo {
+	if cached, ok := fieldRefCache.Load(t); ok {
+		return cached.(map[fieldRefKey]*FieldInfo)
+	}
+	infos := make(map[fieldRefKey]*FieldInfo)
+	collectFieldInfos(t, infos)
+	actual, _ := fieldRefCache.LoadOrStore(t, infos)
+	return actual.(map[fieldRefKey]*FieldInfo)
+}
+
+// 一个待展开的结构体字段(顶层结构体的info是nil).
+type fieldRefLevel struct {
+	t          reflect.Type
+	info       *FieldInfo
+	pathPrefix string
+	keyPrefix  string
+}
+
+// 广度优先(逐层展开), 同一个地址和类型只保留层级最浅的字段.
+func collectFieldInfos(t reflect.Type, infos map[fieldRefKey]*FieldInfo) {
+	queue := []*fieldRefLevel{{t: t}}
+	for len(queue) != 0 {
+		level := queue[0]
+		queue = queue[1:]
+		var baseOffset uintptr
+		var baseIndex []int
+		if level.info != nil {
+			baseOffset, baseIndex = level.info.Offset, level.info.Index
+		}
+		for i := 0; i < level.t.NumField(); i++ {
+			structField := level.t.Field(i)
+			name, _, _ := parseFieldTag(structField, DefaultTagName)
+			info := &FieldInfo{
+				Path:   level.pathPrefix + structField.Name,
+				Key:    level.keyPrefix + name,
+				Column: name,
+				Index:  append(append([]int{}, baseIndex...), i),
+				Offset: baseOffset + structField.Offset,
+				Field:  structField,
+			}
+			key := fieldRefKey{info.Offset, structField.Type}
+			if _, ok := infos[key]; !ok {
+				infos[key] = info
+			}
+			if structField.Type.Kind() != reflect.Struct || isScalarStruct(structField.Type) {
+				continue
+			}
+			keyPrefix := info.Key + "."
+			if structField.Anonymous && structField.Tag.Get(DefaultTagName) == "" {
+				keyPrefix = level.keyPrefix //匿名结构体的字段不加前缀(同BindByMap)
+			}
+			queue = append(queue, &fieldRefLevel{structField.Type, info, info.Path + ".", keyPrefix})
+		}
+	}
+}
+
+// structPtr是结构体指针, fieldPtr是它(或它内部的结构体)的某个字段的指针.
+func ResolveField(structPtr interface{}, fieldPtr interface{}) (*FieldInfo, error) {
+	structValue := reflect.ValueOf(structPtr)
+	if structValue.Kind() != reflect.Ptr || structValue.IsNil() || structValue.Elem().Kind() != reflect.Struct {
+		return nil, errors.New(fmt.Sprintf("structPtr must be a non-nil pointer to struct, type=%T", structPtr))
+	}
+	fieldValue := reflect.ValueOf(fieldPtr)
+	if fieldValue.Kind() != reflect.Ptr || fieldValue.IsNil() {
+		return nil, errors.New(fmt.Sprintf("fieldPtr must be a non-nil pointer, type=%T", fieldPtr))
+	}
+
+	structType := structValue.Elem().Type()
+	begin := structValue.Pointer()
+	addr := fieldValue.Pointer()
+	//字段都在[begin, begin+Size)里面, 只有空结构体(Size为0)的字段地址等于begin+Size.
+	if addr < begin || (begin+structType.Size() <= addr && !(structType.Size() == 0 && addr == begin)) {
+		return nil, errors.New(fmt.Sprintf("fieldPtr is not inside structPtr, type=%v", structType))
+	}
+	info, ok := fieldInfosOf(structType)[fieldRefKey{addr - begin, fieldValue.Type().Elem()}]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("No fields found to match, type=%v, offset=%v", structType, addr-begin))
+	}
+	return info, nil
+}
+
+// 同ResolveField, 出错时panic.
+func MustResolveField(structPtr interface{}, fieldPtr interface{}) *FieldInfo {
+	info, err := ResolveField(structPtr, fieldPtr)
+	if err != nil {
+		panic(err)
+	}
+	return info
+}
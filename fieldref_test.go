@@ -0,0 +1,124 @@
+package zxgo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+type refBase struct {
+	Id         int64
+	CreateTime time.Time `zx:"create_time"`
+}
+
+type refDb struct {
+	Host string
+	Port uint16
+}
+
+type refData struct {
+	refBase
+	Name string `zx:"name"`
+	Db   refDb  `zx:"db"`
+	Ptr  *refDb
+}
+
+func TestResolveField(t *testing.T) {
+	data := new(refData)
+	cases := []struct {
+		fieldPtr interface{}
+		path     string
+		key      string
+		column   string
+		index    []int
+	}{
+		{&data.refBase, "refBase", "refBase", "refBase", []int{0}},
+		{&data.Id, "refBase.Id", "Id", "Id", []int{0, 0}},
+		{&data.CreateTime, "refBase.CreateTime", "create_time", "create_time", []int{0, 1}},
+		{&data.Name, "Name", "name", "name", []int{1}},
+		{&data.Db, "Db", "db", "db", []int{2}},
+		{&data.Db.Host, "Db.Host", "db.Host", "Host", []int{2, 0}},
+		{&data.Db.Port, "Db.Port", "db.Port", "Port", []int{2, 1}},
+		{&data.Ptr, "Ptr", "Ptr", "Ptr", []int{3}},
+	}
+	for _, c := range cases {
+		info, err := ResolveField(data, c.fieldPtr)
+		if err != nil {
+			t.Errorf("%v: %v", c.path, err)
+			continue
+		}
+		if info.Path != c.path || info.Key != c.key || info.Column != c.column || !reflect.DeepEqual(info.Index, c.index) {
+			t.Errorf("got %+v, want path=%v, key=%v, column=%v, index=%v", info, c.path, c.key, c.column, c.index)
+		}
+		if reflect.ValueOf(data).Elem().FieldByIndex(info.Index).Addr().Pointer() != reflect.ValueOf(c.fieldPtr).Pointer() {
+			t.Errorf("%v: Index does not point to the field", c.path)
+		}
+	}
+	//同一个类型的另一个值, 结果来自缓存
+	other := new(refData)
+	if info := MustResolveField(other, &other.Db.Port); info.Path != "Db.Port" {
+		t.Fatalf("info=%+v", info)
+	}
+}
+
+func TestResolveFieldErrors(t *testing.T) {
+	data := new(refData)
+	var nilData *refData
+	pair := new([2]refData)
+	db := new(refDb)
+	cases := []struct {
+		name      string
+		structPtr interface{}
+		fieldPtr  interface{}
+		err       string
+	}{
+		{"not pointer", *data, &data.Id, "structPtr must be"},
+		{"nil struct", nilData, &data.Id, "structPtr must be"},
+		{"not struct", &data.Id, &data.Id, "structPtr must be"},
+		{"not pointer field", data, data.Id, "fieldPtr must be"},
+		{"outside", data, &db.Host, "not inside"},
+		//紧挨着结构体后面的地址(下一个元素的第一个字段)不属于这个结构体
+		{"just after", &pair[0], &pair[1].Id, "not inside"},
+		{"nil field", data, (*int32)(nil), "fieldPtr must be"},
+		//地址在结构体里面, 但是类型对不上
+		{"type mismatch", data, (*int32)(unsafe.Pointer(&data.Id)), "No fields found"},
+	}
+	for _, c := range cases {
+		_, err := ResolveField(c.structPtr, c.fieldPtr)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%v: err=%v, want %v", c.name, err, c.err)
+		}
+	}
+}
+
+type refEmpty struct{}
+
+type refDeep struct {
+	Deep struct{ Z refEmpty }
+}
+
+type refShallow struct {
+	Z refEmpty
+}
+
+// A和B都是空结构体, 偏移量都是0: 应该找到层级浅的B.Z, 而不是A里面更深的A.Deep.Z.
+type refZeroSize struct {
+	A refDeep
+	B refShallow
+}
+
+func TestResolveFieldZeroSize(t *testing.T) {
+	data := new(refZeroSize)
+	info, err := ResolveField(data, &data.B.Z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Path != "B.Z" {
+		t.Fatalf("info=%+v", info)
+	}
+	if info, err = ResolveField(data, &data.A); err != nil || info.Path != "A" {
+		t.Fatalf("info=%+v, err=%v", info, err)
+	}
+}
@@ -0,0 +1,215 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+type AtomicOptions struct {
+	Perm         os.FileMode //新文件的权限(和os.WriteFile一样受umask影响), 默认0644. 文件已经存在时沿用原来的权限
+	BackupSuffix string      //非空时, 提交前把原来的文件保存为Filename+BackupSuffix(比如".bak")
+}
+
+/*
+原子地写入文件: 内容先写入同一个目录下的临时文件, Commit时fsync并改名为Filename, 然后fsync目录.
+所以Filename要么是原来的内容, 要么是完整的新内容, 不会是写了一半的内容.
+Filename是符号链接时, 和os.WriteFile一样写入它最终指向的文件(临时文件和备份也在那个文件的目录下), 链接本身保持不变. 用法如下所示:
+
+	writer, err := file.NewAtomicWriter("output.txt", nil)
+	if err != nil {
+		return err
+	}
+	defer writer.Close() //没有Commit时, 放弃写入的内容
+	if _, err = io.Copy(writer, reader); err != nil {
+		return err
+	}
+	return writer.Commit()
+*/
+type AtomicWriter struct {
+	Filename string
+	target   string //解析符号链接之后, 实际写入的文件
+	exists   bool   //target已经存在: Commit时沿用它的权限(不受umask影响)
+	opts     AtomicOptions
+	file     *os.File
+	done     bool
+	err      error
+}
+
+func NewAtomicWriter(filename string, opts *AtomicOptions) (*AtomicWriter, error) {
+	writer := &AtomicWriter{Filename: filename}
+	if opts != nil {
+		writer.opts = *opts
+	}
+	if writer.opts.Perm == 0 {
+		writer.opts.Perm = 0644
+	}
+	target, err := resolveLink(filename)
+	if err != nil {
+		return nil, err
+	}
+	writer.target = target
+	if info, err := os.Stat(target); err == nil {
+		if info.IsDir() {
+			return nil, errors.New(fmt.Sprintf("%v is a directory", filename))
+		}
+		writer.opts.Perm, writer.exists = info.Mode().Perm(), true
+	}
+	if writer.file, err = createTemp(target, writer.opts.Perm); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// 在filename的目录下创建临时文件. 和os.CreateTemp(总是0600)不同, 权限是perm(受umask影响).
+func createTemp(filename string, perm os.FileMode) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp")
+	for try := 0; ; try++ {
+		f, err := os.OpenFile(prefix+strconv.FormatUint(uint64(rand.Uint32()), 10), os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// 解析filename的符号链接(可以是多级, 最终指向的文件可以不存在), filename不是符号链接时原样返回.
+func resolveLink(filename string) (string, error) {
+	for hops := 0; hops < 255; hops++ {
+		info, err := os.Lstat(filename)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return filename, nil
+		}
+		link, err := os.Readlink(filename)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(filename), link)
+		}
+		filename = link
+	}
+	return "", errors.New(fmt.Sprintf("too many levels of symbolic links, filename=%v", filename))
+}
+
+func (self *AtomicWriter) Write(p []byte) (n int, err error) {
+	if self.done {
+		return 0, os.ErrClosed
+	}
+	n, err = self.file.Write(p)
+	if err != nil && self.err == nil {
+		self.err = err
+	}
+	return
+}
+
+// 提交写入的内容. 之前的Write出过错时不会提交, 而是放弃并返回那个错误.
+func (self *AtomicWriter) Commit() (err error) {
+	if self.done {
+		return os.ErrClosed
+	}
+	if self.err != nil {
+		self.Abort()
+		return self.err
+	}
+	self.done = true
+	tmpName := self.file.Name()
+	for range "1" {
+		if self.exists { //新文件在创建临时文件时已经按umask设置了权限
+			if err = self.file.Chmod(self.opts.Perm); err != nil && runtime.GOOS != "windows" {
+				break
+			}
+		}
+		if err = self.file.Sync(); err != nil {
+			break
+		}
+		if err = self.file.Close(); err != nil {
+			break
+		}
+		if len(self.opts.BackupSuffix) != 0 {
+			if err = backupFile(self.target, self.target+self.opts.BackupSuffix); err != nil {
+				break
+			}
+		}
+		if err = os.Rename(tmpName, self.target); err != nil {
+			break
+		}
+		return syncDir(filepath.Dir(self.target))
+	}
+	self.file.Close()
+	os.Remove(tmpName)
+	return err
+}
+
+// 放弃写入的内容, Filename保持不变.
+func (self *AtomicWriter) Abort() error {
+	if self.done {
+		return nil
+	}
+	self.done = true
+	self.file.Close()
+	return os.Remove(self.file.Name())
+}
+
+// 实现io.WriteCloser: 没有Commit时等同于Abort, 所以可以放心地defer Close().
+func (self *AtomicWriter) Close() error {
+	return self.Abort()
+}
+
+// 原子地写入整个文件(语义同os.WriteFile, 但是不会留下写了一半的文件).
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	writer, err := NewAtomicWriter(filename, &AtomicOptions{Perm: perm})
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(data); err != nil {
+		writer.Abort()
+		return err
+	}
+	return writer.Commit()
+}
+
+// 把filename保存为backup(先尝试硬链接, 不支持时复制), filename不存在时什么也不做.
+func backupFile(filename string, backup string) error {
+	if _, err := os.Lstat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if os.Link(filename, backup) == nil {
+		return nil
+	}
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	writer, err := NewAtomicWriter(backup, nil)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	if _, err = io.Copy(writer, src); err != nil {
+		return err
+	}
+	return writer.Commit()
+}
+
+// fsync目录, 使改名持久化. Windows不支持打开目录后Sync, 直接忽略.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
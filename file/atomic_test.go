@@ -0,0 +1,88 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readString(t *testing.T, filename string) string {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// 目录里除了names之外没有别的文件(临时文件都已经删除).
+func checkDirNames(t *testing.T, dir string, names ...string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	if len(got) != len(names) {
+		t.Fatalf("entries=%v, want %v", got, names)
+	}
+	for idx, name := range names {
+		if got[idx] != name {
+			t.Fatalf("entries=%v, want %v", got, names)
+		}
+	}
+}
+
+func TestAtomicWriter(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "a.txt")
+	if err := WriteFileAtomic(filename, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewAtomicWriter(filename, &AtomicOptions{BackupSuffix: ".bak"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if content := readString(t, filename); content != "old" {
+		t.Fatalf("content is changed before Commit, content=%v", content)
+	}
+	if err = writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Commit(); err != os.ErrClosed {
+		t.Fatalf("second Commit, err=%v", err)
+	}
+	if _, err = writer.Write([]byte("x")); err != os.ErrClosed {
+		t.Fatalf("Write after Commit, err=%v", err)
+	}
+	if readString(t, filename) != "new" || readString(t, filename+".bak") != "old" {
+		t.Fatal("content or backup is wrong")
+	}
+
+	//没有Commit就Close: 放弃写入的内容
+	if writer, err = NewAtomicWriter(filename, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write([]byte("aborted")); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if content := readString(t, filename); content != "new" {
+		t.Fatalf("content=%v", content)
+	}
+	checkDirNames(t, dir, "a.txt", "a.txt.bak")
+
+	if _, err = NewAtomicWriter(dir, nil); err == nil {
+		t.Fatal("directory is not rejected")
+	}
+	if _, err = NewAtomicWriter(filepath.Join(dir, "none", "a.txt"), nil); err == nil {
+		t.Fatal("missing directory is not rejected")
+	}
+}
@@ -0,0 +1,106 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// 新文件的权限和os.WriteFile一样受umask影响, 已经存在的文件沿用原来的权限.
+func TestAtomicWriterPerm(t *testing.T) {
+	old := syscall.Umask(027)
+	defer syscall.Umask(old)
+
+	dir := t.TempDir()
+	perm := func(filename string) os.FileMode {
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	atomicName, plainName := filepath.Join(dir, "atomic.txt"), filepath.Join(dir, "plain.txt")
+	if err := WriteFileAtomic(atomicName, []byte("a"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(plainName, []byte("a"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if perm(atomicName) != 0640 || perm(atomicName) != perm(plainName) {
+		t.Fatalf("perm=%v, os.WriteFile=%v", perm(atomicName), perm(plainName))
+	}
+
+	if err := os.Chmod(atomicName, 0606); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(atomicName, []byte("b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if perm(atomicName) != 0606 {
+		t.Fatalf("perm of existing file is not kept, perm=%v", perm(atomicName))
+	}
+}
+
+// Filename是符号链接时写入它指向的文件, 链接本身不变.
+func TestAtomicWriterSymlink(t *testing.T) {
+	dir := t.TempDir()
+	realDir := filepath.Join(dir, "real")
+	if err := os.Mkdir(realDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(realDir, "config.json")
+	if err := os.WriteFile(target, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link, chain := filepath.Join(dir, "config.json"), filepath.Join(dir, "chain.json")
+	if err := os.Symlink("real/config.json", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("config.json", chain); err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewAtomicWriter(chain, &AtomicOptions{BackupSuffix: ".bak"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if readString(t, target) != "new" || readString(t, target+".bak") != "old" {
+		t.Fatal("target is not written")
+	}
+	for _, name := range []string{link, chain} {
+		if info, err := os.Lstat(name); err != nil || info.Mode()&os.ModeSymlink == 0 {
+			t.Fatalf("%v is replaced, err=%v", name, err)
+		}
+	}
+	checkDirNames(t, realDir, "config.json", "config.json.bak")
+
+	//指向的文件不存在时创建它
+	dangling := filepath.Join(dir, "dangling.json")
+	if err = os.Symlink("real/new.json", dangling); err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteFileAtomic(dangling, []byte("created"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if readString(t, filepath.Join(realDir, "new.json")) != "created" {
+		t.Fatal("dangling target is not created")
+	}
+
+	loop := filepath.Join(dir, "loop")
+	if err = os.Symlink("loop", loop); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewAtomicWriter(loop, nil); err == nil {
+		t.Fatal("symlink loop is not rejected")
+	}
+}
@@ -0,0 +1,274 @@
+package file
+
+/*
+go get -u -v golang.org/x/text
+*/
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	UTF8     string = "UTF-8"
+	UTF8BOM  string = "UTF-8-BOM" //写入时带BOM
+	UTF16LE  string = "UTF-16LE"  //写入时带BOM
+	UTF16BE  string = "UTF-16BE"  //写入时带BOM
+	UTF16    string = "UTF-16"    //读取时按BOM判断字节序(没有BOM时猜测), 写入时同UTF16LE
+	GBK      string = "GBK"
+	GB18030  string = "GB18030"
+	BIG5     string = "Big5"
+	SHIFTJIS string = "Shift_JIS"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// 返回规范的编码名(不区分大小写, 支持一些常见的别名), 不认识时返回error.
+func NormalizeEncoding(name string) (string, error) {
+	switch strings.ToUpper(strings.Replace(strings.TrimSpace(name), "_", "-", -1)) {
+	case "", "UTF-8", "UTF8":
+		return UTF8, nil
+	case "UTF-8-BOM", "UTF8-BOM", "UTF-8BOM", "UTF-8-SIG":
+		return UTF8BOM, nil
+	case "UTF-16LE", "UTF16LE":
+		return UTF16LE, nil
+	case "UTF-16", "UTF16", "UNICODE":
+		return UTF16, nil
+	case "UTF-16BE", "UTF16BE":
+		return UTF16BE, nil
+	case "GBK", "CP936", "GB2312":
+		return GBK, nil
+	case "GB18030":
+		return GB18030, nil
+	case "BIG5", "BIG-5", "CP950":
+		return BIG5, nil
+	case "SHIFT-JIS", "SHIFTJIS", "SJIS", "CP932":
+		return SHIFTJIS, nil
+	}
+	return "", errors.New(fmt.Sprintf("Unknown encoding=%v", name))
+}
+
+// 返回编码对应的encoding.Encoding和写入时使用的BOM(没有BOM时为nil).
+func getEncoding(name string) (enc encoding.Encoding, bom []byte, err error) {
+	if name, err = NormalizeEncoding(name); err != nil {
+		return
+	}
+	switch name {
+	case UTF8:
+		enc = unicode.UTF8
+	case UTF8BOM:
+		enc, bom = unicode.UTF8, bomUTF8
+	case UTF16LE, UTF16:
+		enc, bom = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), bomUTF16LE
+	case UTF16BE:
+		enc, bom = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), bomUTF16BE
+	case GBK:
+		enc = simplifiedchinese.GBK
+	case GB18030:
+		enc = simplifiedchinese.GB18030
+	case BIG5:
+		enc = traditionalchinese.Big5
+	case SHIFTJIS:
+		enc = japanese.ShiftJIS
+	}
+	return
+}
+
+// 根据BOM判断编码, 没有BOM时返回("", 0).
+func DetectBOM(data []byte) (name string, bomLen int) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return UTF8BOM, len(bomUTF8)
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return UTF16LE, len(bomUTF16LE)
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return UTF16BE, len(bomUTF16BE)
+	}
+	return "", 0
+}
+
+/*
+猜测data的编码(只是启发式的猜测, 内容越长越准确):
+ 1. 有BOM时按BOM判断.
+ 2. 偶数(奇数)位置的字节大多是0时, 认为是UTF-16BE(UTF-16LE).
+ 3. 是合法的UTF-8时, 认为是UTF-8.
+ 4. 依次尝试GBK,Big5,Shift_JIS, 返回解码错误最少的那个(相同时靠前的优先, 所以偏向GBK).
+*/
+func SniffEncoding(data []byte) string {
+	if name, _ := DetectBOM(data); len(name) != 0 {
+		return name
+	}
+
+	if 2 <= len(data) {
+		evenZero, oddZero := 0, 0
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 {
+				evenZero++
+			}
+			if data[i+1] == 0 {
+				oddZero++
+			}
+		}
+		pairs := len(data) / 2
+		if pairs < oddZero*10/3 && evenZero*10 < pairs { //30%以上的奇数位置是0, 并且偶数位置很少是0
+			return UTF16LE
+		}
+		if pairs < evenZero*10/3 && oddZero*10 < pairs {
+			return UTF16BE
+		}
+	}
+
+	if utf8.Valid(data) {
+		return UTF8
+	}
+
+	best, bestErrors := GBK, -1
+	for _, name := range []string{GBK, BIG5, SHIFTJIS} {
+		enc, _, _ := getEncoding(name)
+		decoded, err := enc.NewDecoder().Bytes(data)
+		if err != nil {
+			continue
+		}
+		errorCount := bytes.Count(decoded, []byte("\uFFFD"))
+		if bestErrors < 0 || errorCount < bestErrors {
+			best, bestErrors = name, errorCount
+		}
+	}
+	return best
+}
+
+// 把data按编码name解码成UTF-8, name为空时自动判断, 开头的BOM会被去掉.
+// name为UTF16时按BOM决定字节序; 其他的name和BOM表示的编码不一致时返回error.
+func DecodeBytes(data []byte, name string) (string, error) {
+	bomName, bomLen := DetectBOM(data)
+	if len(name) == 0 {
+		name = SniffEncoding(data)
+	}
+	normalized, err := NormalizeEncoding(name)
+	if err != nil {
+		return "", err
+	}
+	if normalized == UTF16 {
+		switch {
+		case bomName == UTF16LE || bomName == UTF16BE:
+			normalized = bomName
+		case len(bomName) == 0 && SniffEncoding(data) == UTF16BE:
+			normalized = UTF16BE
+		default:
+			normalized = UTF16LE
+		}
+	}
+	if len(bomName) != 0 {
+		if bomName != normalized && !(bomName == UTF8BOM && normalized == UTF8) {
+			return "", errors.New(fmt.Sprintf("encoding=%v conflicts with the BOM of %v", name, bomName))
+		}
+		data = data[bomLen:]
+	}
+	enc, _, err := getEncoding(normalized)
+	if err != nil {
+		return "", err
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// 把UTF-8的content按编码name编码(不加BOM). 编码中不存在的字符会返回error.
+func EncodeString(content string, name string) ([]byte, error) {
+	enc, _, err := getEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewEncoder().Bytes([]byte(content))
+}
+
+// 读取文件的所有行(去掉行尾的"\r\n"或"\n"), encodingName为空时自动判断编码.
+func ReadLines(path string, encodingName string) (lines []string, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+	var content string
+	if content, err = DecodeBytes(data, encodingName); err != nil {
+		err = errors.New(fmt.Sprintf("%v: %v", path, err))
+		return
+	}
+	if len(content) == 0 {
+		return []string{}, nil
+	}
+	content = strings.TrimSuffix(content, "\n")
+	lines = strings.Split(content, "\n")
+	for idx := range lines {
+		lines[idx] = strings.TrimSuffix(lines[idx], "\r")
+	}
+	return
+}
+
+func encodeLines(contents []string, encodingName string) ([]byte, []byte, error) {
+	_, bom, err := getEncoding(encodingName)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buffer bytes.Buffer
+	for _, content := range contents {
+		buffer.WriteString(content)
+		buffer.WriteString("\n")
+	}
+	data, err := EncodeString(buffer.String(), encodingName)
+	return data, bom, err
+}
+
+// 用指定的编码写入所有的行(每行后面加"\n"), 文件已经存在时(原子地)覆盖它.
+func WriteAllLines(path string, contents []string, encodingName string) error {
+	data, bom, err := encodeLines(contents, encodingName)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, append(append([]byte{}, bom...), data...), 0644)
+}
+
+// 用指定的编码追加所有的行(每行后面加"\n"). 文件不存在(或者为空)时, 先写入编码的BOM(如果有).
+func AppendAllLinesEncoding(path string, contents []string, encodingName string) error {
+	data, bom, err := encodeLines(contents, encodingName)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	if 0 < len(bom) {
+		if info, err1 := file.Stat(); err1 != nil {
+			err = err1
+		} else if info.Size() == 0 {
+			data = append(append([]byte{}, bom...), data...)
+		}
+	}
+	if err == nil {
+		var n int
+		if n, err = file.Write(data); err == nil && n < len(data) {
+			err = io.ErrShortWrite
+		}
+	}
+	if err1 := file.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
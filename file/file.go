@@ -1,20 +1,9 @@
 package file
 
-/*
-go get -u -v golang.org/x/text/transform
-*/
 import (
 	"bufio"
-	"bytes"
-	"errors"
-	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
-
-	"golang.org/x/text/encoding"
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/transform"
 )
 
 func AppendLine(path string, content string, panicWhenError bool) error {
@@ -24,71 +13,18 @@ func AppendLine(path string, content string, panicWhenError bool) error {
 
 // 模仿了C#的[System.IO.File.AppendAllLines("path", new string[] { })]函数的行为.
 // 参考了ioutil.WriteFile("path", nil, os.ModeAppend)函数.
+// 所有的行在排他锁(见FileLock)的保护下一次性写入, 多个进程同时追加同一个文件时, 行不会交错.
 func AppendAllLines(path string, contents []string, panicWhenError bool) error {
-
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		if panicWhenError {
-			panic(err)
-		}
-		return err
-	}
-
-	for _, content := range contents {
-
-		data := []byte(content + "\n")
-
-		if n, err1 := file.Write(data); err1 != nil {
-			err = err1
-			break
-		} else if n < len(data) {
-			err = io.ErrShortWrite
-			break
-		}
-	}
-
-	if err1 := file.Close(); err == nil {
-		err = err1
-	}
-
+	err := AppendAllLinesLocked(path, contents)
 	if err != nil && panicWhenError {
 		panic(err)
 	}
 	return err
 }
 
+// 为了兼容而保留, 请使用AppendAllLinesEncoding.
 func AppendAllLines_bak(path string, contents []string, encodingType string) error {
-	/* 我建议使用golang.org/x/text/encoding的标准软件包，可能还会使用golang.org/x/net/charset */
-	calcEncoding := func(eType string) encoding.Encoding {
-		if eType == "GBK" {
-			return simplifiedchinese.GBK
-		} else if eType == "GB18030" {
-			return simplifiedchinese.GB18030
-		} else {
-			return nil
-		}
-	}
-
-	eInterface := calcEncoding(encodingType)
-	if eInterface == nil {
-		return errors.New(fmt.Sprintf("Unknown encodingType=%v", encodingType))
-	}
-
-	allData := make([][]byte, 0)
-	for _, content := range contents {
-		content += "\n"
-		if data, err := ioutil.ReadAll(transform.NewReader(bytes.NewReader([]byte(content)), eInterface.NewDecoder())); err != nil {
-			return errors.New(fmt.Sprintf("convert encoding fail."))
-		} else {
-			allData = append(allData, data)
-		}
-	}
-
-	for _, data := range allData {
-		ioutil.WriteFile(path, data, os.ModeAppend)
-	}
-
-	return nil
+	return AppendAllLinesEncoding(path, contents, encodingType)
 }
 
 type iterator_reader struct { //内部使用的类,不能被外部创建.
@@ -102,6 +38,12 @@ func (self *iterator_reader) Next() (line string, err error, first bool, last bo
 		err = io.EOF
 		return
 	}
+	defer func() {
+		if (err != nil || last) && self.f != nil { //读完(或者出错)时关闭文件
+			self.f.Close()
+			self.f = nil
+		}
+	}()
 
 	first = false
 	line, err = self.r.ReadString('\n')
@@ -128,6 +70,7 @@ func (self *iterator_reader) init(filename string) (line string, err error, firs
 	return
 }
 
+// 请使用LineReader(它支持"\r"换行, 行的长度限制和range).
 // 函数用法如下所示:
 //	var eOut string
 //	for line, err, first, last, iter := file.ReadLine("D:/_a.txt", &eOut); err == nil; line, err, first, last = iter.Next() {
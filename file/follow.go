@@ -0,0 +1,309 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FollowFromStart  string = "START"  //从文件头开始
+	FollowFromEnd    string = "END"    //从文件尾开始, 只读取新写入的行
+	FollowFromOffset string = "OFFSET" //从FollowOptions.Offset开始
+)
+
+type FollowOptions struct {
+	From         string        //FollowFromStart(默认)/FollowFromEnd/FollowFromOffset
+	Offset       int64         //From为FollowFromOffset时有效
+	OffsetFile   string        //保存读取位置的文件, 非空时启动时从这里恢复(优先于From), 以便重启后继续读取
+	PollInterval time.Duration //没有新内容时, 隔多久检查一次, 默认250ms
+}
+
+// 读取到的一行. Offset是这一行之后的位置(从这里继续读取就是下一行).
+type FollowLine struct {
+	Text   string //不含换行符
+	Offset int64
+}
+
+/*
+类似"tail -F"的跟随读取, 用法如下所示:
+
+	follower := file.NewFollower("app.log", &file.FollowOptions{From: file.FollowFromEnd, OffsetFile: "app.log.offset"})
+	err := follower.Run(ctx, func(line *file.FollowLine) error {
+		fmt.Println(line.Text)
+		return nil
+	})
+
+能够处理日志的轮转: 文件被改名后重新创建(读完旧文件的剩余内容后切换到新文件), 以及copytruncate(文件变小时从头开始读取).
+文件不存在时会一直等待, 直到它被创建.
+*/
+type Follower struct {
+	Filename string
+	opts     FollowOptions
+	file     *os.File
+	info     os.FileInfo
+	reader   *bufio.Reader
+	offset   int64 //已经输出的完整行之后的位置
+	pending  []byte
+	saved    int64
+	err      error
+}
+
+func NewFollower(filename string, opts *FollowOptions) *Follower {
+	follower := &Follower{Filename: filename, saved: -1}
+	if opts != nil {
+		follower.opts = *opts
+	}
+	if follower.opts.PollInterval <= 0 {
+		follower.opts.PollInterval = 250 * time.Millisecond
+	}
+	return follower
+}
+
+// 当前的读取位置.
+func (self *Follower) Offset() int64 {
+	return self.offset
+}
+
+// 一直读取, 每读到一行就调用fn, 直到ctx被取消(返回nil)或者出错/fn返回error(返回这个error).
+// 返回之前会保存读取位置(如果设置了OffsetFile).
+func (self *Follower) Run(ctx context.Context, fn func(line *FollowLine) error) (err error) {
+	defer func() {
+		if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			err = nil
+		}
+		if self.file != nil {
+			self.file.Close()
+			self.file = nil
+		}
+		if err1 := self.saveOffset(); err == nil {
+			err = err1
+		}
+	}()
+
+	var offset int64
+	if offset, err = self.startOffset(); err != nil {
+		return
+	}
+	if err = self.waitOpen(ctx, offset); err != nil {
+		return
+	}
+
+	for {
+		if err = self.readLines(ctx, fn); err != nil {
+			return
+		}
+		if err = self.saveOffset(); err != nil {
+			return
+		}
+		var rotated bool
+		if rotated, err = self.checkRotate(); err != nil {
+			return
+		}
+		if rotated {
+			if err = self.readLines(ctx, fn); err != nil { //读完旧文件的剩余内容
+				return
+			}
+			if 0 < len(self.pending) { //旧文件不会再有新内容了, 最后一行没有换行符也要输出
+				text := string(bytes.TrimRight(self.pending, "\r"))
+				self.pending = self.pending[:0]
+				if err = self.emit(ctx, fn, text); err != nil {
+					return
+				}
+			}
+			self.file.Close()
+			self.file = nil
+			self.offset, self.pending = 0, nil //新文件的全部内容都是新的, 它也可能又被删除了
+			if err = self.waitOpen(ctx, 0); err != nil {
+				return
+			}
+			continue
+		}
+		if !self.sleep(ctx) {
+			return nil
+		}
+	}
+}
+
+// 在新的goroutine中读取, 通过channel输出, ctx被取消或者出错时关闭channel(出错时Err返回这个错误).
+func (self *Follower) Lines(ctx context.Context) <-chan *FollowLine {
+	ch := make(chan *FollowLine)
+	go func() {
+		defer close(ch)
+		self.err = self.Run(ctx, func(line *FollowLine) error {
+			select {
+			case ch <- line:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return ch
+}
+
+// Lines的channel关闭之后, 返回导致它关闭的错误(ctx被取消时为nil).
+func (self *Follower) Err() error {
+	return self.err
+}
+
+func (self *Follower) sleep(ctx context.Context) bool {
+	timer := time.NewTimer(self.opts.PollInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (self *Follower) startOffset() (int64, error) {
+	if len(self.opts.OffsetFile) != 0 {
+		content, err := os.ReadFile(self.opts.OffsetFile)
+		if err == nil {
+			offset, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+			if err != nil {
+				return 0, errors.New(fmt.Sprintf("%v: %v", self.opts.OffsetFile, err))
+			}
+			self.saved = offset
+			return offset, nil
+		}
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	switch self.opts.From {
+	case "", FollowFromStart:
+		return 0, nil
+	case FollowFromEnd:
+		return -1, nil
+	case FollowFromOffset:
+		return self.opts.Offset, nil
+	}
+	return 0, errors.New(fmt.Sprintf("unknown From=%v", self.opts.From))
+}
+
+// 打开文件并定位到offset(-1表示文件尾, 超过文件大小时从头开始). 文件不存在时self.file为nil.
+func (self *Follower) open(offset int64) error {
+	f, err := os.Open(self.Filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if offset < 0 {
+		offset = info.Size()
+	} else if info.Size() < offset { //保存的位置已经失效(文件被轮转或者截断了)
+		offset = 0
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	self.file, self.info, self.offset, self.pending = f, info, offset, nil
+	self.reader = bufio.NewReader(f)
+	return nil
+}
+
+// 打开文件, 不存在时一直等待它被创建(ctx被取消时返回ctx.Err()).
+func (self *Follower) waitOpen(ctx context.Context, offset int64) error {
+	for {
+		if err := self.open(offset); err != nil || self.file != nil {
+			return err
+		}
+		if !self.sleep(ctx) {
+			return ctx.Err()
+		}
+		offset = 0 //文件是后来创建的, 它的全部内容都是新的
+	}
+}
+
+// 读取到文件尾, 最后不完整的一行留在pending里面, 等待它的换行符.
+func (self *Follower) readLines(ctx context.Context, fn func(line *FollowLine) error) error {
+	for {
+		chunk, err := self.reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			self.pending = append(self.pending, chunk...)
+			continue
+		}
+		if err == io.EOF {
+			self.pending = append(self.pending, chunk...)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line := chunk
+		if 0 < len(self.pending) {
+			line = append(self.pending, chunk...)
+		}
+		self.pending = self.pending[:0]
+		if err = self.emit(ctx, fn, string(bytes.TrimRight(line, "\r\n"))); err != nil {
+			return err
+		}
+	}
+}
+
+// fn成功之后才更新读取位置, 以免保存的位置跳过了没有输出的行.
+func (self *Follower) emit(ctx context.Context, fn func(line *FollowLine) error, text string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	offset, err := self.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	offset -= int64(self.reader.Buffered() + len(self.pending))
+	if err = fn(&FollowLine{Text: text, Offset: offset}); err != nil {
+		return err
+	}
+	self.offset = offset
+	return nil
+}
+
+// 检查文件是否被轮转. 文件被截断(copytruncate)时, 直接从头开始读取, 返回false.
+func (self *Follower) checkRotate() (rotated bool, err error) {
+	info, err := os.Stat(self.Filename)
+	if err != nil {
+		if os.IsNotExist(err) { //已经改名, 新文件还没有创建, 继续读旧文件
+			return false, nil
+		}
+		return false, err
+	}
+	if !os.SameFile(self.info, info) {
+		return true, nil
+	}
+	if info.Size() < self.offset+int64(len(self.pending)) {
+		if _, err = self.file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		self.reader.Reset(self.file)
+		self.offset, self.pending = 0, self.pending[:0]
+	}
+	return false, nil
+}
+
+func (self *Follower) saveOffset() error {
+	if len(self.opts.OffsetFile) == 0 || self.offset == self.saved {
+		return nil
+	}
+	if err := WriteFileAtomic(self.opts.OffsetFile, []byte(strconv.FormatInt(self.offset, 10)), 0644); err != nil {
+		return err
+	}
+	self.saved = self.offset
+	return nil
+}
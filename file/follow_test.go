@@ -0,0 +1,120 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func appendFile(t *testing.T, filename string, content string) {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectLines(t *testing.T, ch <-chan *FollowLine, texts ...string) {
+	t.Helper()
+	for _, text := range texts {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				t.Fatalf("channel closed, want %q", text)
+			}
+			if line.Text != text {
+				t.Fatalf("got %q, want %q", line.Text, text)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout, want %q", text)
+		}
+	}
+}
+
+// 在新的goroutine中跟随读取, stop取消并等待它结束(以免在TempDir被删除之后还在写OffsetFile).
+func followLines(follower *Follower) (ch <-chan *FollowLine, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := follower.Lines(ctx)
+	return lines, func() {
+		cancel()
+		for range lines {
+		}
+	}
+}
+
+func TestFollowerTruncate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, filename, "a\nbb\n")
+
+	ch, stop := followLines(NewFollower(filename, &FollowOptions{PollInterval: 10 * time.Millisecond}))
+	defer stop()
+	expectLines(t, ch, "a", "bb")
+
+	if err := os.Truncate(filename, 0); err != nil { //copytruncate
+		t.Fatal(err)
+	}
+	appendFile(t, filename, "c\n")
+	expectLines(t, ch, "c")
+}
+
+func TestFollowerRotate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, filename, "a\n")
+
+	ch, stop := followLines(NewFollower(filename, &FollowOptions{PollInterval: 10 * time.Millisecond}))
+	defer stop()
+	expectLines(t, ch, "a")
+
+	appendFile(t, filename, "b\nlast") //最后一行没有换行符
+	if err := os.Rename(filename, filename+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, filename, "c\n")
+	expectLines(t, ch, "b", "last", "c")
+}
+
+func TestFollowerOffsetResume(t *testing.T) {
+	dir := t.TempDir()
+	filename, offsetFile := filepath.Join(dir, "app.log"), filepath.Join(dir, "app.log.offset")
+	appendFile(t, filename, "a\nb\n")
+	opts := &FollowOptions{OffsetFile: offsetFile, PollInterval: 10 * time.Millisecond}
+
+	//fn失败的行没有输出, 保存的位置不能跳过它
+	errStop := errors.New("stop")
+	err := NewFollower(filename, opts).Run(context.Background(), func(line *FollowLine) error {
+		if line.Text == "b" {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("err=%v", err)
+	}
+	if content, _ := os.ReadFile(offsetFile); strings.TrimSpace(string(content)) != "2" {
+		t.Fatalf("offset file=%q", content)
+	}
+
+	appendFile(t, filename, "c\n")
+	follower := NewFollower(filename, opts)
+	ch, stop := followLines(follower)
+	expectLines(t, ch, "b", "c")
+	stop()
+	if follower.Err() != nil {
+		t.Fatal(follower.Err())
+	}
+	if content, _ := os.ReadFile(offsetFile); strings.TrimSpace(string(content)) != "6" {
+		t.Fatalf("offset file=%q", content)
+	}
+
+	appendFile(t, filename, "d\n")
+	ch, stop = followLines(NewFollower(filename, opts))
+	defer stop()
+	expectLines(t, ch, "d")
+}
@@ -0,0 +1,133 @@
+package file
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"iter"
+	"os"
+)
+
+var ErrLineTooLong = errors.New("line too long")
+
+/*
+逐行读取, "\r\n", "\n", "\r"都被认为是换行符, Text()不含换行符. 用法如下所示:
+
+	reader, err := file.OpenLineReader("D:/_a.txt")
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	for reader.Next() {
+		fmt.Println(reader.LineNumber(), reader.Text())
+	}
+	return reader.Err()
+
+或者:
+
+	for lineNum, line := range reader.All() {
+		fmt.Println(lineNum, line)
+	}
+*/
+type LineReader struct {
+	MaxLineLength int //一行最多多少字节(不含换行符), 超过时Next返回false并且Err返回ErrLineTooLong. 0表示不限制
+	reader        *bufio.Reader
+	closer        io.Closer
+	buffer        []byte
+	lineNum       int
+	err           error
+	done          bool
+}
+
+func NewLineReader(reader io.Reader) *LineReader {
+	lineReader := &LineReader{reader: bufio.NewReader(reader)}
+	if closer, ok := reader.(io.Closer); ok {
+		lineReader.closer = closer
+	}
+	return lineReader
+}
+
+func OpenLineReader(filename string) (*LineReader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewLineReader(f), nil
+}
+
+// 读取下一行, 没有更多的行或者出错时返回false(出错时Err返回这个错误).
+func (self *LineReader) Next() bool {
+	if self.done {
+		return false
+	}
+	self.buffer = self.buffer[:0]
+	for {
+		c, err := self.reader.ReadByte()
+		if err != nil {
+			self.done = true
+			if err != io.EOF {
+				self.err = err
+				return false
+			}
+			if len(self.buffer) == 0 { //文件为空, 或者以换行符结尾
+				return false
+			}
+			break
+		}
+		if c == '\n' {
+			break
+		}
+		if c == '\r' {
+			if next, err := self.reader.Peek(1); err == nil && next[0] == '\n' {
+				self.reader.ReadByte()
+			}
+			break
+		}
+		if 0 < self.MaxLineLength && self.MaxLineLength <= len(self.buffer) {
+			self.done = true
+			self.lineNum++
+			self.err = ErrLineTooLong
+			return false
+		}
+		self.buffer = append(self.buffer, c)
+	}
+	self.lineNum++
+	return true
+}
+
+// 当前行的内容(不含换行符).
+func (self *LineReader) Text() string {
+	return string(self.buffer)
+}
+
+// 当前行的行号, 从1开始. 出错(比如ErrLineTooLong)时是出错的那一行的行号.
+func (self *LineReader) LineNumber() int {
+	return self.lineNum
+}
+
+// 第一个非io.EOF的错误.
+func (self *LineReader) Err() error {
+	return self.err
+}
+
+// 关闭底层的reader(如果它实现了io.Closer), 可以多次调用.
+func (self *LineReader) Close() error {
+	self.done = true
+	if self.closer == nil {
+		return nil
+	}
+	closer := self.closer
+	self.closer = nil
+	return closer.Close()
+}
+
+// 用于range的迭代器, 依次得到(行号, 行的内容). 结束后请检查Err().
+func (self *LineReader) All() iter.Seq2[int, string] {
+	return func(yield func(int, string) bool) {
+		for self.Next() {
+			if !yield(self.lineNum, self.Text()) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LockShared    string = "SHARED"    //共享锁(读锁), 可以有多个持有者
+	LockExclusive string = "EXCLUSIVE" //排他锁(写锁)
+)
+
+var (
+	ErrLockTimeout     = errors.New("lock timeout")
+	ErrLockUnsupported = errors.New("file lock is not supported on this platform")
+	errWouldBlock      = errors.New("lock would block")
+)
+
+/*
+跨进程的建议锁(Linux等使用flock, Windows使用LockFileEx). 只对同样加锁的进程有效, 用法如下所示:
+
+	lock := file.NewFileLock("data.lock")
+	if err := lock.Lock(file.LockExclusive); err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+进程退出(包括崩溃)时, 操作系统会自动释放它持有的锁.
+*/
+type FileLock struct {
+	Filename string
+	file     *os.File
+	mode     string
+}
+
+func NewFileLock(filename string) *FileLock {
+	return &FileLock{Filename: filename}
+}
+
+func checkLockMode(mode string) error {
+	if mode != LockShared && mode != LockExclusive {
+		return errors.New(fmt.Sprintf("unknown lock mode=%v", mode))
+	}
+	return nil
+}
+
+func (self *FileLock) open() error {
+	if self.file != nil {
+		return nil
+	}
+	f, err := os.OpenFile(self.Filename, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	self.file = f
+	return nil
+}
+
+func (self *FileLock) lock(mode string, block bool) error {
+	if err := checkLockMode(mode); err != nil {
+		return err
+	}
+	if err := self.open(); err != nil {
+		return err
+	}
+	if err := lockFile(self.file, mode == LockExclusive, block); err != nil {
+		if len(self.mode) == 0 {
+			self.file.Close()
+			self.file = nil
+		}
+		return err
+	}
+	self.mode = mode
+	return nil
+}
+
+// 阻塞直到得到锁. 已经持有锁时, 转换成mode(转换不是原子的).
+func (self *FileLock) Lock(mode string) error {
+	return self.lock(mode, true)
+}
+
+// 不阻塞, 锁被其他进程持有时返回(false, nil).
+func (self *FileLock) TryLock(mode string) (bool, error) {
+	err := self.lock(mode, false)
+	if err == errWouldBlock {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// 最多等待timeout, 超时返回ErrLockTimeout.
+func (self *FileLock) LockTimeout(mode string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	interval := 5 * time.Millisecond
+	for {
+		locked, err := self.TryLock(mode)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return nil
+		}
+		remain := time.Until(deadline)
+		if remain <= 0 {
+			return ErrLockTimeout
+		}
+		time.Sleep(min(interval, remain))
+		if interval < 100*time.Millisecond {
+			interval *= 2
+		}
+	}
+}
+
+// 当前持有的锁的模式, 没有持有锁时为空.
+func (self *FileLock) Mode() string {
+	return self.mode
+}
+
+// 释放锁并关闭文件(锁文件不会被删除, 删除它会让其他等待的进程锁住一个已经不存在的文件).
+func (self *FileLock) Unlock() error {
+	if self.file == nil {
+		return nil
+	}
+	err := unlockFile(self.file)
+	if err1 := self.file.Close(); err == nil {
+		err = err1
+	}
+	self.file, self.mode = nil, ""
+	return err
+}
+
+// 在排他锁的保护下把所有的行一次性追加到文件, 多个进程同时追加时, 行不会交错.
+func AppendAllLinesLocked(path string, contents []string) error {
+	var builder strings.Builder
+	for _, content := range contents {
+		builder.WriteString(content)
+		builder.WriteString("\n")
+	}
+	return appendLocked(path, []byte(builder.String()))
+}
+
+func AppendLineLocked(path string, content string) error {
+	return AppendAllLinesLocked(path, []string{content})
+}
+
+// 不支持文件锁的平台上, 不加锁直接追加.
+func appendLocked(path string, data []byte) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err1 := f.Close(); err == nil {
+			err = err1
+		}
+	}()
+	if err = lockFile(f, true, true); err != nil && err != ErrLockUnsupported {
+		return err
+	}
+	locked := err == nil
+	var n int
+	if n, err = f.Write(data); err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if locked {
+		if err1 := unlockFile(f); err == nil {
+			err = err1
+		}
+	}
+	return err
+}
+
+/*
+用PID文件实现的互斥锁(比如保证同一时刻只有一个进程在运行): 持有排他锁, 并把自己的PID写入文件.
+持有者退出(包括崩溃)时锁被自动释放, 留下的PID文件是过期的, 会被下一个进程接管(StalePID是它的PID).
+*/
+type PIDLock struct {
+	Filename string
+	StalePID int //接管的过期PID文件中的PID, 没有时为0
+	lock     *FileLock
+}
+
+// 不阻塞地获取PID锁, 被其他进程持有时返回的error中含有它的PID.
+func TryLockPID(filename string) (*PIDLock, error) {
+	return lockPID(filename, 0)
+}
+
+// 最多等待timeout.
+func LockPID(filename string, timeout time.Duration) (*PIDLock, error) {
+	return lockPID(filename, timeout)
+}
+
+func lockPID(filename string, timeout time.Duration) (*PIDLock, error) {
+	pidLock := &PIDLock{Filename: filename, lock: NewFileLock(filename)}
+	err := pidLock.lock.LockTimeout(LockExclusive, timeout)
+	if err == ErrLockTimeout {
+		if pid, err1 := ReadPIDFile(filename); err1 == nil {
+			return nil, errors.New(fmt.Sprintf("%v is locked by pid=%v", filename, pid))
+		}
+		return nil, errors.New(fmt.Sprintf("%v is locked", filename))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := pidLock.lock.file
+	for range "1" {
+		if pid, err1 := ReadPIDFile(filename); err1 == nil && pid != os.Getpid() {
+			pidLock.StalePID = pid
+		}
+		if err = f.Truncate(0); err != nil {
+			break
+		}
+		if _, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+			break
+		}
+		err = f.Sync()
+	}
+	if err != nil {
+		pidLock.lock.Unlock()
+		return nil, err
+	}
+	return pidLock, nil
+}
+
+// 清空PID文件并释放锁.
+func (self *PIDLock) Unlock() error {
+	if self.lock.file == nil {
+		return nil
+	}
+	self.lock.file.Truncate(0)
+	return self.lock.Unlock()
+}
+
+// 读取PID文件中的PID.
+func ReadPIDFile(filename string) (int, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("%v: %v", filename, err))
+	}
+	return pid, nil
+}
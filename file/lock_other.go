@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package file
+
+import (
+	"os"
+)
+
+func lockFile(f *os.File, exclusive bool, block bool) error {
+	return ErrLockUnsupported
+}
+
+func unlockFile(f *os.File) error {
+	return ErrLockUnsupported
+}
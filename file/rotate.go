@@ -0,0 +1,337 @@
+package file
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RotateOptions struct {
+	MaxSize    int64  //文件超过多少字节时轮转, 0表示不按大小轮转
+	TimeLayout string //按时间轮转, 格式化后的时间变化时轮转, 比如"2006-01-02"每天, "2006-01-02_15"每小时. 为空表示不按时间轮转
+	MaxBackups int    //最多保留几个轮转出来的文件, 0表示全部保留
+	Compress   bool   //用gzip压缩轮转出来的文件(在后台进行)
+	//后台压缩和清理的错误(在后台的goroutine里调用). 为nil时, 第一个错误由Close返回.
+	OnError func(err error)
+}
+
+/*
+可以轮转的日志文件, 实现了io.WriteCloser, 可以并发使用. 例如和log一起使用:
+
+	writer, err := file.NewRotateWriter("app.log", &file.RotateOptions{TimeLayout: "2006-01-02", MaxBackups: 7, Compress: true})
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	log.SetOutput(writer)
+
+轮转出来的文件名是"app.log.<时间>"(按时间轮转时是旧文件所属的时间段, 否则是轮转的时刻), 压缩后再加上".gz".
+轮转时重新打开文件失败的话, 这次Write返回错误, 之后的Write会再次尝试打开.
+*/
+type RotateWriter struct {
+	Filename string
+	opts     RotateOptions
+	mutex    sync.Mutex
+	file     *os.File //nil: 已经关闭, 或者轮转之后没能重新打开
+	closed   bool
+	size     int64
+	period   string         //当前文件所属的时间段
+	wg       sync.WaitGroup //后台的压缩和清理
+	bgMutex  sync.Mutex     //同一时刻只有一个后台任务
+	bgErr    error          //OnError为nil时, 后台的第一个错误
+}
+
+func NewRotateWriter(filename string, opts *RotateOptions) (*RotateWriter, error) {
+	writer := &RotateWriter{Filename: filename}
+	if opts != nil {
+		writer.opts = *opts
+	}
+	if writer.opts.MaxSize < 0 || writer.opts.MaxBackups < 0 {
+		return nil, errors.New(fmt.Sprintf("illegal options, MaxSize=%v, MaxBackups=%v", writer.opts.MaxSize, writer.opts.MaxBackups))
+	}
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (self *RotateWriter) currentPeriod(t time.Time) string {
+	if len(self.opts.TimeLayout) == 0 {
+		return ""
+	}
+	return t.Format(self.opts.TimeLayout)
+}
+
+// 打开(或者创建)文件, 已经存在的文件按它的修改时间决定所属的时间段.
+func (self *RotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(self.Filename), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(self.Filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	self.file, self.size = f, info.Size()
+	if self.size == 0 {
+		self.period = self.currentPeriod(time.Now())
+	} else {
+		self.period = self.currentPeriod(info.ModTime())
+	}
+	return nil
+}
+
+func (self *RotateWriter) Write(p []byte) (n int, err error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if err = self.reopen(); err != nil {
+		return
+	}
+	if self.period != self.currentPeriod(time.Now()) ||
+		(0 < self.opts.MaxSize && 0 < self.size && self.opts.MaxSize < self.size+int64(len(p))) {
+		if err = self.rotate(); err != nil {
+			return
+		}
+	}
+	n, err = self.file.Write(p)
+	self.size += int64(n)
+	return
+}
+
+// 立即轮转(比如收到信号时).
+func (self *RotateWriter) Rotate() error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if err := self.reopen(); err != nil {
+		return err
+	}
+	return self.rotate()
+}
+
+// 已经Close时返回os.ErrClosed; 之前轮转之后没能重新打开文件时, 再次尝试打开.
+func (self *RotateWriter) reopen() error {
+	if self.closed {
+		return os.ErrClosed
+	}
+	if self.file != nil {
+		return nil
+	}
+	return self.open()
+}
+
+// 出错时self.file可能是nil, 下次Write/Rotate时会重新打开.
+func (self *RotateWriter) rotate() error {
+	err := self.file.Close()
+	self.file = nil
+	if err != nil {
+		return err
+	}
+
+	if 0 < self.size {
+		stamp := self.period
+		if len(stamp) == 0 || 0 < self.opts.MaxSize { //按大小轮转时, 同一个时间段可能有多个文件
+			stamp = time.Now().Format("20060102_150405")
+			if len(self.period) != 0 {
+				stamp = self.period + "." + stamp
+			}
+		}
+		backup := self.backupName(stamp)
+		if err := os.Rename(self.Filename, backup); err != nil {
+			if openErr := self.open(); openErr != nil {
+				return errors.New(fmt.Sprintf("%v, reopen: %v", err, openErr))
+			}
+			return err
+		}
+		self.wg.Add(1)
+		go self.background(backup)
+	}
+	return self.open()
+}
+
+// 不和已有的文件重名.
+func (self *RotateWriter) backupName(stamp string) string {
+	name := self.Filename + "." + stamp
+	for idx := 1; ; idx++ {
+		candidate := name
+		if 1 < idx {
+			candidate = fmt.Sprintf("%v.%v", name, idx)
+		}
+		_, err1 := os.Lstat(candidate)
+		_, err2 := os.Lstat(candidate + ".gz")
+		if os.IsNotExist(err1) && os.IsNotExist(err2) {
+			return candidate
+		}
+	}
+}
+
+func (self *RotateWriter) background(backup string) {
+	defer self.wg.Done()
+	self.bgMutex.Lock()
+	defer self.bgMutex.Unlock()
+	if self.opts.Compress {
+		if err := gzipFile(backup); err != nil && !os.IsNotExist(err) { //不存在: 已经被之前的清理删除了
+			self.reportError(errors.New(fmt.Sprintf("gzip %v: %v", backup, err)))
+		}
+	}
+	if err := self.removeOldBackups(); err != nil {
+		self.reportError(err)
+	}
+}
+
+// 只在后台任务里调用(持有bgMutex).
+func (self *RotateWriter) reportError(err error) {
+	if self.opts.OnError != nil {
+		self.opts.OnError(err)
+	} else if self.bgErr == nil {
+		self.bgErr = err
+	}
+}
+
+// 所有轮转出来的文件, 从旧到新.
+func (self *RotateWriter) Backups() ([]string, error) {
+	dir, base := filepath.Dir(self.Filename), filepath.Base(self.Filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type backupInfo struct {
+		name    string
+		modTime time.Time
+	}
+	infos := make([]*backupInfo, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), base+".") || !self.isBackupStamp(entry.Name()[len(base)+1:]) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, &backupInfo{name: filepath.Join(dir, entry.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].modTime.Equal(infos[j].modTime) {
+			return infos[i].modTime.Before(infos[j].modTime)
+		}
+		return infos[i].name < infos[j].name
+	})
+	backups := make([]string, 0, len(infos))
+	for _, info := range infos {
+		backups = append(backups, info.name)
+	}
+	return backups, nil
+}
+
+// 检查文件名的后缀是不是backupName生成的(以免删除同一个目录下的其他文件).
+func (self *RotateWriter) isBackupStamp(suffix string) bool {
+	const stampLayout = "20060102_150405"
+	check := func(stamp string) bool {
+		if 0 < self.opts.MaxSize || len(self.opts.TimeLayout) == 0 {
+			if len(stamp) < len(stampLayout) {
+				return false
+			}
+			if _, err := time.Parse(stampLayout, stamp[len(stamp)-len(stampLayout):]); err != nil {
+				return false
+			}
+			if len(self.opts.TimeLayout) == 0 {
+				return len(stamp) == len(stampLayout)
+			}
+			stamp = strings.TrimSuffix(stamp[:len(stamp)-len(stampLayout)], ".")
+		}
+		_, err := time.Parse(self.opts.TimeLayout, stamp)
+		return err == nil
+	}
+
+	suffix = strings.TrimSuffix(suffix, ".gz")
+	if check(suffix) {
+		return true
+	}
+	if idx := strings.LastIndex(suffix, "."); 0 <= idx { //重名时加上的".2"等
+		if _, err := strconv.Atoi(suffix[idx+1:]); err == nil {
+			return check(suffix[:idx])
+		}
+	}
+	return false
+}
+
+func (self *RotateWriter) removeOldBackups() error {
+	if self.opts.MaxBackups == 0 {
+		return nil
+	}
+	backups, err := self.Backups()
+	if err != nil {
+		return err
+	}
+	for idx := 0; idx < len(backups)-self.opts.MaxBackups; idx++ {
+		if err = os.Remove(backups[idx]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 把filename压缩成filename+".gz"(保留修改时间), 然后删除filename.
+func gzipFile(filename string) (err error) {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	writer, err := NewAtomicWriter(filename+".gz", nil)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	gzipWriter := gzip.NewWriter(writer)
+	gzipWriter.Name = filepath.Base(filename)
+	gzipWriter.ModTime = info.ModTime()
+	if _, err = io.Copy(gzipWriter, src); err != nil {
+		return err
+	}
+	if err = gzipWriter.Close(); err != nil {
+		return err
+	}
+	if err = writer.Commit(); err != nil {
+		return err
+	}
+	os.Chtimes(filename+".gz", info.ModTime(), info.ModTime())
+	src.Close()
+	return os.Remove(filename)
+}
+
+// 关闭文件, 并等待后台的压缩和清理完成. 没有设置OnError时, 也返回后台的第一个错误.
+func (self *RotateWriter) Close() error {
+	self.mutex.Lock()
+	var err error
+	if self.file != nil {
+		err = self.file.Close()
+		self.file = nil
+	}
+	self.closed = true
+	self.mutex.Unlock()
+	self.wg.Wait()
+	if err == nil {
+		self.bgMutex.Lock()
+		err, self.bgErr = self.bgErr, nil
+		self.bgMutex.Unlock()
+	}
+	return err
+}
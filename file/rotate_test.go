@@ -0,0 +1,129 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotateWriterSize(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "logs", "app.log")
+	writer, err := NewRotateWriter(filename, &RotateOptions{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err = writer.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write([]byte("x")); err != os.ErrClosed {
+		t.Fatalf("write after Close, err=%v", err)
+	}
+
+	backups, err := writer.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 { //first被清理了
+		t.Fatalf("backups=%v", backups)
+	}
+	contents := make([]string, 0)
+	for _, backup := range append(backups, filename) {
+		content, err := os.ReadFile(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(content))
+	}
+	if got := strings.Join(contents, ""); got != "second\nthird\nfourth\n" {
+		t.Fatalf("contents=%q", got)
+	}
+}
+
+// 让后台的压缩失败: 在压缩开始之前, 创建一个和压缩文件同名的目录.
+func rotateWithGzipError(t *testing.T, writer *RotateWriter) {
+	writer.bgMutex.Lock()
+	if err := writer.Rotate(); err != nil {
+		writer.bgMutex.Unlock()
+		t.Fatal(err)
+	}
+	backups, err := writer.Backups()
+	if err != nil || len(backups) != 1 {
+		writer.bgMutex.Unlock()
+		t.Fatalf("backups=%v, err=%v", backups, err)
+	}
+	err = os.Mkdir(backups[0]+".gz", 0755)
+	writer.bgMutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateWriterBackgroundError(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "app.log")
+	writer, err := NewRotateWriter(filename, &RotateOptions{Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write([]byte("data\n")); err != nil {
+		t.Fatal(err)
+	}
+	rotateWithGzipError(t, writer)
+	if err = writer.Close(); err == nil || !strings.Contains(err.Error(), "gzip") {
+		t.Fatalf("background error is not returned by Close, err=%v", err)
+	}
+
+	errs := make([]error, 0)
+	filename = filepath.Join(t.TempDir(), "app.log")
+	writer, err = NewRotateWriter(filename, &RotateOptions{Compress: true, OnError: func(err error) { errs = append(errs, err) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write([]byte("data\n")); err != nil {
+		t.Fatal(err)
+	}
+	rotateWithGzipError(t, writer)
+	if err = writer.Close(); err != nil || len(errs) != 1 || !strings.Contains(errs[0].Error(), "gzip") {
+		t.Fatalf("err=%v, errs=%v", err, errs)
+	}
+}
+
+// 轮转之后没能重新打开文件时, 之后的Write会再次尝试打开.
+func TestRotateWriterReopen(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	writer, err := NewRotateWriter(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	//用一个同名的目录让打开失败
+	if err = os.Rename(filename, filename+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Mkdir(filename, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = writer.Rotate(); err == nil {
+		t.Fatal("reopen error is not returned")
+	}
+	if _, err = writer.Write([]byte("lost\n")); err == nil || err == os.ErrClosed {
+		t.Fatalf("err=%v", err)
+	}
+
+	if err = os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = writer.Write([]byte("data\n")); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(filename); err != nil || string(content) != "data\n" {
+		t.Fatalf("content=%q, err=%v", content, err)
+	}
+}
@@ -0,0 +1,479 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zx9229/zxgo"
+)
+
+const (
+	SymlinkCopy   string = "COPY"   //复制符号链接本身(默认)
+	SymlinkFollow string = "FOLLOW" //复制符号链接指向的文件或目录
+	SymlinkSkip   string = "SKIP"   //忽略符号链接
+)
+
+const (
+	CompareModTime string = "MODTIME" //大小和修改时间都相同时, 认为文件没有变化(默认)
+	CompareHash    string = "HASH"    //大小和哈希值(见zxgo.CalcHash)都相同时, 认为文件没有变化
+)
+
+const (
+	ActionMkdir   string = "MKDIR"
+	ActionCopy    string = "COPY"   //目标文件不存在
+	ActionUpdate  string = "UPDATE" //目标文件存在, 但是内容不同
+	ActionSymlink string = "SYMLINK"
+	ActionDelete  string = "DELETE"
+	ActionSkip    string = "SKIP" //不是普通文件(设备文件, 命名管道等), 没有复制
+)
+
+type TreeOptions struct {
+	Symlink   string //SymlinkCopy(默认)/SymlinkFollow/SymlinkSkip
+	Compare   string //SyncTree比较文件的方式, CompareModTime(默认)/CompareHash
+	HashStyle string //CompareHash使用的算法, 默认"sha256"
+	Delete    bool   //删除目标目录中, 源目录里没有的文件和目录
+	DryRun    bool   //只返回要做的动作, 不修改任何文件
+}
+
+// 一个动作, Path是相对于目录的路径(用"/"分隔).
+type TreeAction struct {
+	Action string
+	Path   string
+	Size   int64
+}
+
+func (self *TreeAction) String() string {
+	return fmt.Sprintf("%-7v %v", self.Action, self.Path)
+}
+
+type treeSyncer struct {
+	opts     TreeOptions
+	always   bool //CopyTree: 不比较, 总是复制
+	actions  []*TreeAction
+	seen     map[string]bool //源目录中存在的相对路径
+	dirs     []*dirTimes
+	visiting map[string]bool //SymlinkFollow时, 正在复制的目录的真实路径, 用于检测循环
+	realDst  string          //目标目录的真实路径
+	strict   bool            //MoveTree: 不能复制的文件是错误, 而不是ActionSkip
+}
+
+type dirTimes struct {
+	path    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// 递归地复制目录, 保留文件的权限和修改时间. 已经存在的文件总是被覆盖. dst不能是src, 也不能在src里面.
+// 设置了Delete时, src也不能在dst里面(否则src会被当成多余的文件删掉).
+func CopyTree(src string, dst string, opts *TreeOptions) ([]*TreeAction, error) {
+	return newTreeSyncer(opts, true).sync(src, dst)
+}
+
+/*
+单向同步: 只复制新增的和有变化的文件(比较方式见TreeOptions.Compare). 例如先看看要做什么:
+
+	actions, err := file.SyncTree("build", "/srv/www", &file.TreeOptions{Delete: true, DryRun: true})
+	for _, action := range actions {
+		fmt.Println(action)
+	}
+*/
+func SyncTree(src string, dst string, opts *TreeOptions) ([]*TreeAction, error) {
+	return newTreeSyncer(opts, false).sync(src, dst)
+}
+
+// 镜像: 同SyncTree, 并且删除目标目录中多余的文件, 使两个目录完全一样.
+func MirrorTree(src string, dst string, opts *TreeOptions) ([]*TreeAction, error) {
+	mirrorOpts := TreeOptions{}
+	if opts != nil {
+		mirrorOpts = *opts
+	}
+	mirrorOpts.Delete = true
+	return newTreeSyncer(&mirrorOpts, false).sync(src, dst)
+}
+
+func newTreeSyncer(opts *TreeOptions, always bool) *treeSyncer {
+	syncer := &treeSyncer{always: always, actions: make([]*TreeAction, 0), seen: make(map[string]bool), visiting: make(map[string]bool)}
+	if opts != nil {
+		syncer.opts = *opts
+	}
+	return syncer
+}
+
+func (self *treeSyncer) sync(src string, dst string) ([]*TreeAction, error) {
+	if len(self.opts.Symlink) == 0 {
+		self.opts.Symlink = SymlinkCopy
+	}
+	if len(self.opts.Compare) == 0 {
+		self.opts.Compare = CompareModTime
+	}
+	if len(self.opts.HashStyle) == 0 {
+		self.opts.HashStyle = "sha256"
+	}
+	switch {
+	case self.opts.Symlink != SymlinkCopy && self.opts.Symlink != SymlinkFollow && self.opts.Symlink != SymlinkSkip:
+		return nil, errors.New(fmt.Sprintf("unknown Symlink=%v", self.opts.Symlink))
+	case self.opts.Compare != CompareModTime && self.opts.Compare != CompareHash:
+		return nil, errors.New(fmt.Sprintf("unknown Compare=%v", self.opts.Compare))
+	}
+
+	var err error
+	if src, err = realPath(src); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(src); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, errors.New(fmt.Sprintf("%v is not a directory", src))
+	}
+	if self.realDst, err = realPath(dst); err != nil {
+		return nil, err
+	}
+	if isSubPath(src, self.realDst) {
+		return nil, errors.New(fmt.Sprintf("%v is inside %v", dst, src))
+	}
+	if self.opts.Delete && isSubPath(self.realDst, src) {
+		return nil, errors.New(fmt.Sprintf("%v is inside %v", src, dst))
+	}
+	if err = self.syncDir(src, dst, "."); err != nil {
+		return self.actions, err
+	}
+	if self.opts.Delete {
+		if err = self.deleteExtraneous(dst); err != nil {
+			return self.actions, err
+		}
+	}
+	if !self.opts.DryRun {
+		for idx := len(self.dirs) - 1; 0 <= idx; idx-- { //目录里面的文件写完之后, 再设置目录的权限和修改时间
+			dir := self.dirs[idx]
+			if err = os.Chmod(dir.path, dir.mode); err != nil {
+				return self.actions, err
+			}
+			if err = os.Chtimes(dir.path, dir.modTime, dir.modTime); err != nil {
+				return self.actions, err
+			}
+		}
+	}
+	return self.actions, nil
+}
+
+// 绝对路径, 并且解析了符号链接. path(或者它的上级目录)可以不存在, 这时解析存在的那部分.
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+// path是dir本身, 或者在dir里面(两者都是realPath的结果).
+func isSubPath(dir string, path string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func (self *treeSyncer) addAction(action string, relPath string, size int64) {
+	self.actions = append(self.actions, &TreeAction{Action: action, Path: relPath, Size: size})
+}
+
+// 把src目录(真实路径, 不是符号链接)同步到dst, relPrefix是src相对于最初的源目录的路径.
+func (self *treeSyncer) syncDir(src string, dst string, relPrefix string) error {
+	if self.opts.Symlink == SymlinkFollow {
+		if self.visiting[src] {
+			return errors.New(fmt.Sprintf("symlink loop at %v", relPrefix))
+		}
+		self.visiting[src] = true
+		defer delete(self.visiting, src)
+	}
+
+	return filepath.WalkDir(src, func(srcPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, srcPath)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, relPath)
+		relPath = filepath.ToSlash(filepath.Join(relPrefix, relPath))
+		self.seen[relPath] = true
+
+		if entry.Type()&fs.ModeSymlink != 0 {
+			switch self.opts.Symlink {
+			case SymlinkSkip:
+				return nil
+			case SymlinkCopy:
+				return self.syncSymlink(srcPath, dstPath, relPath)
+			}
+			info, err := os.Stat(srcPath)
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				realSrc, err := filepath.EvalSymlinks(srcPath)
+				if err != nil {
+					return err
+				}
+				if isSubPath(realSrc, self.realDst) {
+					return errors.New(fmt.Sprintf("%v points to a directory containing the destination", relPath))
+				}
+				if self.opts.Delete && isSubPath(self.realDst, realSrc) {
+					return errors.New(fmt.Sprintf("%v points to a directory inside the destination", relPath))
+				}
+				return self.syncDir(realSrc, dstPath, relPath)
+			}
+			if !info.Mode().IsRegular() {
+				return self.skip(relPath)
+			}
+			return self.syncFile(srcPath, info, dstPath, relPath)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return self.mkdir(dstPath, info, relPath)
+		}
+		if !info.Mode().IsRegular() { //设备文件, 命名管道等
+			return self.skip(relPath)
+		}
+		return self.syncFile(srcPath, info, dstPath, relPath)
+	})
+}
+
+func (self *treeSyncer) skip(relPath string) error {
+	if self.strict {
+		return errors.New(fmt.Sprintf("%v is not a regular file", relPath))
+	}
+	self.addAction(ActionSkip, relPath, 0)
+	return nil
+}
+
+// dst已经存在但是类型不对(比如源是文件而目标是目录)时, 先删除它. 返回dst现在的信息(不存在时为nil).
+func (self *treeSyncer) prepare(dstPath string, relPath string, wantDir bool, wantSymlink bool) (os.FileInfo, error) {
+	info, err := os.Lstat(dstPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	isSymlink := info.Mode()&fs.ModeSymlink != 0
+	if info.IsDir() == wantDir && isSymlink == wantSymlink && (wantDir || wantSymlink || info.Mode().IsRegular()) {
+		return info, nil
+	}
+	self.addAction(ActionDelete, relPath, 0)
+	if self.opts.DryRun {
+		return nil, nil
+	}
+	return nil, os.RemoveAll(dstPath)
+}
+
+func (self *treeSyncer) mkdir(dstPath string, info os.FileInfo, relPath string) error {
+	dstInfo, err := self.prepare(dstPath, relPath, true, false)
+	if err != nil {
+		return err
+	}
+	if dstInfo == nil {
+		self.addAction(ActionMkdir, relPath, 0)
+		if !self.opts.DryRun {
+			if err = os.MkdirAll(dstPath, 0777); err != nil {
+				return err
+			}
+		}
+	} else if dstInfo.Mode().Perm()&0200 == 0 && !self.opts.DryRun { //上次同步时设置成了只读, 暂时允许写入, 最后会恢复
+		if err = os.Chmod(dstPath, dstInfo.Mode().Perm()|0200); err != nil {
+			return err
+		}
+	}
+	self.dirs = append(self.dirs, &dirTimes{path: dstPath, mode: info.Mode().Perm(), modTime: info.ModTime()})
+	return nil
+}
+
+func (self *treeSyncer) syncSymlink(srcPath string, dstPath string, relPath string) error {
+	target, err := os.Readlink(srcPath)
+	if err != nil {
+		return err
+	}
+	dstInfo, err := self.prepare(dstPath, relPath, false, true)
+	if err != nil {
+		return err
+	}
+	if dstInfo != nil {
+		if current, err := os.Readlink(dstPath); err == nil && current == target {
+			return nil
+		}
+	}
+	self.addAction(ActionSymlink, relPath, 0)
+	if self.opts.DryRun {
+		return nil
+	}
+	if dstInfo != nil {
+		if err = os.Remove(dstPath); err != nil {
+			return err
+		}
+	}
+	return os.Symlink(target, dstPath)
+}
+
+func (self *treeSyncer) syncFile(srcPath string, info os.FileInfo, dstPath string, relPath string) error {
+	dstInfo, err := self.prepare(dstPath, relPath, false, false)
+	if err != nil {
+		return err
+	}
+	action := ActionCopy
+	if dstInfo != nil {
+		action = ActionUpdate
+		if !self.always {
+			changed, err := self.changed(srcPath, info, dstPath, dstInfo)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				return nil
+			}
+		}
+	}
+	self.addAction(action, relPath, info.Size())
+	if self.opts.DryRun {
+		return nil
+	}
+	return copyFile(srcPath, info, dstPath)
+}
+
+func (self *treeSyncer) changed(srcPath string, info os.FileInfo, dstPath string, dstInfo os.FileInfo) (bool, error) {
+	if info.Size() != dstInfo.Size() {
+		return true, nil
+	}
+	if self.opts.Compare == CompareModTime {
+		return !info.ModTime().Equal(dstInfo.ModTime()), nil
+	}
+	srcHash, err := zxgo.CalcHash(srcPath, self.opts.HashStyle, false)
+	if err != nil {
+		return false, err
+	}
+	dstHash, err := zxgo.CalcHash(dstPath, self.opts.HashStyle, false)
+	if err != nil {
+		return false, err
+	}
+	return srcHash != dstHash, nil
+}
+
+// 删除dst中源目录没有的文件和目录.
+func (self *treeSyncer) deleteExtraneous(dst string) error {
+	if _, err := os.Lstat(dst); os.IsNotExist(err) {
+		return nil
+	}
+	deletes := make([]string, 0)
+	err := filepath.WalkDir(dst, func(dstPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dst, dstPath)
+		if err != nil {
+			return err
+		}
+		if relPath = filepath.ToSlash(relPath); relPath == "." || self.seen[relPath] {
+			return nil
+		}
+		self.addAction(ActionDelete, relPath, 0)
+		deletes = append(deletes, dstPath)
+		if entry.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil || self.opts.DryRun {
+		return err
+	}
+	for _, dstPath := range deletes {
+		if err = os.RemoveAll(dstPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 原子地复制一个文件, 保留权限和修改时间.
+func copyFile(srcPath string, info os.FileInfo, dstPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	writer, err := NewAtomicWriter(dstPath, &AtomicOptions{Perm: info.Mode().Perm()})
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	if _, err = io.Copy(writer, src); err != nil {
+		return err
+	}
+	if err = writer.Commit(); err != nil {
+		return err
+	}
+	if err = os.Chmod(dstPath, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dstPath, info.ModTime(), info.ModTime())
+}
+
+// 移动文件或目录, dst不能已经存在. 不能直接改名(比如跨文件系统)时, 先复制再删除src.
+// 复制时遇到不能复制的文件(设备文件, 命名管道等)会返回错误, 并且保留src.
+func MoveTree(src string, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if _, err = os.Lstat(dst); err == nil {
+		return errors.New(fmt.Sprintf("%v already exists", dst))
+	}
+	if err = os.Rename(src, dst); err == nil {
+		return nil
+	} else if os.IsNotExist(err) || os.IsPermission(err) {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		syncer := newTreeSyncer(nil, true)
+		syncer.strict = true
+		if _, err = syncer.sync(src, dst); err != nil {
+			os.RemoveAll(dst)
+			return err
+		}
+	case info.Mode()&fs.ModeSymlink != 0:
+		var target string
+		if target, err = os.Readlink(src); err != nil {
+			return err
+		}
+		if err = os.Symlink(target, dst); err != nil {
+			return err
+		}
+	case !info.Mode().IsRegular():
+		return errors.New(fmt.Sprintf("%v is not a regular file", src))
+	default:
+		if err = copyFile(src, info, dst); err != nil {
+			os.Remove(dst)
+			return err
+		}
+	}
+	return os.RemoveAll(src)
+}
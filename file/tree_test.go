@@ -0,0 +1,134 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTree(t *testing.T, root string) map[string]string {
+	files := make(map[string]string)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(root, path)
+		files[filepath.ToSlash(relPath)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func actionsString(actions []*TreeAction) string {
+	items := make([]string, 0, len(actions))
+	for _, action := range actions {
+		items = append(items, action.Action+" "+action.Path)
+	}
+	return strings.Join(items, ",")
+}
+
+func TestSyncTree(t *testing.T) {
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	writeTree(t, src, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "a.txt"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err := SyncTree(src, dst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actionsString(actions); got != "MKDIR .,COPY a.txt,MKDIR sub,COPY sub/b.txt" {
+		t.Fatalf("actions=%v", got)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "a.txt")); err != nil || !info.ModTime().Equal(modTime) {
+		t.Fatalf("modification time is not preserved, info=%v, err=%v", info, err)
+	}
+
+	if actions, err = SyncTree(src, dst, nil); err != nil || len(actions) != 0 {
+		t.Fatalf("nothing changed, actions=%v, err=%v", actionsString(actions), err)
+	}
+
+	writeTree(t, src, map[string]string{"a.txt": "aa"})
+	writeTree(t, dst, map[string]string{"extra/c.txt": "c"})
+	if actions, err = MirrorTree(src, dst, &TreeOptions{DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := actionsString(actions); got != "UPDATE a.txt,DELETE extra" {
+		t.Fatalf("actions=%v", got)
+	}
+	if got := readTree(t, dst); got["a.txt"] != "a" || got["extra/c.txt"] != "c" {
+		t.Fatalf("DryRun modified dst, files=%v", got)
+	}
+
+	if _, err = MirrorTree(src, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readTree(t, dst), readTree(t, src); len(got) != len(want) || got["a.txt"] != "aa" || got["sub/b.txt"] != "b" {
+		t.Fatalf("files=%v, want %v", got, want)
+	}
+}
+
+func TestSyncTreeNested(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"x.txt": "x", "b/y.txt": "y"})
+
+	if _, err := CopyTree(root, filepath.Join(root, "b", "copy"), nil); err == nil {
+		t.Fatal("dst inside src is not rejected")
+	}
+	if _, err := CopyTree(root, root, nil); err == nil {
+		t.Fatal("dst equal to src is not rejected")
+	}
+
+	//src在dst里面: 删除多余的文件时会把src本身删掉
+	actions, err := MirrorTree(filepath.Join(root, "b"), root, nil)
+	if err == nil || len(actions) != 0 {
+		t.Fatalf("src inside dst is not rejected, actions=%v, err=%v", actionsString(actions), err)
+	}
+	if got := readTree(t, root); len(got) != 2 || got["b/y.txt"] != "y" {
+		t.Fatalf("files=%v", got)
+	}
+}
+
+func TestMoveTree(t *testing.T) {
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	writeTree(t, src, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	writeTree(t, root, map[string]string{"exists/c.txt": "c"})
+
+	if err := MoveTree(src, filepath.Join(root, "exists")); err == nil {
+		t.Fatal("existing dst is not rejected")
+	}
+	if err := MoveTree(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(src); !os.IsNotExist(err) {
+		t.Fatalf("src still exists, err=%v", err)
+	}
+	if got := readTree(t, dst); len(got) != 2 || got["a.txt"] != "a" || got["sub/b.txt"] != "b" {
+		t.Fatalf("files=%v", got)
+	}
+}
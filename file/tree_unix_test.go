@@ -0,0 +1,67 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestSyncTreeSpecialFile(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	writeTree(t, src, map[string]string{"a.txt": "a"})
+	if err := syscall.Mkfifo(filepath.Join(src, "fifo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	actions, err := CopyTree(src, filepath.Join(root, "copy"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actionsString(actions); got != "MKDIR .,COPY a.txt,SKIP fifo" {
+		t.Fatalf("actions=%v", got)
+	}
+
+	//MoveTree不能改名时使用的复制方式: 不能复制的文件是错误, 否则删除src时会丢失它
+	syncer := newTreeSyncer(nil, true)
+	syncer.strict = true
+	if _, err = syncer.sync(src, filepath.Join(root, "move")); err == nil {
+		t.Fatal("special file is not rejected")
+	}
+	if _, err = os.Lstat(filepath.Join(src, "fifo")); err != nil {
+		t.Fatal(err)
+	}
+
+	//跨文件系统时才会复制, 找不到另一个文件系统就不测试了
+	other, err := os.MkdirTemp("/dev/shm", "tree_test")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer os.RemoveAll(other)
+	var srcStat, otherStat syscall.Stat_t
+	if syscall.Stat(src, &srcStat) != nil || syscall.Stat(other, &otherStat) != nil || srcStat.Dev == otherStat.Dev {
+		t.Skip("/dev/shm is not another file system")
+	}
+	if err = MoveTree(src, filepath.Join(other, "move")); err == nil {
+		t.Fatal("special file is not rejected")
+	}
+	if got := readTree(t, src); len(got) != 1 || got["a.txt"] != "a" {
+		t.Fatalf("src is modified, files=%v", got)
+	}
+	if _, err = os.Lstat(filepath.Join(other, "move")); !os.IsNotExist(err) {
+		t.Fatalf("dst is not removed, err=%v", err)
+	}
+
+	if err = os.Remove(filepath.Join(src, "fifo")); err != nil {
+		t.Fatal(err)
+	}
+	if err = MoveTree(src, filepath.Join(other, "move")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Lstat(src); !os.IsNotExist(err) {
+		t.Fatalf("src still exists, err=%v", err)
+	}
+}
@@ -0,0 +1,347 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zx9229/zxgo"
+	"github.com/zx9229/zxgo/zxmatch"
+)
+
+const (
+	WatchCreate string = "CREATE"
+	WatchWrite  string = "WRITE"
+	WatchRemove string = "REMOVE" //删除, 或者被移出了监视的目录
+)
+
+var errWatchUnsupported = errors.New("native watcher is not supported on this platform")
+
+// 一个变化. 改名被报告为旧名字的WatchRemove和新名字的WatchCreate.
+type WatchEvent struct {
+	Op       string
+	Path     string //相对于Root的路径(用"/"分隔)
+	FullPath string
+	IsDir    bool
+	Time     time.Time //最后一次变化的时间
+}
+
+func (self *WatchEvent) String() string {
+	return fmt.Sprintf("%-6v %v", self.Op, self.Path)
+}
+
+type WatchOptions struct {
+	Recursive    bool             //也监视子目录(包括后来创建的子目录)
+	Debounce     time.Duration    //同一个路径的变化, 在安静了这么久之后才合并成一个事件输出, 默认100ms
+	PollInterval time.Duration    //轮询的间隔, 默认1s
+	ForcePoll    bool             //不使用inotify, 总是轮询(比如网络文件系统上inotify不可靠)
+	Matcher      *zxmatch.Matcher //只报告匹配的路径(用法见zxmatch.Matcher), 被排除的目录不会被监视. nil表示全部
+}
+
+/*
+监视目录的变化. Linux上使用inotify, 其他平台(或者inotify不可用时)定期轮询. 用法如下所示:
+
+	exclude, _ := zxmatch.NewRuleSet("*.tmp", ".git/")
+	watcher := file.NewWatcher("uploads", &file.WatchOptions{Recursive: true, Matcher: &zxmatch.Matcher{Exclude: exclude}})
+	err := watcher.Run(ctx, func(event *file.WatchEvent) error {
+		fmt.Println(event)
+		return nil
+	})
+
+同一个路径在Debounce之内的多次变化被合并: 比如CREATE之后的WRITE仍然是CREATE, CREATE之后又REMOVE则什么也不输出.
+*/
+type Watcher struct {
+	Root string
+	opts WatchOptions
+}
+
+func NewWatcher(root string, opts *WatchOptions) *Watcher {
+	watcher := &Watcher{Root: filepath.Clean(root)}
+	if opts != nil {
+		watcher.opts = *opts
+	}
+	if watcher.opts.Debounce <= 0 {
+		watcher.opts.Debounce = 100 * time.Millisecond
+	}
+	if watcher.opts.PollInterval <= 0 {
+		watcher.opts.PollInterval = time.Second
+	}
+	return watcher
+}
+
+// 事件的来源: inotify或者轮询. run把原始的事件写入out, 直到ctx被取消.
+type watchSource interface {
+	run(ctx context.Context, out chan<- *WatchEvent) error
+}
+
+func (self *Watcher) match(relPath string, isDir bool) bool {
+	return self.opts.Matcher == nil || self.opts.Matcher.Match(relPath, isDir)
+}
+
+func (self *Watcher) fullPath(relPath string) string {
+	return filepath.Join(self.Root, filepath.FromSlash(relPath))
+}
+
+func (self *Watcher) newEvent(op string, relPath string, isDir bool) *WatchEvent {
+	relPath = filepath.ToSlash(relPath)
+	return &WatchEvent{Op: op, Path: relPath, FullPath: self.fullPath(relPath), IsDir: isDir, Time: time.Now()}
+}
+
+// 遍历要监视的目录(Recursive为false时只有Root), 对每一个(没有被排除的)路径调用fn.
+func (self *Watcher) walk(fn func(relPath string, entry fs.DirEntry) error) error {
+	return filepath.WalkDir(self.Root, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if fullPath != self.Root && os.IsNotExist(err) { //遍历时被删除了
+				return nil
+			}
+			return err
+		}
+		relPath, err := filepath.Rel(self.Root, fullPath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath != "." {
+			if !self.match(relPath, entry.IsDir()) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if err = fn(relPath, entry); err != nil {
+			return err
+		}
+		if entry.IsDir() && relPath != "." && !self.opts.Recursive {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+type pendingEvent struct {
+	event *WatchEvent
+	seq   int
+}
+
+// 合并同一个路径的两次变化, keep为false表示这两次变化互相抵消了.
+func coalesce(prev string, next string) (op string, keep bool) {
+	switch {
+	case prev == WatchCreate && next == WatchRemove:
+		return "", false
+	case prev == WatchCreate:
+		return WatchCreate, true
+	case prev == WatchRemove && next == WatchCreate: //被替换了
+		return WatchWrite, true
+	}
+	return next, true
+}
+
+// 一直监视, 对每一个(合并后的)事件调用fn, 直到ctx被取消(返回nil), 或者出错/fn返回error(返回这个error).
+func (self *Watcher) Run(ctx context.Context, fn func(event *WatchEvent) error) error {
+	if info, err := os.Stat(self.Root); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return errors.New(fmt.Sprintf("%v is not a directory", self.Root))
+	}
+
+	var source watchSource
+	var err error
+	if !self.opts.ForcePoll {
+		source, err = newNativeSource(self)
+	}
+	if self.opts.ForcePoll || err != nil {
+		if source, err = newPollSource(self); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	raw := make(chan *WatchEvent, 1024)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- source.run(ctx, raw)
+	}()
+
+	pendings := make(map[string]*pendingEvent)
+	seq := 0
+	flush := func(all bool) error {
+		due := make([]*pendingEvent, 0)
+		now := time.Now()
+		for path, pending := range pendings {
+			if all || self.opts.Debounce <= now.Sub(pending.event.Time) {
+				due = append(due, pending)
+				delete(pendings, path)
+			}
+		}
+		sort.Slice(due, func(i, j int) bool { return due[i].seq < due[j].seq })
+		for _, pending := range due {
+			if err := fn(pending.event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(max(self.opts.Debounce/4, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-raw:
+			pending, ok := pendings[event.Path]
+			if !ok {
+				seq++
+				pendings[event.Path] = &pendingEvent{event: event, seq: seq}
+				continue
+			}
+			op, keep := coalesce(pending.event.Op, event.Op)
+			if !keep {
+				delete(pendings, event.Path)
+				continue
+			}
+			event.Op = op
+			pending.event = event
+		case <-ticker.C:
+			if err = flush(false); err != nil {
+				return err
+			}
+		case err = <-errCh:
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err1 := flush(true); err1 != nil {
+				return err1
+			}
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// 把事件(*WatchEvent)放入队列, 由队列的回调函数(或者Pop)处理.
+func (self *Watcher) PushToQueue(ctx context.Context, queue *zxgo.Queue) error {
+	return self.Run(ctx, func(event *WatchEvent) error {
+		queue.Push(event)
+		return nil
+	})
+}
+
+type pollEntry struct {
+	isDir   bool
+	size    int64
+	modTime time.Time
+}
+
+// 定期遍历目录, 和上一次的结果比较.
+type pollSource struct {
+	watcher  *Watcher
+	snapshot map[string]*pollEntry
+}
+
+func newPollSource(watcher *Watcher) (watchSource, error) {
+	source := &pollSource{watcher: watcher}
+	var err error
+	if source.snapshot, err = source.scan(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+func (self *pollSource) scan() (map[string]*pollEntry, error) {
+	snapshot := make(map[string]*pollEntry)
+	err := self.watcher.walk(func(relPath string, entry fs.DirEntry) error {
+		if relPath == "." {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		snapshot[relPath] = &pollEntry{isDir: entry.IsDir(), size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return snapshot, err
+}
+
+// 重新遍历目录, 返回和上一次的结果相比的变化(按路径排序, 父目录在前).
+func (self *pollSource) rescan() ([]*WatchEvent, error) {
+	snapshot, err := self.scan()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]*WatchEvent, 0)
+	for relPath, entry := range snapshot {
+		old, ok := self.snapshot[relPath]
+		switch {
+		case !ok:
+			events = append(events, self.watcher.newEvent(WatchCreate, relPath, entry.isDir))
+		case old.isDir != entry.isDir:
+			events = append(events, self.watcher.newEvent(WatchRemove, relPath, old.isDir), self.watcher.newEvent(WatchCreate, relPath, entry.isDir))
+		case !entry.isDir && (old.size != entry.size || !old.modTime.Equal(entry.modTime)):
+			events = append(events, self.watcher.newEvent(WatchWrite, relPath, false))
+		}
+	}
+	for relPath, old := range self.snapshot {
+		if _, ok := snapshot[relPath]; !ok {
+			events = append(events, self.watcher.newEvent(WatchRemove, relPath, old.isDir))
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	self.snapshot = snapshot
+	return events, nil
+}
+
+// 按已经报告的事件更新上一次的结果(inotify使用, 以便队列溢出时rescan只报告丢失的变化).
+func (self *pollSource) update(event *WatchEvent) {
+	if event.Op == WatchRemove {
+		delete(self.snapshot, event.Path)
+		if event.IsDir {
+			for relPath := range self.snapshot {
+				if strings.HasPrefix(relPath, event.Path+"/") {
+					delete(self.snapshot, relPath)
+				}
+			}
+		}
+		return
+	}
+	info, err := os.Lstat(event.FullPath)
+	if err != nil {
+		delete(self.snapshot, event.Path)
+		return
+	}
+	self.snapshot[event.Path] = &pollEntry{isDir: info.IsDir(), size: info.Size(), modTime: info.ModTime()}
+}
+
+func (self *pollSource) run(ctx context.Context, out chan<- *WatchEvent) error {
+	ticker := time.NewTicker(self.watcher.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		events, err := self.rescan()
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+//go:build linux
+
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+type inotifySource struct {
+	watcher *Watcher
+	fd      int
+	file    *os.File         //非阻塞的fd, 由runtime的poller等待, Close可以中断Read
+	dirs    map[int32]string //wd => 目录的相对路径("."是Root)
+	wds     map[string]int32
+	poll    *pollSource //已经报告的状态, 队列溢出(丢失了事件)时重新扫描, 报告和它的差别
+}
+
+func newNativeSource(watcher *Watcher) (watchSource, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	source := &inotifySource{watcher: watcher, fd: fd, file: os.NewFile(uintptr(fd), "inotify"), dirs: make(map[int32]string), wds: make(map[string]int32)}
+	err = watcher.walk(func(relPath string, entry fs.DirEntry) error {
+		if entry.IsDir() && (relPath == "." || watcher.opts.Recursive) {
+			return source.addWatch(relPath)
+		}
+		return nil
+	})
+	if err == nil { //先监视再扫描, 之间的变化会在事件里报告
+		source.poll = &pollSource{watcher: watcher}
+		source.poll.snapshot, err = source.poll.scan()
+	}
+	if err != nil {
+		source.file.Close()
+		return nil, err
+	}
+	return source, nil
+}
+
+func (self *inotifySource) addWatch(relPath string) error {
+	fullPath := self.watcher.fullPath(relPath)
+	wd, err := syscall.InotifyAddWatch(self.fd, fullPath, inotifyMask|syscall.IN_ONLYDIR)
+	if err != nil {
+		if err == syscall.ENOENT || err == syscall.ENOTDIR { //已经被删除了
+			return nil
+		}
+		return errors.New(fmt.Sprintf("inotify_add_watch %v: %v", fullPath, err))
+	}
+	self.dirs[int32(wd)] = relPath
+	self.wds[relPath] = int32(wd)
+	return nil
+}
+
+func (self *inotifySource) run(ctx context.Context, out chan<- *WatchEvent) error {
+	go func() {
+		<-ctx.Done()
+		self.file.Close()
+	}()
+
+	send := func(event *WatchEvent) bool {
+		self.poll.update(event) //发送之后event属于Run(会修改它的Op)
+		select {
+		case out <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := self.file.Read(buffer)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameBytes := buffer[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+			offset += syscall.SizeofInotifyEvent + int(raw.Len)
+			name := string(bytes.TrimRight(nameBytes, "\x00"))
+
+			if raw.Mask&syscall.IN_Q_OVERFLOW != 0 { //队列溢出, 丢失了一些事件
+				if err = self.rescan(ctx, send); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+				continue
+			}
+			dir, ok := self.dirs[raw.Wd]
+			if !ok {
+				continue
+			}
+			if raw.Mask&syscall.IN_IGNORED != 0 { //目录被删除了, 内核自动移除了监视
+				delete(self.dirs, raw.Wd)
+				if self.wds[dir] == raw.Wd {
+					delete(self.wds, dir)
+				}
+				continue
+			}
+			if raw.Mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+				if dir == "." {
+					return errors.New(fmt.Sprintf("%v was removed or moved", self.watcher.Root))
+				}
+				continue //父目录会报告这个目录的删除
+			}
+
+			relPath := path.Join(dir, name)
+			isDir := raw.Mask&syscall.IN_ISDIR != 0
+			if !self.watcher.match(relPath, isDir) {
+				continue
+			}
+			var op string
+			switch {
+			case raw.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+				op = WatchCreate
+			case raw.Mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+				op = WatchRemove
+			case raw.Mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0:
+				op = WatchWrite
+			default:
+				continue
+			}
+			if isDir && op == WatchWrite {
+				continue
+			}
+			if !send(self.watcher.newEvent(op, relPath, isDir)) {
+				return nil
+			}
+
+			if isDir && op == WatchRemove {
+				self.removeWatches(relPath)
+			}
+			if isDir && op == WatchCreate && self.watcher.opts.Recursive {
+				if err = self.addTree(ctx, relPath, send); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+			}
+		}
+	}
+}
+
+// 重新扫描, 像轮询一样报告和已经报告的状态之间的差别, 并且更新子目录的监视.
+func (self *inotifySource) rescan(ctx context.Context, send func(event *WatchEvent) bool) error {
+	events, err := self.poll.rescan()
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if event.IsDir && event.Op == WatchRemove {
+			self.removeWatches(event.Path)
+		}
+		if event.IsDir && event.Op == WatchCreate && self.watcher.opts.Recursive {
+			if err = self.addWatch(event.Path); err != nil {
+				return err
+			}
+		}
+		if !send(event) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// 新的子目录: 监视它和它的子目录, 并且报告监视之前就已经在里面的文件.
+func (self *inotifySource) addTree(ctx context.Context, relPath string, send func(event *WatchEvent) bool) error {
+	sub := &Watcher{Root: self.watcher.fullPath(relPath), opts: self.watcher.opts}
+	sub.opts.Matcher = nil
+	return sub.walk(func(subPath string, entry fs.DirEntry) error {
+		fullRel := path.Join(relPath, subPath)
+		if subPath != "." && !self.watcher.match(fullRel, entry.IsDir()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if err := self.addWatch(fullRel); err != nil {
+				return err
+			}
+		}
+		if subPath != "." && !send(self.watcher.newEvent(WatchCreate, fullRel, entry.IsDir())) {
+			return ctx.Err()
+		}
+		return nil
+	})
+}
+
+// 目录被删除(或者移走)时, 移除它和它的子目录的监视.
+func (self *inotifySource) removeWatches(relPath string) {
+	for dir, wd := range self.wds {
+		if dir == relPath || strings.HasPrefix(dir, relPath+"/") {
+			syscall.InotifyRmWatch(self.fd, uint32(wd))
+			delete(self.wds, dir)
+			delete(self.dirs, wd)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//go:build linux
+
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 处理事件的fn阻塞时, 内核的事件队列会溢出, 丢失的变化要通过重新扫描找回来.
+func TestWatcherInotifyOverflow(t *testing.T) {
+	content, err := os.ReadFile("/proc/sys/fs/inotify/max_queued_events")
+	if err != nil {
+		t.Skip(err)
+	}
+	maxQueued, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || 100000 < maxQueued {
+		t.Skipf("max_queued_events=%v", strings.TrimSpace(string(content)))
+	}
+	count := maxQueued/2 + 1000 //每个文件至少有CREATE和CLOSE_WRITE两个事件
+
+	dir := t.TempDir()
+	blocked, release, allCreated := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	created := make(map[string]bool)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- NewWatcher(dir, &WatchOptions{Debounce: 10 * time.Millisecond}).Run(ctx, func(event *WatchEvent) error {
+			if event.Path == "start" {
+				close(blocked)
+				<-release //写文件的时候不读取事件
+				return nil
+			}
+			if event.Op == WatchCreate && !created[event.Path] {
+				created[event.Path] = true
+				if len(created) == count {
+					close(allCreated)
+				}
+			}
+			return nil
+		})
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond) //等待开始监视
+	os.WriteFile(filepath.Join(dir, "start"), nil, 0644)
+	<-blocked
+	for idx := 0; idx < count; idx++ {
+		if err = os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%06d", idx)), []byte("x"), 0644); err != nil {
+			close(release)
+			t.Fatal(err)
+		}
+	}
+	close(release)
+
+	select {
+	case <-allCreated:
+	case <-time.After(20 * time.Second):
+		t.Fatalf("timeout, not all %v files were reported", count)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package file
+
+// 其他平台上总是轮询.
+func newNativeSource(watcher *Watcher) (watchSource, error) {
+	return nil, errWatchUnsupported
+}
@@ -0,0 +1,108 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoalesce(t *testing.T) {
+	for _, c := range []struct {
+		prev, next string
+		op         string
+		keep       bool
+	}{
+		{WatchCreate, WatchWrite, WatchCreate, true},
+		{WatchCreate, WatchRemove, "", false},
+		{WatchWrite, WatchWrite, WatchWrite, true},
+		{WatchWrite, WatchRemove, WatchRemove, true},
+		{WatchRemove, WatchCreate, WatchWrite, true},
+	} {
+		op, keep := coalesce(c.prev, c.next)
+		if op != c.op || keep != c.keep {
+			t.Errorf("coalesce(%v, %v) = (%v, %v), want (%v, %v)", c.prev, c.next, op, keep, c.op, c.keep)
+		}
+	}
+}
+
+// 在新的goroutine中运行watcher, stop取消并等待它结束.
+func runWatcher(t *testing.T, watcher *Watcher) (events <-chan *WatchEvent, stop func()) {
+	ch := make(chan *WatchEvent, 1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Run(ctx, func(event *WatchEvent) error {
+			ch <- event
+			return nil
+		})
+	}()
+	time.Sleep(50 * time.Millisecond) //等待开始监视
+	return ch, func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// 收集事件, 直到quiet这么久没有新的事件.
+func collectEvents(events <-chan *WatchEvent, quiet time.Duration) []string {
+	results := make([]string, 0)
+	for {
+		select {
+		case event := <-events:
+			results = append(results, event.String())
+		case <-time.After(quiet):
+			return results
+		}
+	}
+}
+
+func expectEvents(t *testing.T, events <-chan *WatchEvent, quiet time.Duration, want ...string) {
+	t.Helper()
+	got := collectEvents(events, quiet)
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for idx := range want {
+		if got[idx] != want[idx] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestWatcherCoalesce(t *testing.T) {
+	dir := t.TempDir()
+	events, stop := runWatcher(t, NewWatcher(dir, &WatchOptions{Debounce: 300 * time.Millisecond, PollInterval: 50 * time.Millisecond}))
+	defer stop()
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("1"), 0644)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("12"), 0644) //CREATE之后的WRITE仍然是CREATE
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("1"), 0644)
+	os.Remove(filepath.Join(dir, "b.txt")) //CREATE之后又REMOVE, 什么也不输出
+	expectEvents(t, events, time.Second, (&WatchEvent{Op: WatchCreate, Path: "a.txt"}).String())
+}
+
+func TestWatcherPoll(t *testing.T) {
+	dir := t.TempDir()
+	events, stop := runWatcher(t, NewWatcher(dir, &WatchOptions{Recursive: true, ForcePoll: true, Debounce: 10 * time.Millisecond, PollInterval: 50 * time.Millisecond}))
+	defer stop()
+	quiet := 500 * time.Millisecond
+	event := func(op string, path string) string {
+		return (&WatchEvent{Op: op, Path: path}).String()
+	}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("1"), 0644)
+	expectEvents(t, events, quiet, event(WatchCreate, "a.txt"))
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("12"), 0644)
+	expectEvents(t, events, quiet, event(WatchWrite, "a.txt"))
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("1"), 0644)
+	expectEvents(t, events, quiet, event(WatchCreate, "sub"), event(WatchCreate, "sub/b.txt"))
+	os.Remove(filepath.Join(dir, "a.txt"))
+	expectEvents(t, events, quiet, event(WatchRemove, "a.txt"))
+	os.RemoveAll(filepath.Join(dir, "sub"))
+	expectEvents(t, events, quiet, event(WatchRemove, "sub"), event(WatchRemove, "sub/b.txt"))
+}
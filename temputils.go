@@ -1,79 +1,16 @@
 package zxgo
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
-	"strconv"
-	"strings"
+	"unsafe"
 )
 
 // 通过map修改data(中的各个字段)的值.
+// 为了兼容而保留: 只按字段名匹配(忽略tag), 忽略所有解析失败的字段. 新代码请使用BindByMap.
 func ModifyByMap(data interface{}, kvs map[string]string, upperKey bool) {
-	var cacheKvs map[string]string = nil
-	if upperKey {
-		cacheKvs = make(map[string]string, 0)
-		for k, v := range kvs { //TODO:怎么复制一个map.
-			k2 := strings.ToUpper(k)
-			cacheKvs[k2] = v
-		}
-	} else {
-		cacheKvs = kvs
-	}
-
-	elem := reflect.ValueOf(data).Elem()
-	elemType := elem.Type()
-
-	for i := 0; i < elem.NumField(); i++ {
-		field := elem.Field(i)
-		if !field.CanSet() { //一般情况下,变量首字母是小写的,不可Set.
-			continue
-		}
-		fieldKind := field.Kind()
-		fieldName := elemType.Field(i).Name
-		fieldNameFind := fieldName
-		if upperKey {
-			fieldNameFind = strings.ToUpper(fieldName)
-		}
-		if cacheV, isOk := cacheKvs[fieldNameFind]; isOk {
-			switch fieldKind {
-			case reflect.Bool:
-				if b, err := strconv.ParseBool(cacheV); err == nil {
-					field.SetBool(b)
-				}
-			case reflect.Int:
-				fallthrough
-			case reflect.Int8:
-				fallthrough
-			case reflect.Int16:
-				fallthrough
-			case reflect.Int32:
-				fallthrough
-			case reflect.Int64:
-				fallthrough
-			case reflect.Uint:
-				fallthrough
-			case reflect.Uint8:
-				fallthrough
-			case reflect.Uint16:
-				fallthrough
-			case reflect.Uint32:
-				fallthrough
-			case reflect.Uint64:
-				if i, err := strconv.ParseInt(cacheV, 10, 64); err == nil {
-					field.SetInt(i)
-				}
-			case reflect.Float32:
-				fallthrough
-			case reflect.Float64:
-				if f, err := strconv.ParseFloat(cacheV, 64); err == nil {
-					field.SetFloat(f)
-				}
-			case reflect.String:
-				field.SetString(cacheV)
-			default:
-				panic("unknown fieldKind=" + strconv.Itoa(int(fieldKind)))
-			}
-		}
-	}
+	BindByMap(data, kvs, &BindOptions{TagName: "-", UpperKey: upperKey})
 }
 
 //  使用例子:
@@ -87,6 +24,7 @@ func ModifyByMap(data interface{}, kvs map[string]string, upperKey bool) {
 //  	data := new(UserData)
 //  	fmt.Println(GuessFieldNameByOffset(data, unsafe.Offsetof(data.CreateTime), true))
 //  }
+// 只查找第一层的字段, 新代码请使用ResolveField(支持嵌套和匿名结构体, 不需要unsafe).
 func GuessFieldNameByOffset(data interface{}, offset uintptr, panicWhenError bool) string {
 	elem := reflect.ValueOf(data).Elem()
 	matchedAddr := elem.UnsafeAddr() + offset
@@ -103,3 +41,296 @@ func GuessFieldNameByOffset(data interface{}, offset uintptr, panicWhenError boo
 
 	return ""
 }
+
+type CopyOptions struct {
+	CopyUnexported bool //为true时(通过unsafe)也深拷贝未导出的字段, 否则未导出的字段保持零值.
+}
+
+// 深拷贝data, 返回值的类型和data相同. opts为nil时使用默认值.
+// 指针,map,slice的循环引用会保持原样(拷贝后仍然指向拷贝出来的对象); func和chan只拷贝引用; time.Time按值拷贝.
+// CopyUnexported为false时, 指向有未导出字段的结构体的指针(比如*regexp.Regexp)只拷贝引用, 以免得到一个未导出字段都是零值的不可用的对象(data本身除外).
+//  使用例子:
+//  cfgCopy := DeepCopy(cfg, nil).(*Config)
+func DeepCopy(data interface{}, opts *CopyOptions) interface{} {
+	if data == nil {
+		return nil
+	}
+	c := &copier{visited: make(map[copyKey]reflect.Value)}
+	if opts != nil {
+		c.opts = *opts
+	}
+	src := reflect.ValueOf(data)
+	dst := reflect.New(src.Type()).Elem()
+	if src.Kind() == reflect.Ptr && !src.IsNil() { //data本身总是深拷贝
+		c.copyPointer(dst, src)
+	} else {
+		c.copyValue(dst, src)
+	}
+	return dst.Interface()
+}
+
+// 使用默认的CopyOptions深拷贝v, 和DeepCopy不同的是, v是指针时同样遵守只拷贝引用的规则.
+func deepCopyValue(v reflect.Value) reflect.Value {
+	c := &copier{visited: make(map[copyKey]reflect.Value)}
+	dst := reflect.New(v.Type()).Elem()
+	c.copyValue(dst, v)
+	return dst
+}
+
+type copyKey struct {
+	ptr    uintptr
+	length int
+	t      reflect.Type
+}
+
+type copier struct {
+	opts    CopyOptions
+	visited map[copyKey]reflect.Value
+}
+
+// 得到未导出字段的可读写的Value(v必须可以取地址).
+func exposeField(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// t是有未导出字段的结构体(time.Time除外).
+func hasUnexportedField(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+// src是非nil的指针, dst是可以Set的零值.
+func (self *copier) copyPointer(dst, src reflect.Value) {
+	key := copyKey{src.Pointer(), 0, src.Type()}
+	if copied, ok := self.visited[key]; ok {
+		dst.Set(copied)
+		return
+	}
+	ptr := reflect.New(src.Type().Elem())
+	self.visited[key] = ptr
+	self.copyValue(ptr.Elem(), src.Elem())
+	dst.Set(ptr)
+}
+
+// dst是可以Set的零值.
+func (self *copier) copyValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		_, copied := self.visited[copyKey{src.Pointer(), 0, src.Type()}] //指向data本身等已经拷贝过的对象时, 使用拷贝
+		if !copied && !self.opts.CopyUnexported && hasUnexportedField(src.Type().Elem()) {
+			dst.Set(src)
+			return
+		}
+		self.copyPointer(dst, src)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		self.copyValue(elem, src.Elem())
+		dst.Set(elem)
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{src.Pointer(), 0, src.Type()}
+		if copied, ok := self.visited[key]; ok {
+			dst.Set(copied)
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		self.visited[key] = m
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			self.copyValue(k, iter.Key())
+			v := reflect.New(src.Type().Elem()).Elem()
+			self.copyValue(v, iter.Value())
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{src.Pointer(), src.Len(), src.Type()}
+		if copied, ok := self.visited[key]; ok {
+			dst.Set(copied)
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		self.visited[key] = s
+		for i := 0; i < src.Len(); i++ {
+			self.copyValue(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			self.copyValue(dst.Index(i), src.Index(i))
+		}
+	case reflect.Struct:
+		if src.Type() == timeType {
+			dst.Set(src)
+			return
+		}
+		if self.opts.CopyUnexported && !src.CanAddr() {
+			addressable := reflect.New(src.Type()).Elem()
+			addressable.Set(src)
+			src = addressable
+		}
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).IsExported() {
+				self.copyValue(dst.Field(i), src.Field(i))
+			} else if self.opts.CopyUnexported {
+				self.copyValue(exposeField(dst.Field(i)), exposeField(src.Field(i)))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+const (
+	MergeOverwrite    string = "Overwrite"    //src的非零值覆盖dst, slice整体替换
+	MergeKeepExisting string = "KeepExisting" //只填充dst中的零值
+	MergeAppend       string = "Append"       //同MergeOverwrite, 但是slice追加到dst的后面
+)
+
+type MergeOptions struct {
+	Strategy string //为空时使用MergeOverwrite
+}
+
+// 把src合并到dst(指针)中, 用于合并多层的配置. src和dst的类型必须相同(src可以是指针也可以是值).
+// 结构体和map会递归合并, src中的零值(包括nil)不会覆盖dst, 写入dst的值都是深拷贝(规则见DeepCopy). 未导出的字段被忽略.
+// dst里面的指针,map和slice可能和别的值共享, 所以合并时先复制一份(dst本身除外), 不会修改它们原来指向的对象. 支持循环引用.
+func Merge(dst interface{}, src interface{}, opts *MergeOptions) error {
+	strategy := MergeOverwrite
+	if opts != nil && len(opts.Strategy) != 0 {
+		strategy = opts.Strategy
+	}
+	if strategy != MergeOverwrite && strategy != MergeKeepExisting && strategy != MergeAppend {
+		return errors.New(fmt.Sprintf("unknown merge strategy=%v", strategy))
+	}
+
+	dstValue := reflect.ValueOf(dst)
+	if dstValue.Kind() != reflect.Ptr || dstValue.IsNil() {
+		return errors.New(fmt.Sprintf("dst must be a non-nil pointer, type=%T", dst))
+	}
+	srcValue := reflect.ValueOf(src)
+	if !srcValue.IsValid() { //src是nil
+		return nil
+	}
+	if srcValue.Type() == dstValue.Type() {
+		if srcValue.IsNil() {
+			return nil
+		}
+		srcValue = srcValue.Elem()
+	}
+	if srcValue.Type() != dstValue.Type().Elem() {
+		return errors.New(fmt.Sprintf("src and dst must be the same type, %T != %T", src, dst))
+	}
+	m := &merger{strategy: strategy, visited: make(map[mergeKey]reflect.Value)}
+	m.mergeValue(dstValue.Elem(), srcValue)
+	return nil
+}
+
+// src和dst的一对指针(或者map), 合并过一次之后, 再遇到时使用同一个结果(循环引用).
+type mergeKey struct {
+	src uintptr
+	dst uintptr
+	t   reflect.Type
+}
+
+type merger struct {
+	strategy string
+	visited  map[mergeKey]reflect.Value
+}
+
+func (self *merger) mergeValue(dst, src reflect.Value) {
+	if src.IsZero() {
+		return
+	}
+
+	switch src.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(deepCopyValue(src))
+			return
+		}
+		if !hasUnexportedField(src.Type().Elem()) {
+			key := mergeKey{src.Pointer(), dst.Pointer(), src.Type()}
+			if merged, ok := self.visited[key]; ok {
+				dst.Set(merged)
+				return
+			}
+			ptr := reflect.New(src.Type().Elem()) //合并到副本里, 不修改dst原来指向的对象
+			ptr.Elem().Set(dst.Elem())
+			self.visited[key] = ptr
+			self.mergeValue(ptr.Elem(), src.Elem())
+			dst.Set(ptr)
+			return
+		}
+		//有未导出字段的结构体(比如*regexp.Regexp)不能逐个字段合并, 整体替换
+	case reflect.Struct:
+		if src.Type() == timeType {
+			break
+		}
+		for i := 0; i < src.NumField(); i++ {
+			if src.Type().Field(i).IsExported() {
+				self.mergeValue(dst.Field(i), src.Field(i))
+			}
+		}
+		return
+	case reflect.Map:
+		if dst.IsNil() {
+			dst.Set(deepCopyValue(src))
+			return
+		}
+		key := mergeKey{src.Pointer(), dst.Pointer(), src.Type()}
+		if merged, ok := self.visited[key]; ok {
+			dst.Set(merged)
+			return
+		}
+		m := reflect.MakeMapWithSize(dst.Type(), dst.Len()) //合并到副本里, 不修改dst原来的map
+		for iter := dst.MapRange(); iter.Next(); {
+			m.SetMapIndex(iter.Key(), iter.Value())
+		}
+		self.visited[key] = m
+		iter := src.MapRange()
+		for iter.Next() {
+			current := m.MapIndex(iter.Key())
+			if !current.IsValid() {
+				m.SetMapIndex(iter.Key(), deepCopyValue(iter.Value()))
+				continue
+			}
+			elem := reflect.New(current.Type()).Elem() //map的元素不能取地址, 合并副本之后再放回去
+			elem.Set(current)
+			self.mergeValue(elem, iter.Value())
+			m.SetMapIndex(iter.Key(), elem)
+		}
+		dst.Set(m)
+		return
+	case reflect.Slice:
+		if self.strategy == MergeAppend {
+			s := reflect.MakeSlice(dst.Type(), 0, dst.Len()+src.Len()) //不能写入dst后面共享的空间
+			s = reflect.AppendSlice(s, dst)
+			dst.Set(reflect.AppendSlice(s, deepCopyValue(src)))
+			return
+		}
+	}
+
+	if self.strategy == MergeKeepExisting && !dst.IsZero() {
+		return
+	}
+	dst.Set(deepCopyValue(src))
+}
@@ -0,0 +1,166 @@
+package zxgo
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+type copyNode struct {
+	Name     string
+	Next     *copyNode
+	Children []*copyNode
+	Attrs    map[string]*copyNode
+	Pattern  *regexp.Regexp
+	Hidden   copyHidden
+}
+
+type copyHidden struct {
+	Value  int
+	hidden int
+}
+
+func TestDeepCopy(t *testing.T) {
+	shared := &copyNode{Name: "shared"}
+	src := &copyNode{
+		Name:     "root",
+		Children: []*copyNode{shared, {Name: "child"}},
+		Attrs:    map[string]*copyNode{"a": shared},
+		Pattern:  regexp.MustCompile(`^a+$`),
+		Hidden:   copyHidden{Value: 1, hidden: 2},
+	}
+	src.Next = src //循环引用
+
+	dst := DeepCopy(src, nil).(*copyNode)
+	if dst == src || dst.Next != dst {
+		t.Fatalf("cycle is not preserved, dst=%p, dst.Next=%p", dst, dst.Next)
+	}
+	if dst.Children[0] == shared || dst.Children[0] != dst.Attrs["a"] {
+		t.Fatal("shared pointer is not copied once")
+	}
+	if dst.Pattern != src.Pattern || !dst.Pattern.MatchString("aa") {
+		t.Fatal("pointer to struct with unexported fields is not shared")
+	}
+	if dst.Hidden != (copyHidden{Value: 1}) {
+		t.Fatalf("unexported field is copied, Hidden=%+v", dst.Hidden)
+	}
+
+	dst.Children[1].Name = "changed"
+	dst.Attrs["b"] = nil
+	if src.Children[1].Name != "child" || len(src.Attrs) != 1 {
+		t.Fatal("copy shares data with src")
+	}
+
+	withUnexported := DeepCopy(src, &CopyOptions{CopyUnexported: true}).(*copyNode)
+	if withUnexported.Hidden != src.Hidden || withUnexported.Pattern == src.Pattern || !withUnexported.Pattern.MatchString("aa") {
+		t.Fatalf("CopyUnexported, Hidden=%+v", withUnexported.Hidden)
+	}
+
+	type slices struct{ All, Same []int }
+	all := []int{1, 2, 3}
+	copied := DeepCopy(slices{All: all, Same: all}, nil).(slices)
+	copied.All[0] = 9
+	if all[0] != 1 || copied.Same[0] != 9 {
+		t.Fatalf("all=%v, copied=%+v", all, copied)
+	}
+
+	if !reflect.DeepEqual(DeepCopy(map[string][]int{"x": {1}}, nil), map[string][]int{"x": {1}}) || DeepCopy(nil, nil) != nil {
+		t.Fatal("DeepCopy of map or nil")
+	}
+}
+
+type mergeDb struct {
+	Host string
+	Port int
+}
+
+type mergeConfig struct {
+	Name   string
+	Debug  bool
+	Tags   []string
+	Labels map[string]string
+	Db     *mergeDb
+	Next   *mergeConfig
+}
+
+func TestMerge(t *testing.T) {
+	newDst := func() *mergeConfig {
+		return &mergeConfig{Name: "base", Tags: []string{"a"}, Labels: map[string]string{"x": "1"}, Db: &mergeDb{Host: "localhost", Port: 3306}}
+	}
+	src := mergeConfig{Debug: true, Tags: []string{"b"}, Labels: map[string]string{"y": "2"}, Db: &mergeDb{Port: 3307}}
+
+	cases := []struct {
+		strategy string
+		want     mergeConfig
+	}{
+		{MergeOverwrite, mergeConfig{Name: "base", Debug: true, Tags: []string{"b"}, Labels: map[string]string{"x": "1", "y": "2"}, Db: &mergeDb{Host: "localhost", Port: 3307}}},
+		{MergeKeepExisting, mergeConfig{Name: "base", Debug: true, Tags: []string{"a"}, Labels: map[string]string{"x": "1", "y": "2"}, Db: &mergeDb{Host: "localhost", Port: 3306}}},
+		{MergeAppend, mergeConfig{Name: "base", Debug: true, Tags: []string{"a", "b"}, Labels: map[string]string{"x": "1", "y": "2"}, Db: &mergeDb{Host: "localhost", Port: 3307}}},
+	}
+	for _, c := range cases {
+		dst := newDst()
+		if err := Merge(dst, src, &MergeOptions{Strategy: c.strategy}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*dst, c.want) {
+			t.Errorf("%v: got %+v, want %+v", c.strategy, dst, c.want)
+		}
+		if dst.Db == src.Db || (c.strategy == MergeOverwrite && &dst.Tags[0] == &src.Tags[0]) {
+			t.Errorf("%v: dst shares data with src", c.strategy)
+		}
+	}
+
+	if err := Merge(newDst(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := Merge(newDst(), &mergeDb{}, nil); err == nil {
+		t.Fatal("different types are not rejected")
+	}
+	if err := Merge(newDst(), src, &MergeOptions{Strategy: "unknown"}); err == nil {
+		t.Fatal("unknown strategy is not rejected")
+	}
+}
+
+// dst里的指针,map和slice可能和别的值共享, 合并不能修改它们.
+func TestMergeShared(t *testing.T) {
+	defaults := &mergeDb{Host: "localhost", Port: 3306}
+	labels := map[string]string{"x": "1"}
+	tags := make([]string, 1, 4) //有多余的容量, append会写到共享的数组里
+	tags[0] = "a"
+	dst := &mergeConfig{Db: defaults, Labels: labels, Tags: tags}
+	other := &mergeConfig{Db: defaults, Labels: labels, Tags: tags}
+
+	src := &mergeConfig{Db: &mergeDb{Port: 3307}, Labels: map[string]string{"y": "2"}, Tags: []string{"b"}}
+	if err := Merge(dst, src, &MergeOptions{Strategy: MergeAppend}); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Db.Port != 3307 || len(dst.Labels) != 2 || !reflect.DeepEqual(dst.Tags, []string{"a", "b"}) {
+		t.Fatalf("dst=%+v", dst)
+	}
+	if defaults.Port != 3306 || len(labels) != 1 || tags[:2][1] != "" || other.Db.Port != 3306 {
+		t.Fatalf("shared data is modified, defaults=%+v, labels=%v, tags=%v", defaults, labels, tags[:2])
+	}
+}
+
+func TestMergeCycle(t *testing.T) {
+	dst := &mergeConfig{Name: "dst"}
+	dst.Next = dst
+	src := &mergeConfig{Debug: true}
+	src.Next = src
+
+	if err := Merge(dst, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !dst.Debug || dst.Next == nil || !dst.Next.Debug || dst.Next.Next != dst.Next || dst.Next.Name != "dst" {
+		t.Fatalf("dst=%+v, dst.Next=%+v", dst, dst.Next)
+	}
+
+	//dst里没有的循环引用, 深拷贝过来
+	empty := &mergeConfig{}
+	if err := Merge(empty, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if empty.Next == src || empty.Next.Next != empty.Next {
+		t.Fatalf("empty.Next=%p, src=%p", empty.Next, src)
+	}
+}
@@ -0,0 +1,258 @@
+package zxconfig
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	INI  string = "ini"
+	JSON string = "json"
+	YAML string = "yaml"
+)
+
+// 根据扩展名猜测文件格式, 无法识别时返回空字符串.
+func GuessFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ini", ".conf", ".cfg":
+		return INI
+	case ".json":
+		return JSON
+	case ".yaml", ".yml":
+		return YAML
+	default:
+		return ""
+	}
+}
+
+// 读取配置文件, 返回"A.B"形式的key和字符串形式的值, format为空时根据扩展名判断.
+func ParseFile(filename, format string, separator string) (kvs map[string]string, err error) {
+	if len(format) == 0 {
+		if format = GuessFormat(filename); len(format) == 0 {
+			err = errors.New(fmt.Sprintf("Unknown config format, filename=%v", filename))
+			return
+		}
+	}
+
+	var content []byte
+	if content, err = os.ReadFile(filename); err != nil {
+		return
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")) //UTF-8 BOM
+
+	switch format {
+	case INI:
+		kvs, err = ParseINI(bytes.NewReader(content))
+	case JSON:
+		kvs, err = ParseJSON(bytes.NewReader(content), separator)
+	case YAML:
+		kvs, err = ParseYAML(bytes.NewReader(content), separator)
+	default:
+		err = errors.New(fmt.Sprintf("Unknown config format=%v", format))
+	}
+	if err != nil {
+		err = errors.New(fmt.Sprintf("%v: %v", filename, err))
+	}
+	return
+}
+
+func unquote(value string) string {
+	if 2 <= len(value) {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' && last == '"') || (first == '\'' && last == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// INI格式: "[Section]"下面的"Key = Value"的key是"Section.Key", 以";"或"#"开头的行是注释.
+func ParseINI(reader io.Reader) (kvs map[string]string, err error) {
+	kvs = make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(reader)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		idx := strings.IndexAny(line, "=:")
+		if idx <= 0 {
+			err = errors.New(fmt.Sprintf("lineNum=%v, illegal line=%v", lineNum, line))
+			return
+		}
+		key := strings.TrimSpace(line[:idx])
+		if len(section) != 0 {
+			key = section + "." + key
+		}
+		kvs[key] = unquote(strings.TrimSpace(line[idx+1:]))
+	}
+	err = scanner.Err()
+	return
+}
+
+// JSON格式: 嵌套的对象展开成"A.B", 数组用separator连接成一个字符串.
+func ParseJSON(reader io.Reader, separator string) (kvs map[string]string, err error) {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber() //避免大整数变成float64
+	var root map[string]interface{}
+	if err = decoder.Decode(&root); err != nil {
+		return
+	}
+	kvs = make(map[string]string)
+	err = flattenJSON(root, "", separator, kvs)
+	return
+}
+
+func flattenJSON(obj map[string]interface{}, prefix, separator string, kvs map[string]string) error {
+	for key, value := range obj {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flattenJSON(v, prefix+key+".", separator, kvs); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				switch item.(type) {
+				case map[string]interface{}, []interface{}:
+					return errors.New(fmt.Sprintf("nested array or object is not supported, key=%v", prefix+key))
+				}
+				items = append(items, scalarString(item))
+			}
+			kvs[prefix+key] = strings.Join(items, separator)
+		case nil:
+			//null表示不设置
+		default:
+			kvs[prefix+key] = scalarString(v)
+		}
+	}
+	return nil
+}
+
+func scalarString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// YAML的一个简单子集:
+//
+//	# 注释
+//	Name: zx
+//	Db:
+//	  Port: 3306
+//	Tags:
+//	  - a
+//	  - b
+//	Nums: [1, 2, 3]
+//
+// 缩进表示嵌套(key用"."连接), "- item"形式的列表用separator连接. 不支持多行字符串,锚点等.
+func ParseYAML(reader io.Reader, separator string) (kvs map[string]string, err error) {
+	kvs = make(map[string]string)
+
+	type level struct {
+		indent int
+		key    string
+	}
+	stack := make([]level, 0)
+	lists := make(map[string][]string)
+
+	scanner := bufio.NewScanner(reader)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		raw := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(raw, " ")
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			err = errors.New(fmt.Sprintf("lineNum=%v, tab is not allowed for indentation", lineNum))
+			return
+		}
+		indent := len(raw) - len(trimmed)
+		trimmed = stripYAMLComment(trimmed)
+
+		isItem := strings.HasPrefix(trimmed, "- ") || trimmed == "-"
+		//列表项可以和它的key对齐(比如"Tags:"的下一行是"- a").
+		for 0 < len(stack) && (indent < stack[len(stack)-1].indent || (!isItem && indent == stack[len(stack)-1].indent)) {
+			stack = stack[:len(stack)-1]
+		}
+
+		if isItem {
+			if len(stack) == 0 {
+				err = errors.New(fmt.Sprintf("lineNum=%v, list item without key", lineNum))
+				return
+			}
+			parent := stack[len(stack)-1].key
+			lists[parent] = append(lists[parent], unquote(strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))))
+			continue
+		}
+
+		idx := strings.Index(trimmed, ":")
+		if idx <= 0 {
+			err = errors.New(fmt.Sprintf("lineNum=%v, illegal line=%v", lineNum, trimmed))
+			return
+		}
+		key := unquote(strings.TrimSpace(trimmed[:idx]))
+		value := strings.TrimSpace(trimmed[idx+1:])
+		if 0 < len(stack) {
+			key = stack[len(stack)-1].key + "." + key
+		}
+
+		if len(value) == 0 { //下面是嵌套的对象或者列表
+			stack = append(stack, level{indent, key})
+			continue
+		}
+		if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+			items := make([]string, 0)
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); len(item) != 0 {
+					items = append(items, unquote(item))
+				}
+			}
+			value = strings.Join(items, separator)
+		} else {
+			value = unquote(value)
+		}
+		kvs[key] = value
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+
+	for key, items := range lists {
+		kvs[key] = strings.Join(items, separator)
+	}
+	return
+}
+
+// 去掉行尾的" #注释"(引号里面的不算).
+func stripYAMLComment(line string) string {
+	var quote byte = 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && 0 < i && (line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return line
+}
@@ -0,0 +1,266 @@
+package zxconfig
+
+/*
+从多个来源填充一个配置结构体(通过zxgo.BindByMap), 优先级从低到高:
+	结构体的初始值 < default tag < 配置文件(按顺序) < 环境变量 < 命令行参数
+支持的tag:
+	zx:"name,required"  key的名字(规则同zxgo.BindByMap), required表示必须由某个来源提供
+	default:"9999"      默认值
+	help:"listen port"  帮助信息
+key不区分大小写("Labels.k1"这样逐个指定的map元素, map的key保持优先级最高的来源里的写法). 例如key为"Db.Port"时:
+	配置文件: INI的[Db]下面的Port, JSON/YAML的 {"Db": {"Port": 3306}}
+	环境变量: 前缀_DB_PORT
+	命令行  : -db.port
+使用例子:
+	cfg := new(Config)
+	loader := zxconfig.NewLoader("myApp")
+	loader.EnvPrefix = "MYAPP"
+	loader.Files = []string{"myApp.yaml"}
+	if err := loader.Load(cfg); err == flag.ErrHelp {
+		fmt.Print(loader.Help(cfg))
+		return
+	} else if err != nil {
+		...
+	}
+*/
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/zx9229/zxgo"
+)
+
+type Loader struct {
+	Name          string                          //程序名, 用于帮助信息
+	Files         []string                        //依次加载, 后面的覆盖前面的
+	IgnoreMissing bool                            //配置文件不存在时跳过
+	Strict        bool                            //配置文件里有结构体中不存在的key时报错
+	EnvPrefix     string                          //为空时不读取环境变量
+	Args          []string                        //命令行参数, 为nil时不解析命令行
+	ConfigFlag    string                          //非空时增加一个这个名字的命令行参数, 用来追加配置文件(可以出现多次)
+	Separator     string                          //slice的分隔符, 为空时使用","
+	LookupEnv     func(key string) (string, bool) //为nil时使用os.LookupEnv
+	sources       map[string]string
+}
+
+// 默认解析os.Args[1:], 并且可以用 -config 指定配置文件.
+func NewLoader(name string) *Loader {
+	return &Loader{Name: name, Args: os.Args[1:], ConfigFlag: "config", Separator: ","}
+}
+
+type keyInfo struct {
+	*zxgo.FieldKey
+	required bool
+	dflt     string
+	hasDflt  bool
+	help     string
+	isBool   bool
+}
+
+func (self *keyInfo) flagName() string {
+	return strings.ToLower(self.Key)
+}
+
+func (self *Loader) envName(key string) string {
+	return strings.ToUpper(self.EnvPrefix + "_" + strings.Replace(key, ".", "_", -1))
+}
+
+func collectKeys(cfg interface{}) []*keyInfo {
+	infos := make([]*keyInfo, 0)
+	for _, fieldKey := range zxgo.FieldKeys(cfg, "") {
+		info := &keyInfo{FieldKey: fieldKey}
+		options := strings.Split(fieldKey.Field.Tag.Get(zxgo.DefaultTagName), ",")[1:]
+		for _, option := range options {
+			if option == "required" {
+				info.required = true
+			}
+		}
+		info.dflt, info.hasDflt = fieldKey.Field.Tag.Lookup("default")
+		info.help = fieldKey.Field.Tag.Get("help")
+		fieldType := fieldKey.Field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		info.isBool = fieldType.Kind() == reflect.Bool
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// 返回key的值来自哪里(比如"default","file:a.yaml","env:MYAPP_PORT","flag:-port"), 未设置时为空字符串.
+func (self *Loader) Source(key string) string {
+	return self.sources[strings.ToUpper(key)]
+}
+
+func (self *Loader) separator() string {
+	if len(self.Separator) == 0 {
+		return ","
+	}
+	return self.Separator
+}
+
+func (self *Loader) newFlagSet(cfg interface{}, infos []*keyInfo, configFiles *[]string) *flag.FlagSet {
+	fs := flag.NewFlagSet(self.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+
+	current, _ := zxgo.EncodeToMap(cfg, &zxgo.EncodeOptions{UpperKey: true, Separator: self.separator()})
+	for _, info := range infos {
+		usage := info.help
+		if info.required {
+			usage += " (required)"
+		}
+		if len(self.EnvPrefix) != 0 {
+			usage += " (env: " + self.envName(info.Key) + ")"
+		}
+		usage = strings.TrimSpace(usage)
+		dflt := current[strings.ToUpper(info.Key)]
+		if info.hasDflt {
+			dflt = info.dflt
+		}
+		if info.isBool {
+			fs.Bool(info.flagName(), dflt == "true", usage)
+		} else {
+			fs.String(info.flagName(), dflt, usage)
+		}
+	}
+	if len(self.ConfigFlag) != 0 && fs.Lookup(self.ConfigFlag) == nil {
+		fs.Func(self.ConfigFlag, "load config file (ini/json/yaml), can be repeated", func(value string) error {
+			*configFiles = append(*configFiles, value)
+			return nil
+		})
+	}
+	return fs
+}
+
+// 生成帮助信息.
+func (self *Loader) Help(cfg interface{}) string {
+	var configFiles []string
+	fs := self.newFlagSet(cfg, collectKeys(cfg), &configFiles)
+	var buffer bytes.Buffer
+	fs.SetOutput(&buffer)
+	if len(self.Name) != 0 {
+		fmt.Fprintf(&buffer, "Usage of %v:\n", self.Name)
+	} else {
+		fmt.Fprintf(&buffer, "Usage:\n")
+	}
+	fs.PrintDefaults()
+	return buffer.String()
+}
+
+// 按优先级填充cfg(结构体指针). 命令行里有-help(且cfg中没有help字段)时返回flag.ErrHelp.
+func (self *Loader) Load(cfg interface{}) (err error) {
+	infos := collectKeys(cfg)
+	kvs := make(map[string]string)
+	spelled := make(map[string]string) //大写的key => kvs里的key
+	self.sources = make(map[string]string)
+	set := func(key, value, source string) {
+		upper := strings.ToUpper(key)
+		if old, ok := spelled[upper]; ok {
+			delete(kvs, old)
+		}
+		spelled[upper] = key
+		kvs[key] = value
+		self.sources[upper] = source
+	}
+
+	for _, info := range infos {
+		if info.hasDflt {
+			set(info.Key, info.dflt, "default")
+		}
+	}
+
+	//先解析命令行(为了拿到-config), 但最后才使用它的值.
+	configFiles := append([]string{}, self.Files...)
+	var fs *flag.FlagSet
+	if self.Args != nil {
+		fs = self.newFlagSet(cfg, infos, &configFiles)
+		if err = fs.Parse(self.Args); err != nil {
+			return
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, info := range infos {
+		known[strings.ToUpper(info.Key)] = true
+	}
+	for _, filename := range configFiles {
+		var fileKvs map[string]string
+		if fileKvs, err = ParseFile(filename, "", self.separator()); err != nil {
+			if self.IgnoreMissing && errors.Is(err, os.ErrNotExist) {
+				err = nil
+				continue
+			}
+			return
+		}
+		unknown := make([]string, 0)
+		for key, value := range fileKvs {
+			if !known[strings.ToUpper(key)] && !self.isMapEntry(infos, key) {
+				unknown = append(unknown, key)
+				continue
+			}
+			set(key, value, "file:"+filename)
+		}
+		if self.Strict && len(unknown) != 0 {
+			sort.Strings(unknown)
+			err = errors.New(fmt.Sprintf("%v: unknown keys %v", filename, unknown))
+			return
+		}
+	}
+
+	if len(self.EnvPrefix) != 0 {
+		lookupEnv := self.LookupEnv
+		if lookupEnv == nil {
+			lookupEnv = os.LookupEnv
+		}
+		for _, info := range infos {
+			if value, ok := lookupEnv(self.envName(info.Key)); ok {
+				set(info.Key, value, "env:"+self.envName(info.Key))
+			}
+		}
+	}
+
+	if fs != nil {
+		byFlag := make(map[string]*keyInfo)
+		for _, info := range infos {
+			byFlag[info.flagName()] = info
+		}
+		fs.Visit(func(f *flag.Flag) {
+			if info, ok := byFlag[f.Name]; ok {
+				set(info.Key, f.Value.String(), "flag:-"+f.Name)
+			}
+		})
+	}
+
+	missing := make([]string, 0)
+	for _, info := range infos {
+		if _, ok := spelled[strings.ToUpper(info.Key)]; info.required && !ok {
+			missing = append(missing, info.Key)
+		}
+	}
+	if len(missing) != 0 {
+		err = errors.New(fmt.Sprintf("missing required config %v", missing))
+		return
+	}
+
+	err = zxgo.BindByMap(cfg, kvs, &zxgo.BindOptions{UpperKey: true, Separator: self.separator()})
+	return
+}
+
+// "Labels.k1"这样的key属于map字段Labels.
+func (self *Loader) isMapEntry(infos []*keyInfo, key string) bool {
+	key = strings.ToUpper(key)
+	for _, info := range infos {
+		if info.Field.Type.Kind() == reflect.Map && strings.HasPrefix(key, strings.ToUpper(info.Key)+".") {
+			return true
+		}
+	}
+	return false
+}
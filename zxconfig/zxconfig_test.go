@@ -0,0 +1,40 @@
+package zxconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type testConfig struct {
+	Port   int               `default:"80"`
+	Labels map[string]string `zx:"labels"`
+}
+
+func TestLoadMapEntries(t *testing.T) {
+	dir := t.TempDir()
+	base, local := filepath.Join(dir, "base.json"), filepath.Join(dir, "local.json")
+	if err := os.WriteFile(base, []byte(`{"PORT": 8080, "Labels": {"MixedCase": "a", "Other": "b"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(local, []byte(`{"labels": {"mixedcase": "c"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := new(testConfig)
+	loader := &Loader{Files: []string{base, local}, Strict: true}
+	if err := loader.Load(cfg); err != nil {
+		t.Fatal(err)
+	}
+	//key不区分大小写, 后面的文件覆盖前面的, map的key保持覆盖它的那个文件里的写法
+	if cfg.Port != 8080 || !reflect.DeepEqual(cfg.Labels, map[string]string{"mixedcase": "c", "Other": "b"}) {
+		t.Fatalf("cfg=%+v", cfg)
+	}
+	if source := loader.Source("Labels.MixedCase"); source != "file:"+local {
+		t.Fatalf("source=%v", source)
+	}
+	if source := loader.Source("port"); source != "file:"+base {
+		t.Fatalf("source=%v", source)
+	}
+}
@@ -0,0 +1,218 @@
+package zxmatch
+
+// glob的语法(路径分隔符是"/", Windows的路径请先用filepath.ToSlash转换):
+//	*       不包含"/"的任意字符串
+//	**      作为完整的一段时("**/", "/**/", "/**")匹配任意层目录, 否则同"*"
+//	?       不是"/"的一个字符
+//	[abc]   字符集合, [!abc]或[^abc]表示取反, 可以使用范围[a-z]
+//	{a,b}   多选一, 可以嵌套: {*.go,doc/{a,b}.md}
+//	\x      转义, x按字面意思匹配
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/zx9229/zxgo/zxre"
+)
+
+// 把glob翻译成正则表达式(不含"^"和"$").
+func translate(glob string) (string, error) {
+	var builder strings.Builder
+	runes := []rune(glob)
+	depth := 0 //花括号的深度
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch c {
+		case '\\':
+			if i+1 >= len(runes) {
+				return "", errors.New(fmt.Sprintf("trailing backslash, glob=%v", glob))
+			}
+			i++
+			builder.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				atStart := i == 0 || runes[i-1] == '/'
+				j := i + 2
+				for j < len(runes) && runes[j] == '*' {
+					j++
+				}
+				if atStart && j < len(runes) && runes[j] == '/' {
+					builder.WriteString("(?:.*/)?")
+					i = j
+					continue
+				}
+				if atStart && j == len(runes) {
+					builder.WriteString(".*")
+					i = j - 1
+					continue
+				}
+				i = j - 1
+			}
+			builder.WriteString("[^/]*")
+		case '?':
+			builder.WriteString("[^/]")
+		case '[':
+			j := i + 1
+			negate := false
+			if j < len(runes) && (runes[j] == '!' || runes[j] == '^') {
+				negate = true
+				j++
+			}
+			var class strings.Builder
+			for first := true; j < len(runes) && (first || runes[j] != ']'); j++ {
+				first = false
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+					if unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) {
+						class.WriteRune(runes[j])
+					} else {
+						class.WriteString(`\` + string(runes[j]))
+					}
+					continue
+				}
+				if runes[j] == '-' { //不是范围时转义, 否则"[!-a]"会变成"[^/-a]"
+					if 0 < class.Len() && j+1 < len(runes) && runes[j+1] != ']' {
+						class.WriteRune('-')
+					} else {
+						class.WriteString(`\-`)
+					}
+					continue
+				}
+				class.WriteString(regexp.QuoteMeta(string(runes[j])))
+			}
+			if j >= len(runes) {
+				return "", errors.New(fmt.Sprintf("unclosed [, glob=%v", glob))
+			}
+			if negate {
+				builder.WriteString("[^/" + class.String() + "]")
+			} else {
+				builder.WriteString("[" + class.String() + "]")
+			}
+			i = j
+		case '{':
+			depth++
+			builder.WriteString("(?:")
+		case ',':
+			if 0 < depth {
+				builder.WriteString("|")
+			} else {
+				builder.WriteString(",")
+			}
+		case '}':
+			if depth == 0 {
+				builder.WriteString(`\}`)
+				continue
+			}
+			depth--
+			builder.WriteString(")")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if depth != 0 {
+		return "", errors.New(fmt.Sprintf("unclosed {, glob=%v", glob))
+	}
+	return builder.String(), nil
+}
+
+// 把glob翻译成匹配整个路径的正则表达式.
+func GlobToRegexp(glob string) (string, error) {
+	expr, err := translate(glob)
+	if err != nil {
+		return "", err
+	}
+	return "^" + expr + "$", nil
+}
+
+// 编译glob, 语法错误时返回error.
+func CompileGlob(glob string) (*regexp.Regexp, error) {
+	pattern, err := CompileGlobPattern(glob)
+	if err != nil {
+		return nil, err
+	}
+	return pattern.Regexp, nil
+}
+
+// 编译glob得到zxre.Pattern(会使用zxre的缓存).
+func CompileGlobPattern(glob string) (*zxre.Pattern, error) {
+	expr, err := GlobToRegexp(glob)
+	if err != nil {
+		return nil, err
+	}
+	return zxre.Compile(expr)
+}
+
+// path(用"/"分隔)是否匹配glob.
+func MatchGlob(glob string, path string) (bool, error) {
+	pattern, err := CompileGlobPattern(glob)
+	if err != nil {
+		return false, err
+	}
+	return pattern.MatchString(path), nil
+}
+
+// pattern[i]是"[", 返回和它配对的"]"的位置(规则同translate), 没有时返回-1.
+func classEnd(pattern string, i int) int {
+	j := i + 1
+	if j < len(pattern) && (pattern[j] == '!' || pattern[j] == '^') {
+		j++
+	}
+	for first := true; j < len(pattern) && (first || pattern[j] != ']'); j++ {
+		first = false
+		if pattern[j] == '\\' {
+			j++
+		}
+	}
+	if j >= len(pattern) {
+		return -1
+	}
+	return j
+}
+
+// 展开花括号, 比如"a{b,c{d,e}}f"得到["abf", "acdf", "acef"]. 没有花括号时返回[pattern].
+func ExpandBraces(pattern string) []string {
+	start, depth := -1, 0
+	commas := make([]int, 0)
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '[': //字符集合中的"{"和","是字面量
+			if end := classEnd(pattern, i); 0 <= end {
+				i = end
+			}
+		case '{':
+			if depth == 0 {
+				start = i
+				commas = commas[:0]
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth != 0 {
+				continue
+			}
+			if len(commas) == 0 { //"{a}"不是多选一, 保持原样
+				start = -1
+				continue
+			}
+			prefix, suffix := pattern[:start], pattern[i+1:]
+			bounds := append(append([]int{start}, commas...), i)
+			results := make([]string, 0)
+			for k := 0; k+1 < len(bounds); k++ {
+				results = append(results, ExpandBraces(prefix+pattern[bounds[k]+1:bounds[k+1]]+suffix)...)
+			}
+			return results
+		}
+	}
+	return []string{pattern}
+}
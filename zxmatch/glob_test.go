@@ -0,0 +1,114 @@
+package zxmatch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"*.go", "a.go", true},
+		{"*.go", "dir/a.go", false},
+		{"a?c", "abc", true},
+		{"a?c", "a/c", false},
+		{"a/**", "a/b", true},
+		{"a/**", "a/b/c.go", true},
+		{"a/**", "a", false},
+		{"**/b", "b", true},
+		{"**/b", "x/y/b", true},
+		{"**/b", "x/yb", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "ab", false},
+		{"a**b", "axxb", true}, //不是完整的一段, 同"*"
+		{"a**b", "a/b", false},
+		{"**", "a/b/c", true},
+		{"[abc].go", "b.go", true},
+		{"[a-c].go", "d.go", false},
+		{"[!a-].go", "b.go", true},
+		{"[!a-].go", "a.go", false},
+		{"[!a-].go", "-.go", false},
+		{"[!a-].go", "/.go", false},
+		{"[^a].go", "b.go", true},
+		{"[!-a].go", "b.go", true},
+		{"[!-a].go", "-.go", false},
+		{"[!-a].go", "0.go", true}, //"-"是字面量, 不是从"/"开始的范围
+		{"[-a].go", "-.go", true},
+		{"[]].go", "].go", true},
+		{"[!]].go", "].go", false},
+		{`[\]].go`, "].go", true},
+		{`[a\-z].go`, "b.go", false},
+		{`[a\-z].go`, "-.go", true},
+		{"[.]go", ".go", true},
+		{"[.]go", "ago", false},
+		{"{a,{b,c}}.go", "c.go", true},
+		{"{a,{b,c}}.go", "d.go", false},
+		{"{*.go,doc/{a,b}.md}", "doc/b.md", true},
+		{"{*.go,doc/{a,b}.md}", "doc/x.go", false},
+		{"{[,]a,b}", ",a", true},
+		{"a,b", "a,b", true},
+		{"a}", "a}", true},
+		{`\*.go`, "*.go", true},
+		{`\*.go`, "a.go", false},
+		{`a\ `, "a ", true},
+		{"中?.go", "中文.go", true},
+	}
+	for _, c := range cases {
+		match, err := MatchGlob(c.glob, c.path)
+		if err != nil || match != c.match {
+			t.Errorf("glob=%q, path=%q, got %v, err=%v, want %v", c.glob, c.path, match, err, c.match)
+		}
+	}
+
+	for _, glob := range []string{`a\`, "[abc", "[!]", "{a,b", "a{b{c}"} {
+		if _, err := GlobToRegexp(glob); err == nil {
+			t.Errorf("glob=%q is not rejected", glob)
+		}
+	}
+	if expr, err := GlobToRegexp("a/**/*.go"); err != nil || expr != `^a/(?:.*/)?[^/]*\.go$` {
+		t.Fatalf("expr=%v, err=%v", expr, err)
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    string
+	}{
+		{"abc", "abc"},
+		{"a{b,c}d", "abd acd"},
+		{"{a,{b,c}}", "a b c"},
+		{"a{b,c{d,e}}f", "abf acdf acef"},
+		{"{a,b}{1,2}", "a1 a2 b1 b2"},
+		{"{a}", "{a}"},
+		{"{a}{b,c}", "{a}b {a}c"},
+		{"{,a}b", "b ab"},
+		{`\{a,b}`, `\{a,b}`},
+		{`{a\,b,c}`, `a\,b c`},
+		{"{[,]a,b}", "[,]a b"},
+		{"[{]{a,b}", "[{]a [{]b"},
+		{"{a,b", "{a,b"},
+		{"a}", "a}"},
+	}
+	for _, c := range cases {
+		if got := strings.Join(ExpandBraces(c.pattern), " "); got != c.want {
+			t.Errorf("pattern=%q, got %q, want %q", c.pattern, got, c.want)
+		}
+	}
+	//展开后和翻译成正则表达式的结果一致
+	for _, pattern := range []string{"{a,{b,c}}.go", "x{[,]a,b}", "{*.go,doc/{a,b}.md}"} {
+		for _, expanded := range ExpandBraces(pattern) {
+			if match, err := MatchGlob(pattern, strings.NewReplacer("*", "x", "[,]", ",").Replace(expanded)); err != nil || !match {
+				t.Errorf("pattern=%q, expanded=%q, err=%v", pattern, expanded, err)
+			}
+		}
+	}
+	if got := ExpandBraces("{a,b}"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("got %v", got)
+	}
+}
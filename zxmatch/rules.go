@@ -0,0 +1,219 @@
+package zxmatch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zx9229/zxgo/zxre"
+)
+
+/*
+一条.gitignore风格的规则:
+
+	!pattern   取反(重新包含之前排除的路径)
+	pattern/   只匹配目录
+	/pattern   以及中间含有"/"的pattern, 相对于根目录; 否则匹配任意一层的名字
+*/
+type Rule struct {
+	Text     string //原始的文本
+	Negate   bool
+	DirOnly  bool
+	Anchored bool
+	pattern  *zxre.Pattern
+}
+
+func ParseRule(text string) (*Rule, error) {
+	rule := &Rule{Text: text}
+	line := text
+	if strings.HasPrefix(line, "!") {
+		rule.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if len(line) == 0 {
+		return nil, errors.New(fmt.Sprintf("empty rule, text=%v", text))
+	}
+	if strings.Contains(line, "/") {
+		rule.Anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	expr, err := translate(line)
+	if err != nil {
+		return nil, err
+	}
+	if rule.Anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	if rule.pattern, err = zxre.Compile(expr); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// 可以和zxre一起使用的正则表达式.
+func (self *Rule) Pattern() *zxre.Pattern {
+	return self.pattern
+}
+
+// path是相对于根目录的路径(用"/"分隔).
+func (self *Rule) Match(path string, isDir bool) bool {
+	if self.DirOnly && !isDir {
+		return false
+	}
+	return self.pattern.MatchString(strings.Trim(path, "/"))
+}
+
+// 有序的规则, 后面的规则优先(同.gitignore).
+type RuleSet struct {
+	Rules []*Rule
+}
+
+func NewRuleSet(texts ...string) (*RuleSet, error) {
+	ruleSet := &RuleSet{Rules: make([]*Rule, 0, len(texts))}
+	for _, text := range texts {
+		if err := ruleSet.Add(text); err != nil {
+			return nil, err
+		}
+	}
+	return ruleSet, nil
+}
+
+// 添加规则, text中的花括号在这里展开成多条规则.
+func (self *RuleSet) Add(text string) error {
+	for _, expanded := range ExpandBraces(text) {
+		rule, err := ParseRule(expanded)
+		if err != nil {
+			return err
+		}
+		self.Rules = append(self.Rules, rule)
+	}
+	return nil
+}
+
+// 读取.gitignore格式的内容: 空行和"#"开头的行被忽略, 行尾的空格被去掉(除非用"\ "转义, 见trimTrailingSpaces).
+func ParseRules(reader io.Reader) (*RuleSet, error) {
+	ruleSet := &RuleSet{Rules: make([]*Rule, 0)}
+	scanner := bufio.NewScanner(reader)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line = trimTrailingSpaces(line); len(line) == 0 {
+			continue
+		}
+		if err := ruleSet.Add(line); err != nil {
+			return nil, errors.New(fmt.Sprintf("lineNum=%v, %v", lineNum, err))
+		}
+	}
+	return ruleSet, scanner.Err()
+}
+
+// 同git: 去掉行尾的空格(不包括tab), 但是保留用"\"转义的空格. "\\ "中的"\\"是转义的"\", 后面的空格也会被去掉.
+func trimTrailingSpaces(line string) string {
+	trimmed := strings.TrimRight(line, " ")
+	if len(trimmed) == len(line) {
+		return line
+	}
+	if backslashes := len(trimmed) - len(strings.TrimRight(trimmed, `\`)); backslashes%2 == 1 {
+		return trimmed + " "
+	}
+	return trimmed
+}
+
+func LoadIgnoreFile(filename string) (*RuleSet, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	ruleSet, err := ParseRules(file)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%v: %v", filename, err))
+	}
+	return ruleSet, nil
+}
+
+// 返回最后一条匹配path的规则, matched表示这条规则不是取反的规则. 没有规则匹配时返回(false, nil).
+func (self *RuleSet) Match(path string, isDir bool) (matched bool, rule *Rule) {
+	if self == nil {
+		return
+	}
+	path = filepath.ToSlash(path)
+	for idx := len(self.Rules) - 1; 0 <= idx; idx-- {
+		if self.Rules[idx].Match(path, isDir) {
+			return !self.Rules[idx].Negate, self.Rules[idx]
+		}
+	}
+	return
+}
+
+// 同.gitignore: path或者它的某一层父目录被排除时返回true(父目录被排除时, 不能重新包含里面的文件).
+func (self *RuleSet) Ignored(path string, isDir bool) bool {
+	segments := strings.Split(strings.Trim(filepath.ToSlash(path), "/"), "/")
+	for idx := 1; idx < len(segments); idx++ {
+		if matched, _ := self.Match(strings.Join(segments[:idx], "/"), true); matched {
+			return true
+		}
+	}
+	matched, _ := self.Match(path, isDir)
+	return matched
+}
+
+// 包含和排除的规则: 文件必须被Include匹配(Include为空时全部匹配)且不被Exclude忽略. 目录只检查Exclude.
+type Matcher struct {
+	Include *RuleSet
+	Exclude *RuleSet
+}
+
+func (self *Matcher) Match(path string, isDir bool) bool {
+	if self.Exclude.Ignored(path, isDir) {
+		return false
+	}
+	if isDir || self.Include == nil || len(self.Include.Rules) == 0 {
+		return true
+	}
+	matched, _ := self.Include.Match(path, isDir)
+	return matched
+}
+
+// 遍历root, 跳过被排除的目录, 只对匹配的文件调用fn(path是相对于root的路径, 用"/"分隔).
+func (self *Matcher) Walk(root string, fn func(path string, entry fs.DirEntry) error) error {
+	return filepath.WalkDir(root, func(fullPath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(root, fullPath)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+		if !self.Match(relPath, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		return fn(relPath, entry)
+	})
+}
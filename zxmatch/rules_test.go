@@ -0,0 +1,197 @@
+package zxmatch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	cases := []struct {
+		text     string
+		negate   bool
+		dirOnly  bool
+		anchored bool
+		expr     string
+	}{
+		{"*.log", false, false, false, `^(?:.*/)?[^/]*\.log$`},
+		{"!keep.log", true, false, false, `^(?:.*/)?keep\.log$`},
+		{"build/", false, true, false, `^(?:.*/)?build$`},
+		{"/build", false, false, true, `^build$`},
+		{"doc/*.md", false, false, true, `^doc/[^/]*\.md$`},
+		{"**/b", false, false, true, `^(?:.*/)?b$`},
+		{`\!important`, false, false, false, `^(?:.*/)?!important$`},
+		{`\#hash`, false, false, false, `^(?:.*/)?#hash$`},
+	}
+	for _, c := range cases {
+		rule, err := ParseRule(c.text)
+		if err != nil {
+			t.Errorf("text=%q, err=%v", c.text, err)
+			continue
+		}
+		if rule.Negate != c.negate || rule.DirOnly != c.dirOnly || rule.Anchored != c.anchored || rule.Pattern().String() != c.expr {
+			t.Errorf("text=%q, rule=%+v, expr=%v", c.text, rule, rule.Pattern())
+		}
+	}
+	for _, text := range []string{"", "!", "/", "[a"} {
+		if _, err := ParseRule(text); err == nil {
+			t.Errorf("text=%q is not rejected", text)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	cases := []struct {
+		rules   []string
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{[]string{"*.log"}, "a.log", false, true},
+		{[]string{"*.log"}, "x/y/a.log", false, true},
+		{[]string{"*.log"}, "a.log.txt", false, false},
+		{[]string{"/a.log"}, "x/a.log", false, false},
+		{[]string{"build/"}, "build", false, false}, //只匹配目录
+		{[]string{"build/"}, "x/build", true, true},
+		{[]string{"build/"}, "x/build/y.o", false, true},
+		{[]string{"doc/*.md"}, "doc/a.md", false, true},
+		{[]string{"doc/*.md"}, "x/doc/a.md", false, false},
+		{[]string{"doc/*.md"}, "doc/x/a.md", false, false},
+		{[]string{"a/**"}, "a", true, false},
+		{[]string{"a/**"}, "a/x", false, true},
+		{[]string{"a/**"}, "a/x/y", false, true},
+		{[]string{"**/b"}, "b", false, true},
+		{[]string{"**/b"}, "x/y/b", false, true},
+		{[]string{"**/b"}, "x/b/c", false, true}, //父目录b被排除
+		{[]string{"a/**/b"}, "a/b", false, true},
+		{[]string{"a/**/b"}, "a/x/y/b", false, true},
+		{[]string{"[!a-].go"}, "b.go", false, true},
+		{[]string{"[!a-].go"}, "-.go", false, false},
+		{[]string{"{a,{b,c}}.go"}, "x/c.go", false, true},
+		{[]string{"{a,{b,c}}.go"}, "d.go", false, false},
+		{[]string{"{a,{b,c}}.go", "!b.go"}, "b.go", false, false},
+		//后面的规则优先
+		{[]string{"*.log", "!keep.log"}, "x/keep.log", false, false},
+		{[]string{"!keep.log", "*.log"}, "keep.log", false, true},
+		//父目录被排除时, 不能重新包含里面的文件
+		{[]string{"build/", "!build/keep.txt"}, "build/keep.txt", false, true},
+		{[]string{"/build", "!build/keep.txt"}, "build/keep.txt", false, true},
+		{[]string{"build/*", "!build/keep.txt"}, "build/keep.txt", false, false},
+		{[]string{"build/*", "!build/keep.txt"}, "build/other.txt", false, true},
+		{[]string{"build/*", "!build/sub/"}, "build/sub/a.txt", false, false},
+		{[]string{"build/*", "!build/sub/"}, "build/sub", false, true}, //"!build/sub/"只匹配目录
+		{[]string{"a/**", "!a/b/"}, "a/b/c", false, true},
+		{[]string{"build/", "!build/"}, "build/a.txt", false, false},
+		{[]string{"*"}, "a/b", false, true},
+		{[]string{"*", "!*/"}, "a/b", false, true},
+		{[]string{"*", "!*/", "!*.go"}, "a/b.go", false, false},
+		{nil, "a", false, false},
+	}
+	for _, c := range cases {
+		ruleSet, err := NewRuleSet(c.rules...)
+		if err != nil {
+			t.Errorf("rules=%q, err=%v", c.rules, err)
+			continue
+		}
+		if got := ruleSet.Ignored(c.path, c.isDir); got != c.ignored {
+			t.Errorf("rules=%q, path=%q, isDir=%v, got %v, want %v", c.rules, c.path, c.isDir, got, c.ignored)
+		}
+	}
+	if (*RuleSet)(nil).Ignored("a", false) {
+		t.Fatal("nil RuleSet ignores path")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	content := strings.Join([]string{
+		"# comment",
+		"",
+		"   ",
+		`\#hash`,
+		"trail  ",
+		`space\ `,
+		`spaces\ \ `,
+		`escaped\  `,
+		`backslash\\ `,
+		"tab\t",
+		"crlf\r",
+		"{a,b}.txt",
+	}, "\n")
+	ruleSet, err := ParseRules(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := make([]string, 0, len(ruleSet.Rules))
+	for _, rule := range ruleSet.Rules {
+		texts = append(texts, rule.Text)
+	}
+	want := []string{`\#hash`, "trail", `space\ `, `spaces\ \ `, `escaped\ `, `backslash\\`, "tab\t", "crlf", "a.txt", "b.txt"}
+	if !reflect.DeepEqual(texts, want) {
+		t.Fatalf("texts=%q", texts)
+	}
+
+	cases := []struct {
+		path    string
+		ignored bool
+	}{
+		{"#hash", true},
+		{"trail", true},
+		{"trail  ", false},
+		{"space ", true},
+		{"space", false},
+		{"spaces  ", true},
+		{"escaped ", true},
+		{`backslash\`, true},
+		{`backslash\ `, false},
+		{"tab\t", true},
+		{"b.txt", true},
+	}
+	for _, c := range cases {
+		if got := ruleSet.Ignored(c.path, false); got != c.ignored {
+			t.Errorf("path=%q, got %v, want %v", c.path, got, c.ignored)
+		}
+	}
+
+	if _, err = ParseRules(strings.NewReader("a\n[b\n")); err == nil || !strings.Contains(err.Error(), "lineNum=2") {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestMatcherWalk(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.go", "a_test.go", "doc/readme.md", "build/out.go", "build/keep.go", "vendor/x/y.go", "sub/b.go"} {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ignoreFile := filepath.Join(root, ".gitignore")
+	if err := os.WriteFile(ignoreFile, []byte("*_test.go\nbuild/\n!build/keep.go\n/vendor\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	exclude, err := LoadIgnoreFile(ignoreFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	include, err := NewRuleSet("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := make([]string, 0)
+	err = (&Matcher{Include: include, Exclude: exclude}).Walk(root, func(path string, entry os.DirEntry) error {
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.go", "sub/b.go"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths=%v", paths)
+	}
+}
@@ -0,0 +1,577 @@
+package zxmigrate
+
+/*
+迁移文件的命名规则(版本号必须是正整数,同一版本的up和down成对出现,down可以没有):
+	0001_create_user.up.sql
+	0001_create_user.down.sql
+	0002_add_memo.up.sql
+文件内的多条语句用";"分隔(引号,注释和postgres的$$...$$里面的";"不算).
+MySQL的字符串里"\"是转义字符('It\'s'), 请设置 Migrator.BackslashEscapes = true; sqlite和postgres没有这种转义('C:\').
+如果语句本身含有";"(比如sqlite的TRIGGER), 请设置 Migrator.NoSplit = true,此时整个文件作为一条语句执行.
+已经应用的up文件不能再修改; down文件可以之后再添加, 但是应用时已经有的down文件也不能再修改.
+Up和Down期间用 TableName+"_lock" 表里的一行记录作为锁, 防止多个进程同时迁移(见 Migrator.Unlock).
+记录迁移的语句使用 Migrator.Style 风格的占位符(默认"?", postgres请设置为zxsql.DOLLAR).
+注意: MySQL的DDL语句会隐式提交事务,所以在MySQL上"每个迁移一个事务"只对DML有保证.
+*/
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zx9229/zxgo"
+	"github.com/zx9229/zxgo/zxsql"
+)
+
+const (
+	DefaultTableName string = "zx_schema_migrations"
+	HashStyle        string = "sha256"
+)
+
+type Migration struct {
+	Version      int64
+	Name         string
+	UpSQL        string
+	DownSQL      string
+	HasDown      bool
+	Checksum     string //up文件的哈希值
+	DownChecksum string //down文件的哈希值, 没有down文件时为""
+}
+
+type MigrationStatus struct {
+	Version     int64
+	Name        string
+	Applied     bool
+	AppliedAt   string
+	Checksum    string //up文件的哈希值
+	DbSum       string //数据库里记录的up文件的哈希值
+	Drifted     bool   //已经应用的up文件被修改过了
+	DownDrifted bool   //应用时已经有的down文件被修改过了
+	Missing     bool   //数据库里有记录,但是找不到对应的文件
+}
+
+var fileNamePattern = regexp.MustCompile(`^(?P<Version>[0-9]+)_(?P<Name>.*)\.(?P<Direction>up|down)\.sql$`)
+
+// 从目录里加载迁移文件.
+func LoadDir(dir string) ([]*Migration, error) {
+	return LoadFS(os.DirFS(dir), ".")
+}
+
+// 从fs.FS(比如embed.FS)里加载迁移文件.
+func LoadFS(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	hasUp := make(map[int64]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		submatches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if submatches == nil {
+			continue
+		}
+
+		var version int64
+		if version, err = strconv.ParseInt(submatches[1], 10, 64); err != nil || version <= 0 {
+			return nil, errors.New(fmt.Sprintf("illegal version, filename=%v", entry.Name()))
+		}
+		name := submatches[2]
+		isUp := submatches[3] == "up"
+
+		var content []byte
+		if content, err = fs.ReadFile(fsys, path.Join(dir, entry.Name())); err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, errors.New(fmt.Sprintf("version conflict, version=%v, name=%v, name=%v", version, migration.Name, name))
+		}
+
+		if isUp {
+			if hasUp[version] {
+				return nil, errors.New(fmt.Sprintf("duplicate up file, version=%v", version))
+			}
+			migration.UpSQL = string(content)
+			hasUp[version] = true
+		} else {
+			if migration.HasDown {
+				return nil, errors.New(fmt.Sprintf("duplicate down file, version=%v", version))
+			}
+			migration.DownSQL = string(content)
+			migration.HasDown = true
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if !hasUp[migration.Version] {
+			return nil, errors.New(fmt.Sprintf("up file not found, version=%v", migration.Version))
+		}
+		if migration.Checksum, err = zxgo.CalcHashByReader(strings.NewReader(migration.UpSQL), HashStyle, false); err != nil {
+			return nil, err
+		}
+		if migration.HasDown {
+			if migration.DownChecksum, err = zxgo.CalcHashByReader(strings.NewReader(migration.DownSQL), HashStyle, false); err != nil {
+				return nil, err
+			}
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+type appliedRecord struct {
+	version      int64
+	name         string
+	checksum     string
+	downChecksum string
+	appliedAt    string
+}
+
+type Migrator struct {
+	db               *sql.DB
+	migrations       []*Migration
+	TableName        string
+	Style            string                                   //占位符的风格(zxsql.QUESTION/DOLLAR/NAMED),为空时使用zxsql.QUESTION.
+	DryRun           bool                                     //只打印要执行的SQL,不真正执行(要得到这些SQL,请使用PlanUp/PlanDown).
+	NoSplit          bool                                     //整个文件作为一条语句执行.
+	BackslashEscapes bool                                     //拆分语句时, 引号里面的"\"转义下一个字符(MySQL).
+	LockTimeout      time.Duration                            //其它进程正在迁移时最多等待多久, 0表示不等待, 直接返回错误.
+	Logf             func(format string, args ...interface{}) //为nil时不输出日志.
+	planned          []string                                 //PlanUp/PlanDown收集的SQL
+}
+
+func NewMigrator(db *sql.DB, migrations []*Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations, TableName: DefaultTableName}
+}
+
+func (self *Migrator) logf(format string, args ...interface{}) {
+	if self.Logf != nil {
+		self.Logf(format, args...)
+	}
+}
+
+func (self *Migrator) ensureTable() error {
+	if self.DryRun {
+		return nil
+	}
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, checksum VARCHAR(128) NOT NULL, down_checksum VARCHAR(128) NOT NULL, applied_at VARCHAR(32) NOT NULL)", self.TableName)
+	_, err := self.db.Exec(stmt)
+	return err
+}
+
+func (self *Migrator) loadApplied() (records map[int64]*appliedRecord, err error) {
+	records = make(map[int64]*appliedRecord)
+
+	if err = self.ensureTable(); err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	if rows, err = self.db.Query(fmt.Sprintf("SELECT version, name, checksum, down_checksum, applied_at FROM %v", self.TableName)); err != nil {
+		if self.DryRun { //表不存在,相当于还没有应用过任何迁移.
+			err = nil
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record := new(appliedRecord)
+		if err = rows.Scan(&record.version, &record.name, &record.checksum, &record.downChecksum, &record.appliedAt); err != nil {
+			return
+		}
+		records[record.version] = record
+	}
+	err = rows.Err()
+
+	return
+}
+
+// 查看每个迁移的状态(包括数据库里有记录但文件已经不存在的迁移).
+func (self *Migrator) Status() (results []*MigrationStatus, err error) {
+	var records map[int64]*appliedRecord
+	if records, err = self.loadApplied(); err != nil {
+		return
+	}
+
+	results = make([]*MigrationStatus, 0, len(self.migrations))
+	known := make(map[int64]bool)
+	for _, migration := range self.migrations {
+		known[migration.Version] = true
+		status := &MigrationStatus{Version: migration.Version, Name: migration.Name, Checksum: migration.Checksum}
+		if record, ok := records[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.appliedAt
+			status.DbSum = record.checksum
+			status.Drifted = record.checksum != migration.Checksum
+			status.DownDrifted = len(record.downChecksum) != 0 && record.downChecksum != migration.DownChecksum //应用之后才添加的down文件不算
+		}
+		results = append(results, status)
+	}
+	for version, record := range records {
+		if known[version] {
+			continue
+		}
+		results = append(results, &MigrationStatus{Version: version, Name: record.name, Applied: true, AppliedAt: record.appliedAt, DbSum: record.checksum, Missing: true})
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Version < results[j].Version })
+
+	return
+}
+
+// down为true时, 也检查down文件(回滚时才会执行它).
+func checkDrift(statuses []*MigrationStatus, down bool) error {
+	messages := make([]string, 0)
+	for _, status := range statuses {
+		if status.Drifted {
+			messages = append(messages, fmt.Sprintf("version=%v(checksum changed)", status.Version))
+		}
+		if down && status.DownDrifted {
+			messages = append(messages, fmt.Sprintf("version=%v(down checksum changed)", status.Version))
+		}
+	}
+	if len(messages) != 0 {
+		return errors.New("checksum drift detected: " + strings.Join(messages, ", "))
+	}
+	return nil
+}
+
+// 应用所有 version <= target 的未应用的迁移, target <= 0 表示全部.
+// 已应用的up文件被修改过(校验和不一致)时,不执行任何迁移并返回错误.
+func (self *Migrator) Up(target int64) (applied []*Migration, err error) {
+	if err = self.lock(); err != nil {
+		return
+	}
+	defer self.unlock(&err)
+
+	var statuses []*MigrationStatus
+	if statuses, err = self.Status(); err != nil {
+		return
+	}
+	if err = checkDrift(statuses, false); err != nil {
+		return
+	}
+
+	done := make(map[int64]bool)
+	for _, status := range statuses {
+		if status.Applied {
+			done[status.Version] = true
+		}
+	}
+
+	applied = make([]*Migration, 0)
+	for _, migration := range self.migrations {
+		if 0 < target && target < migration.Version {
+			break
+		}
+		if done[migration.Version] {
+			continue
+		}
+		self.logf("migrate up, version=%v, name=%v", migration.Version, migration.Name)
+		if err = self.runInTx(migration.UpSQL, func(tx *sql.Tx) error {
+			stmt, args, err := zxsql.Insert(self.TableName).Set("version", migration.Version).Set("name", migration.Name).
+				Set("checksum", migration.Checksum).Set("down_checksum", migration.DownChecksum).
+				Set("applied_at", time.Now().Format("2006-01-02 15:04:05")).Build(self.Style)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(stmt, args...)
+			return err
+		}); err != nil {
+			err = errors.New(fmt.Sprintf("migrate up fail, version=%v, err=%v", migration.Version, err))
+			return
+		}
+		applied = append(applied, migration)
+	}
+
+	return
+}
+
+// 按版本号从大到小回滚 steps 个已应用的迁移, steps <= 0 表示全部.
+// 已应用的up文件或者down文件被修改过时,不执行任何回滚并返回错误.
+func (self *Migrator) Down(steps int) (reverted []*Migration, err error) {
+	if err = self.lock(); err != nil {
+		return
+	}
+	defer self.unlock(&err)
+
+	var statuses []*MigrationStatus
+	if statuses, err = self.Status(); err != nil {
+		return
+	}
+	if err = checkDrift(statuses, true); err != nil {
+		return
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, migration := range self.migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	reverted = make([]*Migration, 0)
+	for i := len(statuses) - 1; 0 <= i; i-- {
+		if 0 < steps && steps <= len(reverted) {
+			break
+		}
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		migration, ok := byVersion[status.Version]
+		if !ok {
+			err = errors.New(fmt.Sprintf("migration file not found, version=%v", status.Version))
+			return
+		}
+		if !migration.HasDown {
+			err = errors.New(fmt.Sprintf("down file not found, version=%v", status.Version))
+			return
+		}
+		self.logf("migrate down, version=%v, name=%v", migration.Version, migration.Name)
+		if err = self.runInTx(migration.DownSQL, func(tx *sql.Tx) error {
+			stmt, args, err := zxsql.Delete(self.TableName).Where(zxsql.Eq("version", migration.Version)).Build(self.Style)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(stmt, args...)
+			return err
+		}); err != nil {
+			err = errors.New(fmt.Sprintf("migrate down fail, version=%v, err=%v", migration.Version, err))
+			return
+		}
+		reverted = append(reverted, migration)
+	}
+
+	return
+}
+
+func (self *Migrator) runInTx(content string, record func(tx *sql.Tx) error) (err error) {
+	var stmts []string
+	if self.NoSplit {
+		stmts = []string{content}
+	} else {
+		stmts = SplitStatements(content, self.BackslashEscapes)
+	}
+
+	if self.DryRun {
+		for _, stmt := range stmts {
+			self.logf("[DRY-RUN] %v", stmt)
+		}
+		self.planned = append(self.planned, stmts...)
+		return
+	}
+
+	var tx *sql.Tx
+	if tx, err = self.db.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, stmt := range stmts {
+		if _, err = tx.Exec(stmt); err != nil {
+			return
+		}
+	}
+	if err = record(tx); err != nil {
+		return
+	}
+	err = tx.Commit()
+
+	return
+}
+
+// 返回Up(target)要执行的SQL(不包括记录迁移的语句), 不修改数据库.
+func (self *Migrator) PlanUp(target int64) ([]string, error) {
+	planner := *self
+	planner.DryRun = true
+	planner.planned = make([]string, 0)
+	_, err := planner.Up(target)
+	return planner.planned, err
+}
+
+// 返回Down(steps)要执行的SQL(不包括记录迁移的语句), 不修改数据库.
+func (self *Migrator) PlanDown(steps int) ([]string, error) {
+	planner := *self
+	planner.DryRun = true
+	planner.planned = make([]string, 0)
+	_, err := planner.Down(steps)
+	return planner.planned, err
+}
+
+func (self *Migrator) lockTableName() string {
+	return self.TableName + "_lock"
+}
+
+// 插入id=1的记录作为锁(主键冲突表示其它进程持有锁), 在LockTimeout内重试.
+func (self *Migrator) lock() error {
+	if self.DryRun {
+		return nil
+	}
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (id INT NOT NULL PRIMARY KEY, owner VARCHAR(255) NOT NULL, locked_at VARCHAR(32) NOT NULL)", self.lockTableName())
+	if _, err := self.db.Exec(stmt); err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%v:%v", hostname, os.Getpid())
+	deadline := time.Now().Add(self.LockTimeout)
+	for {
+		stmt, args, err := zxsql.Insert(self.lockTableName()).Set("id", 1).Set("owner", owner).
+			Set("locked_at", time.Now().Format("2006-01-02 15:04:05")).Build(self.Style)
+		if err != nil {
+			return err
+		}
+		if _, err = self.db.Exec(stmt, args...); err == nil {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			var holder, lockedAt string
+			self.db.QueryRow(fmt.Sprintf("SELECT owner, locked_at FROM %v", self.lockTableName())).Scan(&holder, &lockedAt)
+			return errors.New(fmt.Sprintf("migration is locked, owner=%v, locked_at=%v, err=%v (call Unlock if that process is gone)", holder, lockedAt, err))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// 释放锁, 并把释放失败的错误放到*err里(如果*err是nil).
+func (self *Migrator) unlock(err *error) {
+	if self.DryRun {
+		return
+	}
+	if err2 := self.Unlock(); err2 != nil && *err == nil {
+		*err = err2
+	}
+}
+
+// 强制释放迁移锁. 只有在持有锁的进程异常退出(锁没有被释放)时才需要调用.
+func (self *Migrator) Unlock() error {
+	stmt, args, err := zxsql.Delete(self.lockTableName()).Where(zxsql.Eq("id", 1)).Build(self.Style)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(stmt, args...)
+	return err
+}
+
+// 把SQL文本按";"拆分成多条语句,忽略引号,注释和$tag$...$tag$里面的";",丢弃空语句.
+// backslashEscapes为true时, 引号里面的"\"转义下一个字符(MySQL的规则), 所以'It\'s;'是一个字符串.
+func SplitStatements(content string, backslashEscapes bool) []string {
+	stmts := make([]string, 0)
+	var current strings.Builder
+
+	flush := func() {
+		stmt := strings.TrimSpace(current.String())
+		if len(stmt) != 0 && !isOnlyComment(stmt) {
+			stmts = append(stmts, stmt)
+		}
+		current.Reset()
+	}
+
+	runes := []rune(content)
+	var quote rune = 0
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			current.WriteRune(c)
+			if backslashEscapes && c == '\\' && quote != '`' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			current.WriteRune(c)
+		case c == '$' && len(dollarTag(runes, i)) != 0:
+			tag := dollarTag(runes, i)
+			rest := string(runes[i:])
+			end := strings.Index(rest[len(tag):], tag)
+			if end < 0 { //找不到结尾时, 剩下的都是字符串
+				end = len(rest)
+			} else {
+				end += 2 * len(tag)
+			}
+			current.WriteString(rest[:end])
+			i += utf8.RuneCountInString(rest[:end]) - 1
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for ; i < len(runes) && runes[i] != '\n'; i++ {
+				current.WriteRune(runes[i])
+			}
+			if i < len(runes) {
+				current.WriteRune('\n')
+			}
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			current.WriteString("/*")
+			for i += 2; i < len(runes); i++ {
+				current.WriteRune(runes[i])
+				if runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/' {
+					current.WriteRune('/')
+					i++
+					break
+				}
+			}
+		case c == ';':
+			flush()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	flush()
+
+	return stmts
+}
+
+// runes[i]开始的postgres的dollar quote的标签($$或$name$), 不是标签时返回"".
+// 标签不能以数字开头($1是参数), $前面也不能是标识符(标识符里可以有$).
+func dollarTag(runes []rune, i int) string {
+	if 0 < i && isIdentRune(runes[i-1]) {
+		return ""
+	}
+	for j := i + 1; j < len(runes); j++ {
+		switch {
+		case runes[j] == '$':
+			return string(runes[i : j+1])
+		case !isIdentRune(runes[j]) || (j == i+1 && unicode.IsDigit(runes[j])):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentRune(c rune) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func isOnlyComment(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) != 0 && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
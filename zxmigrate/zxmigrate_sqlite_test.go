@@ -0,0 +1,170 @@
+//go:build cgo
+
+package zxmigrate
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openSqlite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func loadMigrations(t *testing.T, fsys fstest.MapFS) []*Migration {
+	migrations, err := LoadFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrations
+}
+
+func versions(migrations []*Migration) []int64 {
+	result := make([]int64, 0, len(migrations))
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
+
+func TestMigrator(t *testing.T) {
+	db := openSqlite(t)
+	fsys := fstest.MapFS{
+		"0001_create_user.up.sql":   {Data: []byte("CREATE TABLE user (id INT, name TEXT);\nINSERT INTO user VALUES (1, 'a;b');")},
+		"0001_create_user.down.sql": {Data: []byte("DROP TABLE user;")},
+		"0002_create_log.up.sql":    {Data: []byte("CREATE TABLE log (id INT);")},
+	}
+
+	applied, err := NewMigrator(db, loadMigrations(t, fsys)).Up(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(applied); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Fatalf("applied=%v", got)
+	}
+	var name string
+	if err = db.QueryRow("SELECT name FROM user WHERE id = 1").Scan(&name); err != nil || name != "a;b" {
+		t.Fatalf("name=%v, err=%v", name, err)
+	}
+
+	//应用之后再添加down文件, 不算修改
+	fsys["0002_create_log.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE log;")}
+	migrator := NewMigrator(db, loadMigrations(t, fsys))
+	if applied, err = migrator.Up(0); err != nil || len(applied) != 0 {
+		t.Fatalf("applied=%v, err=%v", versions(applied), err)
+	}
+
+	//应用时已经有的down文件被修改了, 不能回滚
+	fsys["0001_create_user.down.sql"] = &fstest.MapFile{Data: []byte("DELETE FROM user; DROP TABLE user;")}
+	migrator = NewMigrator(db, loadMigrations(t, fsys))
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].Drifted || !statuses[0].DownDrifted || statuses[1].DownDrifted {
+		t.Fatalf("statuses=%+v, %+v", statuses[0], statuses[1])
+	}
+	if _, err = migrator.Up(0); err != nil {
+		t.Fatalf("Up should ignore down files, err=%v", err)
+	}
+	if _, err = migrator.Down(0); err == nil || !strings.Contains(err.Error(), "down checksum changed") {
+		t.Fatalf("err=%v", err)
+	}
+
+	fsys["0001_create_user.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE user;")}
+	migrator = NewMigrator(db, loadMigrations(t, fsys))
+	reverted, err := migrator.Down(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versions(reverted); !reflect.DeepEqual(got, []int64{2, 1}) {
+		t.Fatalf("reverted=%v", got)
+	}
+
+	//修改已经应用的up文件
+	if _, err = migrator.Up(1); err != nil {
+		t.Fatal(err)
+	}
+	fsys["0001_create_user.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE user (id BIGINT);")}
+	if _, err = NewMigrator(db, loadMigrations(t, fsys)).Up(0); err == nil || !strings.Contains(err.Error(), "checksum changed") {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestMigratorPlan(t *testing.T) {
+	db := openSqlite(t)
+	fsys := fstest.MapFS{
+		"0001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);")},
+		"0001_a.down.sql": {Data: []byte("DROP TABLE b; DROP TABLE a;")},
+	}
+	migrator := NewMigrator(db, loadMigrations(t, fsys))
+
+	planned, err := migrator.PlanUp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}; !reflect.DeepEqual(planned, want) {
+		t.Fatalf("planned=%q", planned)
+	}
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&count); err != nil || count != 0 {
+		t.Fatalf("PlanUp modified the database, count=%v, err=%v", count, err)
+	}
+
+	migrator.NoSplit = true
+	if planned, err = migrator.PlanUp(0); err != nil || len(planned) != 1 || planned[0] != string(fsys["0001_a.up.sql"].Data) {
+		t.Fatalf("planned=%q, err=%v", planned, err)
+	}
+
+	if _, err = migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	migrator.NoSplit = false
+	if planned, err = migrator.PlanDown(0); err != nil || !reflect.DeepEqual(planned, []string{"DROP TABLE b", "DROP TABLE a"}) {
+		t.Fatalf("planned=%q, err=%v", planned, err)
+	}
+	if planned, err = migrator.PlanUp(0); err != nil || len(planned) != 0 {
+		t.Fatalf("planned=%q, err=%v", planned, err)
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	db := openSqlite(t)
+	migrations := loadMigrations(t, fstest.MapFS{"0001_a.up.sql": {Data: []byte("CREATE TABLE a (id INT);")}})
+
+	holder := NewMigrator(db, migrations)
+	if err := holder.lock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewMigrator(db, migrations).Up(0); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("err=%v", err)
+	}
+
+	migrator := NewMigrator(db, migrations)
+	migrator.LockTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		holder.Unlock()
+	}()
+	applied, err := migrator.Up(0)
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("applied=%v, err=%v", versions(applied), err)
+	}
+
+	//Up结束时释放了锁
+	if err = holder.lock(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,85 @@
+package zxmigrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name             string
+		content          string
+		backslashEscapes bool
+		want             []string
+	}{
+		{"simple", "CREATE TABLE a (id INT);\n\nINSERT INTO a VALUES (1);  ", false, []string{"CREATE TABLE a (id INT)", "INSERT INTO a VALUES (1)"}},
+		{"empty", " ;\n; -- only comment\n", false, []string{}},
+		{"single quote", "INSERT INTO a VALUES ('x;y');SELECT 1", false, []string{"INSERT INTO a VALUES ('x;y')", "SELECT 1"}},
+		{"doubled quote", "SELECT 'it''s;';SELECT 2", false, []string{"SELECT 'it''s;'", "SELECT 2"}},
+		{"double quote and backtick", "SELECT \"a;b\", `c;d` FROM t;SELECT 3", false, []string{"SELECT \"a;b\", `c;d` FROM t", "SELECT 3"}},
+		{"backslash is not escape", `INSERT INTO a VALUES ('C:\');SELECT 4`, false, []string{`INSERT INTO a VALUES ('C:\')`, "SELECT 4"}},
+		{"backslash escape", `INSERT INTO a VALUES ('It\'s;');SELECT 5`, true, []string{`INSERT INTO a VALUES ('It\'s;')`, "SELECT 5"}},
+		{"backslash in backtick", "SELECT `a\\`;SELECT 6", true, []string{"SELECT `a\\`", "SELECT 6"}},
+		{"line comment", "SELECT 1; -- a;b\nSELECT 2", false, []string{"SELECT 1", "-- a;b\nSELECT 2"}},
+		{"block comment", "SELECT /* a;b */ 1;SELECT 2", false, []string{"SELECT /* a;b */ 1", "SELECT 2"}},
+		{"dollar quote", "CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END $$ LANGUAGE plpgsql;SELECT f()", false,
+			[]string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END $$ LANGUAGE plpgsql", "SELECT f()"}},
+		{"tagged dollar quote", "SELECT $fn$ a;$$;b $fn$;SELECT 2", false, []string{"SELECT $fn$ a;$$;b $fn$", "SELECT 2"}},
+		{"unterminated dollar quote", "SELECT $$ a;b", false, []string{"SELECT $$ a;b"}},
+		{"placeholder is not dollar quote", "UPDATE a SET x = $1 WHERE y = $2;SELECT 3", false, []string{"UPDATE a SET x = $1 WHERE y = $2", "SELECT 3"}},
+		{"dollar in identifier", "SELECT a$b$c FROM t;SELECT 4", false, []string{"SELECT a$b$c FROM t", "SELECT 4"}},
+		{"multibyte", "SELECT '中;文';SELECT $标签$ ;中 $标签$", false, []string{"SELECT '中;文'", "SELECT $标签$ ;中 $标签$"}},
+	}
+	for _, c := range cases {
+		if got := SplitStatements(c.content, c.backslashEscapes); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%v: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_add_memo.up.sql":       {Data: []byte("ALTER TABLE user ADD memo TEXT")},
+		"sql/0001_create_user.up.sql":    {Data: []byte("CREATE TABLE user (id INT)")},
+		"sql/0001_create_user.down.sql":  {Data: []byte("DROP TABLE user")},
+		"sql/README.md":                  {Data: []byte("ignored")},
+		"sql/0003_sub.up.sql/nested.sql": {Data: []byte("ignored")},
+	}
+	migrations, err := LoadFS(fsys, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("migrations=%+v", migrations)
+	}
+	first, second := migrations[0], migrations[1]
+	if first.Name != "create_user" || !first.HasDown || first.DownSQL != "DROP TABLE user" || len(first.DownChecksum) == 0 {
+		t.Fatalf("first=%+v", first)
+	}
+	if second.HasDown || len(second.DownChecksum) != 0 {
+		t.Fatalf("second=%+v", second)
+	}
+
+	//之后添加或修改down文件, 不影响up文件的校验和
+	checksum := second.Checksum
+	fsys["sql/0002_add_memo.down.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE user DROP memo")}
+	if migrations, err = LoadFS(fsys, "sql"); err != nil {
+		t.Fatal(err)
+	}
+	if migrations[1].Checksum != checksum || !migrations[1].HasDown {
+		t.Fatalf("second=%+v", migrations[1])
+	}
+
+	for name, files := range map[string]fstest.MapFS{
+		"up file not found": {"0001_a.down.sql": {}},
+		"version conflict":  {"0001_a.up.sql": {}, "01_b.up.sql": {}},
+		"illegal version":   {"0000_a.up.sql": {}},
+		"duplicate up file": {"0001_a.up.sql": {}, "01_a.up.sql": {}},
+	} {
+		if _, err = LoadFS(files, "."); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%v: err=%v", name, err)
+		}
+	}
+}
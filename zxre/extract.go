@@ -0,0 +1,172 @@
+package zxre
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/zx9229/zxgo"
+)
+
+// 一条命名的规则, Pattern中的命名分组会成为Record.Groups.
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	pattern *Pattern
+}
+
+// 提取出来的一条记录.
+type Record struct {
+	Rule   string            `json:"rule"`           //匹配的规则名, 没有匹配任何规则时为空
+	Line   int               `json:"line"`           //记录的第一行的行号, 从1开始
+	Lines  int               `json:"lines"`          //记录有几行
+	Text   string            `json:"text,omitempty"` //记录的原文(多行时用"\n"连接), KeepText为true时有效
+	Groups map[string]string `json:"groups,omitempty"`
+}
+
+// 可以从JSON文件加载的配置, 例如:
+//
+//	{
+//	  "record_start": "^\\d{4}-\\d{2}-\\d{2} ",
+//	  "rules": [
+//	    {"name": "error", "pattern": "(?s)^(?P<time>\\S+ \\S+) ERROR (?P<message>.*)"},
+//	    {"name": "cost",  "pattern": "^(?P<time>\\S+ \\S+) .*cost=(?P<cost>\\d+)ms"}
+//	  ]
+//	}
+type ExtractorConfig struct {
+	Rules         []*Rule `json:"rules"`
+	RecordStart   string  `json:"record_start"`   //为空时每一行是一条记录; 否则匹配它的行是一条记录的开始, 后面不匹配的行属于这条记录
+	FirstMatch    bool    `json:"first_match"`    //为true时一条记录只输出第一个匹配的规则, 否则每个匹配的规则输出一次
+	KeepText      bool    `json:"keep_text"`      //输出记录的原文
+	EmitUnmatched bool    `json:"emit_unmatched"` //没有匹配任何规则的记录也输出(Rule为空)
+	MaxLines      int     `json:"max_lines"`      //一条记录最多几行, 超过时截断(多出的行被丢弃), 0表示不限制
+}
+
+type Extractor struct {
+	ExtractorConfig
+	recordStart *Pattern
+}
+
+// 编译所有的规则, 规则名不能重复.
+func NewExtractor(config *ExtractorConfig) (*Extractor, error) {
+	extractor := &Extractor{ExtractorConfig: *config}
+	var err error
+	if len(config.RecordStart) != 0 {
+		if extractor.recordStart, err = Compile(config.RecordStart); err != nil {
+			return nil, errors.New(fmt.Sprintf("record_start: %v", err))
+		}
+	}
+	names := make(map[string]bool)
+	extractor.Rules = make([]*Rule, 0, len(config.Rules))
+	for _, rule := range config.Rules {
+		if len(rule.Name) == 0 || names[rule.Name] {
+			return nil, errors.New(fmt.Sprintf("rule name is empty or duplicated, name=%v", rule.Name))
+		}
+		names[rule.Name] = true
+		compiled := &Rule{Name: rule.Name, Pattern: rule.Pattern}
+		if compiled.pattern, err = Compile(rule.Pattern); err != nil {
+			return nil, errors.New(fmt.Sprintf("rule %v: %v", rule.Name, err))
+		}
+		extractor.Rules = append(extractor.Rules, compiled)
+	}
+	return extractor, nil
+}
+
+// 从JSON文件加载配置(格式见ExtractorConfig).
+func LoadExtractor(filename string) (*Extractor, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := new(ExtractorConfig)
+	if err = json.Unmarshal(content, config); err != nil {
+		return nil, errors.New(fmt.Sprintf("%v: %v", filename, err))
+	}
+	return NewExtractor(config)
+}
+
+// 逐行读取reader, 每得到一条记录就调用emit, emit返回error时停止并返回这个error.
+func (self *Extractor) Run(reader io.Reader, emit func(record *Record) error) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	lines := make([]string, 0)
+	firstLine := 0
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		text := strings.Join(lines, "\n")
+		count := len(lines)
+		lines = lines[:0]
+		return self.extract(text, firstLine, count, emit)
+	}
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if self.recordStart == nil || self.recordStart.MatchString(line) || len(lines) == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			firstLine = lineNum
+		} else if 0 < self.MaxLines && self.MaxLines <= len(lines) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (self *Extractor) extract(text string, firstLine, count int, emit func(record *Record) error) error {
+	newRecord := func(ruleName string) *Record {
+		record := &Record{Rule: ruleName, Line: firstLine, Lines: count}
+		if self.KeepText {
+			record.Text = text
+		}
+		return record
+	}
+
+	matched := false
+	for _, rule := range self.Rules {
+		match := rule.pattern.FindMatch(text)
+		if match == nil {
+			continue
+		}
+		matched = true
+		record := newRecord(rule.Name)
+		record.Groups = match.GroupDict()
+		if err := emit(record); err != nil {
+			return err
+		}
+		if self.FirstMatch {
+			break
+		}
+	}
+	if !matched && self.EmitUnmatched {
+		return emit(newRecord(""))
+	}
+	return nil
+}
+
+// 把记录以JSON Lines的格式(每行一个JSON对象)写入writer.
+func (self *Extractor) WriteJSONLines(reader io.Reader, writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	return self.Run(reader, func(record *Record) error {
+		return encoder.Encode(record)
+	})
+}
+
+// 把记录(*Record)放入队列, 由队列的回调函数(或者Pop)处理.
+func (self *Extractor) PushToQueue(reader io.Reader, queue *zxgo.Queue) error {
+	return self.Run(reader, func(record *Record) error {
+		queue.Push(record)
+		return nil
+	})
+}
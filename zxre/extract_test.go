@@ -0,0 +1,175 @@
+package zxre
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/zx9229/zxgo"
+)
+
+const extractLog = "2024-01-02 10:00:00 INFO start cost=5ms\r\n" +
+	"2024-01-02 10:00:01 ERROR boom\r\n" +
+	"\tat a.go:1\r\n" +
+	"\tat b.go:2\r\n" +
+	"\tat c.go:3\r\n" +
+	"2024-01-02 10:00:02 DEBUG idle\r\n"
+
+func extractorRules() []*Rule {
+	return []*Rule{
+		{Name: "error", Pattern: `(?s)^(?P<time>\S+ \S+) ERROR (?P<message>.*)`},
+		{Name: "cost", Pattern: `^(?P<time>\S+ \S+) .*cost=(?P<cost>\d+)ms`},
+		{Name: "line", Pattern: `^\S+ \S+ (?P<level>INFO|ERROR) `},
+	}
+}
+
+func recordString(record *Record) string {
+	keys := make([]string, 0, len(record.Groups))
+	for key := range record.Groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]string, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, fmt.Sprintf("%v=%q", key, record.Groups[key]))
+	}
+	return fmt.Sprintf("%v@%v+%v%q{%v}", record.Rule, record.Line, record.Lines, record.Text, strings.Join(items, " "))
+}
+
+func runExtractor(t *testing.T, config *ExtractorConfig, content string) []string {
+	extractor, err := NewExtractor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make([]string, 0)
+	err = extractor.Run(strings.NewReader(content), func(record *Record) error {
+		records = append(records, recordString(record))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestExtractor(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  *ExtractorConfig
+		content string
+		want    []string
+	}{
+		{"per line", &ExtractorConfig{Rules: extractorRules(), FirstMatch: true}, extractLog, []string{
+			`cost@1+1""{cost="5" time="2024-01-02 10:00:00"}`,
+			`error@2+1""{message="boom" time="2024-01-02 10:00:01"}`,
+		}},
+		{"record start", &ExtractorConfig{Rules: extractorRules(), RecordStart: `^\d{4}-`}, extractLog, []string{
+			`cost@1+1""{cost="5" time="2024-01-02 10:00:00"}`,
+			`line@1+1""{level="INFO"}`,
+			`error@2+4""{message="boom\n\tat a.go:1\n\tat b.go:2\n\tat c.go:3" time="2024-01-02 10:00:01"}`,
+			`line@2+4""{level="ERROR"}`,
+		}},
+		{"max lines", &ExtractorConfig{Rules: extractorRules()[:1], RecordStart: `^\d{4}-`, MaxLines: 2, KeepText: true, EmitUnmatched: true}, extractLog, []string{
+			`@1+1"2024-01-02 10:00:00 INFO start cost=5ms"{}`,
+			`error@2+2"2024-01-02 10:00:01 ERROR boom\n\tat a.go:1"{message="boom\n\tat a.go:1" time="2024-01-02 10:00:01"}`,
+			`@6+1"2024-01-02 10:00:02 DEBUG idle"{}`,
+		}},
+		{"no rules", &ExtractorConfig{RecordStart: `^\d{4}-`, EmitUnmatched: true}, extractLog, []string{`@1+1""{}`, `@2+4""{}`, `@6+1""{}`}},
+		//第一行不匹配RecordStart时, 它自己是一条记录的开始
+		{"leading lines", &ExtractorConfig{RecordStart: `^\d{4}-`, EmitUnmatched: true}, "head1\nhead2\n" + extractLog, []string{`@1+2""{}`, `@3+1""{}`, `@4+4""{}`, `@8+1""{}`}},
+		{"empty", &ExtractorConfig{EmitUnmatched: true}, "", []string{}},
+	}
+	for _, c := range cases {
+		if got := runExtractor(t, c.config, c.content); strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Errorf("%v:\ngot  %v\nwant %v", c.name, strings.Join(got, "\n     "), strings.Join(c.want, "\n     "))
+		}
+	}
+}
+
+func TestNewExtractor(t *testing.T) {
+	cases := []struct {
+		config *ExtractorConfig
+		err    string
+	}{
+		{&ExtractorConfig{Rules: []*Rule{{Name: "a", Pattern: "a"}, {Name: "a", Pattern: "b"}}}, "duplicated, name=a"},
+		{&ExtractorConfig{Rules: []*Rule{{Name: "", Pattern: "a"}}}, "empty or duplicated"},
+		{&ExtractorConfig{Rules: []*Rule{{Name: "a", Pattern: "("}}}, "rule a: "},
+		{&ExtractorConfig{RecordStart: "("}, "record_start: "},
+	}
+	for _, c := range cases {
+		if _, err := NewExtractor(c.config); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("err=%v, want %v", err, c.err)
+		}
+	}
+
+	//emit返回的错误使Run停止
+	extractor, err := NewExtractor(&ExtractorConfig{EmitUnmatched: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("stop")
+	count := 0
+	err = extractor.Run(strings.NewReader("a\nb\nc\n"), func(record *Record) error {
+		count++
+		return failure
+	})
+	if err != failure || count != 1 {
+		t.Fatalf("count=%v, err=%v", count, err)
+	}
+}
+
+func TestLoadExtractor(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "extractor.json")
+	config := `{
+  "record_start": "^\\d{4}-",
+  "first_match": true,
+  "rules": [
+    {"name": "error", "pattern": "(?s)^(?P<time>\\S+ \\S+) ERROR (?P<message>.*)"},
+    {"name": "cost",  "pattern": "^(?P<time>\\S+ \\S+) .*cost=(?P<cost>\\d+)ms"}
+  ]
+}`
+	if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	extractor, err := LoadExtractor(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extractor.FirstMatch || len(extractor.Rules) != 2 {
+		t.Fatalf("extractor=%+v", extractor)
+	}
+
+	buffer := new(bytes.Buffer)
+	if err = extractor.WriteJSONLines(strings.NewReader(extractLog), buffer); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"rule":"cost","line":1,"lines":1,"groups":{"cost":"5","time":"2024-01-02 10:00:00"}}
+{"rule":"error","line":2,"lines":4,"groups":{"message":"boom\n\tat a.go:1\n\tat b.go:2\n\tat c.go:3","time":"2024-01-02 10:00:01"}}
+`
+	if buffer.String() != want {
+		t.Fatalf("got  %v\nwant %v", buffer.String(), want)
+	}
+
+	queue := zxgo.NewQueue(nil)
+	if err = extractor.PushToQueue(strings.NewReader(extractLog), queue); err != nil {
+		t.Fatal(err)
+	}
+	if data, ok := queue.Pop(); !ok || data.(*Record).Rule != "cost" || queue.Size() != 1 {
+		t.Fatalf("data=%+v, size=%v", data, queue.Size())
+	}
+
+	if err = os.WriteFile(filename, []byte(`{"rules": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadExtractor(filename); err == nil || !strings.Contains(err.Error(), filename) {
+		t.Fatalf("err=%v", err)
+	}
+	if _, err = LoadExtractor(filename + ".none"); err == nil {
+		t.Fatal("missing file is not rejected")
+	}
+}
@@ -0,0 +1,201 @@
+package zxre
+
+import (
+	"container/list"
+	"regexp"
+	"sync"
+
+	"github.com/zx9229/zxgo"
+)
+
+// 编译好的正则表达式, 通过Compile得到的Pattern会被缓存(LRU), 可以在多个goroutine中使用.
+type Pattern struct {
+	*regexp.Regexp
+	names []string
+}
+
+// 一个分组的匹配结果, Start/End是在content中的偏移量. 分组没有参与匹配时Matched为false, Start/End为-1.
+type Group struct {
+	Name    string
+	Value   string
+	Start   int
+	End     int
+	Matched bool
+}
+
+// 一次匹配的结果, Groups按分组在pattern中的顺序排列(只包含命名分组).
+type Match struct {
+	Value  string
+	Start  int
+	End    int
+	Groups []*Group
+}
+
+// 返回分组名对应的分组, 没有这个分组时返回nil.
+// 有多个同名的分组时(比如"(?P<x>a)|(?P<x>b)"), 返回第一个参与了匹配的分组, 都没有参与匹配时返回第一个.
+func (self *Match) Group(name string) *Group {
+	var first *Group
+	for _, group := range self.Groups {
+		if group.Name != name {
+			continue
+		}
+		if group.Matched {
+			return group
+		}
+		if first == nil {
+			first = group
+		}
+	}
+	return first
+}
+
+// 返回所有命名分组的值(没有参与匹配的分组是空字符串), 同CalcAllGroupDict.
+// 同名的分组取第一个参与了匹配的分组的值(同regexp.Expand).
+func (self *Match) GroupDict() map[string]string {
+	dict := make(map[string]string, len(self.Groups))
+	for _, group := range self.Groups {
+		if _, ok := dict[group.Name]; !ok {
+			dict[group.Name] = self.Group(group.Name).Value
+		}
+	}
+	return dict
+}
+
+const DefaultCacheSize int = 256
+
+type patternCache struct {
+	mutex    sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List //Front是最近使用的
+}
+
+type cacheEntry struct {
+	expr    string
+	pattern *Pattern
+}
+
+var cache = &patternCache{capacity: DefaultCacheSize, items: make(map[string]*list.Element), order: list.New()}
+
+func (self *patternCache) get(expr string) *Pattern {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if element, ok := self.items[expr]; ok {
+		self.order.MoveToFront(element)
+		return element.Value.(*cacheEntry).pattern
+	}
+	return nil
+}
+
+func (self *patternCache) put(expr string, pattern *Pattern) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if _, ok := self.items[expr]; ok || self.capacity <= 0 {
+		return
+	}
+	self.items[expr] = self.order.PushFront(&cacheEntry{expr, pattern})
+	self.shrink()
+}
+
+func (self *patternCache) shrink() {
+	for self.capacity < self.order.Len() {
+		element := self.order.Back()
+		self.order.Remove(element)
+		delete(self.items, element.Value.(*cacheEntry).expr)
+	}
+}
+
+// 设置缓存的大小, size<=0时不缓存.
+func SetCacheSize(size int) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.capacity = size
+	if size < 0 {
+		cache.capacity = 0
+	}
+	cache.shrink()
+}
+
+// 编译(或者从缓存中取得)正则表达式, 语法错误时返回error.
+func Compile(expr string) (*Pattern, error) {
+	if pattern := cache.get(expr); pattern != nil {
+		return pattern, nil
+	}
+	regexpObj, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	pattern := &Pattern{Regexp: regexpObj, names: regexpObj.SubexpNames()}
+	cache.put(expr, pattern)
+	return pattern, nil
+}
+
+// 同Compile, 出错时panic. 只适合用于常量的pattern.
+func MustCompile(expr string) *Pattern {
+	pattern, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return pattern
+}
+
+func (self *Pattern) newMatch(content string, submatches []int) *Match {
+	match := &Match{Value: content[submatches[0]:submatches[1]], Start: submatches[0], End: submatches[1], Groups: make([]*Group, 0)}
+	for i, name := range self.names {
+		if len(name) == 0 {
+			continue
+		}
+		group := &Group{Name: name, Start: submatches[2*i], End: submatches[2*i+1]}
+		if 0 <= group.Start {
+			group.Value = content[group.Start:group.End]
+			group.Matched = true
+		}
+		match.Groups = append(match.Groups, group)
+	}
+	return match
+}
+
+// 第一个匹配(带位置), 没有匹配时返回nil.
+func (self *Pattern) FindMatch(content string) *Match {
+	submatches := self.FindStringSubmatchIndex(content)
+	if submatches == nil {
+		return nil
+	}
+	return self.newMatch(content, submatches)
+}
+
+// 最多n个匹配(带位置), n<0时返回所有的匹配, 没有匹配时返回nil.
+func (self *Pattern) FindAllMatches(content string, n int) []*Match {
+	var matches []*Match
+	for _, submatches := range self.FindAllStringSubmatchIndex(content, n) {
+		matches = append(matches, self.newMatch(content, submatches))
+	}
+	return matches
+}
+
+// 第一个匹配的分组字典, 没有匹配时返回nil.
+func (self *Pattern) FindGroupDict(content string) map[string]string {
+	if match := self.FindMatch(content); match != nil {
+		return match.GroupDict()
+	}
+	return nil
+}
+
+// 最多n个匹配的分组字典, n<0时返回所有的匹配, 没有匹配时返回nil.
+func (self *Pattern) FindAllGroupDict(content string, n int) []map[string]string {
+	var dicts []map[string]string
+	for _, match := range self.FindAllMatches(content, n) {
+		dicts = append(dicts, match.GroupDict())
+	}
+	return dicts
+}
+
+// 同UnmarshalAll, 最多处理n个匹配, n<0时处理所有的匹配.
+func (self *Pattern) UnmarshalAll(content string, n int, slicePtr interface{}, opts *zxgo.BindOptions) error {
+	return unmarshalGroups(self.Regexp, content, n, slicePtr, opts)
+}
+
+// 同Unmarshal.
+func (self *Pattern) Unmarshal(content string, data interface{}, opts *zxgo.BindOptions) (matched bool, err error) {
+	return unmarshalOne(self.Regexp, content, data, opts)
+}
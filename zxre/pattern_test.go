@@ -0,0 +1,132 @@
+package zxre
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 缓存中的表达式, 从最近使用的开始.
+func cachedExprs() []string {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	exprs := make([]string, 0, cache.order.Len())
+	for element := cache.order.Front(); element != nil; element = element.Next() {
+		exprs = append(exprs, element.Value.(*cacheEntry).expr)
+	}
+	return exprs
+}
+
+func TestPatternCache(t *testing.T) {
+	defer SetCacheSize(DefaultCacheSize)
+	SetCacheSize(0)
+	SetCacheSize(2)
+
+	a, err := Compile("a+")
+	if err != nil {
+		t.Fatal(err)
+	}
+	MustCompile("b+")
+	if again := MustCompile("a+"); again != a { //命中缓存, 并移到最前面
+		t.Fatal("pattern is not cached")
+	}
+	MustCompile("c+") //淘汰最久没有使用的b+
+	if got := cachedExprs(); !reflect.DeepEqual(got, []string{"c+", "a+"}) {
+		t.Fatalf("cache=%v", got)
+	}
+
+	if _, err = Compile("(a"); err == nil {
+		t.Fatal("illegal pattern is not rejected")
+	}
+	if got := cachedExprs(); len(got) != 2 {
+		t.Fatalf("illegal pattern is cached, cache=%v", got)
+	}
+
+	SetCacheSize(1) //缩小时立即淘汰
+	if got := cachedExprs(); !reflect.DeepEqual(got, []string{"c+"}) {
+		t.Fatalf("cache=%v", got)
+	}
+	SetCacheSize(-1)
+	if MustCompile("d+") == MustCompile("d+") || len(cachedExprs()) != 0 {
+		t.Fatal("pattern is cached when size<=0")
+	}
+}
+
+func groupsString(match *Match) string {
+	items := make([]string, 0, len(match.Groups))
+	for _, group := range match.Groups {
+		items = append(items, fmt.Sprintf("%v=%q[%v:%v]%v", group.Name, group.Value, group.Start, group.End, group.Matched))
+	}
+	return strings.Join(items, " ")
+}
+
+func TestFindMatch(t *testing.T) {
+	pattern := MustCompile(`(?P<key>\w+)=(?P<value>\d*)(?:;(?P<memo>[a-z]+))?`)
+	content := "x a=1;ok, 中文 b= c=3"
+
+	match := pattern.FindMatch(content)
+	if match == nil || match.Value != "a=1;ok" || match.Start != 2 || match.End != 8 || content[match.Start:match.End] != match.Value {
+		t.Fatalf("match=%+v", match)
+	}
+	if got := groupsString(match); got != `key="a"[2:3]true value="1"[4:5]true memo="ok"[6:8]true` {
+		t.Fatalf("groups=%v", got)
+	}
+
+	matches := pattern.FindAllMatches(content, -1)
+	if len(matches) != 3 {
+		t.Fatalf("matches=%v", len(matches))
+	}
+	//位置是字节偏移量; 空字符串参与了匹配, 没有参与匹配的分组是-1
+	if got := groupsString(matches[1]); got != `key="b"[17:18]true value=""[19:19]true memo=""[-1:-1]false` {
+		t.Fatalf("groups=%v", got)
+	}
+	if matches[1].Group("memo").Matched || matches[1].Group("unknown") != nil {
+		t.Fatal("Group is wrong")
+	}
+	if got := len(pattern.FindAllMatches(content, 2)); got != 2 {
+		t.Fatalf("n=2, matches=%v", got)
+	}
+	if pattern.FindMatch("none") != nil || pattern.FindAllMatches("none", -1) != nil || pattern.FindGroupDict("none") != nil {
+		t.Fatal("no match should be nil")
+	}
+}
+
+func TestGroupDict(t *testing.T) {
+	//同名的分组: 取参与了匹配的那个
+	pattern := MustCompile(`(?P<x>a)|(?P<x>b)|(?P<y>c)`)
+	dicts := pattern.FindAllGroupDict("abc", -1)
+	want := []map[string]string{{"x": "a", "y": ""}, {"x": "b", "y": ""}, {"x": "", "y": "c"}}
+	if !reflect.DeepEqual(dicts, want) {
+		t.Fatalf("dicts=%v", dicts)
+	}
+	match := pattern.FindMatch("b")
+	if group := match.Group("x"); group.Value != "b" || group.Start != 0 || !group.Matched {
+		t.Fatalf("group=%+v", group)
+	}
+	if group := pattern.FindMatch("c").Group("x"); group.Matched || group.Start != -1 {
+		t.Fatalf("group=%+v", group)
+	}
+	if got := pattern.FindAllGroupDict("abc", 1); !reflect.DeepEqual(got, want[:1]) {
+		t.Fatalf("n=1, dicts=%v", got)
+	}
+	if got := pattern.FindGroupDict("zzc"); !reflect.DeepEqual(got, want[2]) {
+		t.Fatalf("dict=%v", got)
+	}
+}
+
+func TestCalcAllGroupDict(t *testing.T) {
+	dicts, err := CalcAllGroupDict(`(?P<name>\w+):(?P<age>\d+)?`, "tom:12 amy:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []map[string]string{{"name": "tom", "age": "12"}, {"name": "amy", "age": ""}}; !reflect.DeepEqual(dicts, want) {
+		t.Fatalf("dicts=%v", dicts)
+	}
+	if dicts, err = CalcAllGroupDict(`(?P<name>\w+)`, ""); err != nil || dicts != nil {
+		t.Fatalf("no match, dicts=%v, err=%v", dicts, err)
+	}
+	if _, err = CalcAllGroupDict(`(?P<name>\w+`, "a"); err == nil {
+		t.Fatal("illegal pattern is not rejected")
+	}
+}
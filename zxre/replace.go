@@ -0,0 +1,253 @@
+package zxre
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 模板中可以使用的转换函数, 写法是"${name|upper|trim}".
+type TransformFunc func(value string) string
+
+var transforms = map[string]TransformFunc{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"title": func(value string) string { //首字母大写
+		r, size := utf8.DecodeRuneInString(value)
+		if size == 0 {
+			return value
+		}
+		return string(unicode.ToUpper(r)) + value[size:]
+	},
+}
+var transformsMutex sync.RWMutex
+
+// 注册(或者覆盖)一个转换函数.
+func RegisterTransform(name string, fn TransformFunc) {
+	transformsMutex.Lock()
+	transforms[name] = fn
+	transformsMutex.Unlock()
+}
+
+/*
+展开模板, 模板的语法:
+
+	${name}             命名分组的值(没有参与匹配时是空字符串), ${0}是整个匹配
+	${name|lower|trim}  依次经过转换函数(见RegisterTransform)
+	$$                  一个"$"
+
+不存在的分组或转换函数会返回error.
+*/
+func ExpandTemplate(template string, match *Match) (string, error) {
+	var builder strings.Builder
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		if c != '$' {
+			builder.WriteByte(c)
+			continue
+		}
+		if i+1 < len(template) && template[i+1] == '$' {
+			builder.WriteByte('$')
+			i++
+			continue
+		}
+		if i+1 >= len(template) || template[i+1] != '{' {
+			return "", errors.New(fmt.Sprintf("illegal template, offset=%v, template=%v", i, template))
+		}
+		end := strings.IndexByte(template[i:], '}')
+		if end < 0 {
+			return "", errors.New(fmt.Sprintf("unclosed ${, offset=%v, template=%v", i, template))
+		}
+		parts := strings.Split(template[i+2:i+end], "|")
+		var value string
+		if name := strings.TrimSpace(parts[0]); name == "0" {
+			value = match.Value
+		} else if group := match.Group(name); group != nil {
+			value = group.Value
+		} else {
+			return "", errors.New(fmt.Sprintf("unknown group=%v", name))
+		}
+		for _, transformName := range parts[1:] {
+			transformName = strings.TrimSpace(transformName)
+			transformsMutex.RLock()
+			fn, ok := transforms[transformName]
+			transformsMutex.RUnlock()
+			if !ok {
+				return "", errors.New(fmt.Sprintf("unknown transform=%v", transformName))
+			}
+			value = fn(value)
+		}
+		builder.WriteString(value)
+		i += end
+	}
+	return builder.String(), nil
+}
+
+// 一处替换. Line/Column从1开始(Column是字节偏移), Start/End是在原文中的偏移量.
+type Replacement struct {
+	Start  int
+	End    int
+	Line   int
+	Column int
+	Old    string
+	New    string
+}
+
+func (self *Replacement) String() string {
+	return fmt.Sprintf("%v:%v [%v] => [%v]", self.Line, self.Column, self.Old, self.New)
+}
+
+// 用fn的返回值替换最多n个匹配(n<0时替换所有的匹配), 返回替换后的内容和每一处替换(可以用于dry-run).
+// fn返回error时停止并返回这个error.
+func (self *Pattern) ReplaceFunc(content string, n int, fn func(match *Match) (string, error)) (result string, replacements []*Replacement, err error) {
+	var builder strings.Builder
+	last, line, lineStart := 0, 1, 0
+	for _, match := range self.FindAllMatches(content, n) {
+		var replaced string
+		if replaced, err = fn(match); err != nil {
+			return
+		}
+		for i := last; i < match.Start; i++ {
+			if content[i] == '\n' {
+				line++
+				lineStart = i + 1
+			}
+		}
+		replacements = append(replacements, &Replacement{
+			Start:  match.Start,
+			End:    match.End,
+			Line:   line,
+			Column: match.Start - lineStart + 1,
+			Old:    match.Value,
+			New:    replaced,
+		})
+		builder.WriteString(content[last:match.Start])
+		builder.WriteString(replaced)
+		for i := match.Start; i < match.End; i++ { //匹配的内容里面也可能有换行
+			if content[i] == '\n' {
+				line++
+				lineStart = i + 1
+			}
+		}
+		last = match.End
+	}
+	builder.WriteString(content[last:])
+	result = builder.String()
+	return
+}
+
+// 用模板(语法见ExpandTemplate)替换最多n个匹配, n<0时替换所有的匹配.
+func (self *Pattern) ReplaceTemplate(content string, n int, template string) (result string, replacements []*Replacement, err error) {
+	return self.ReplaceFunc(content, n, func(match *Match) (string, error) {
+		return ExpandTemplate(template, match)
+	})
+}
+
+// 替换所有的匹配, pattern有语法错误时返回error.
+func ReplaceAll(pattern string, content string, template string) (string, error) {
+	patternObj, err := Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	result, _, err := patternObj.ReplaceTemplate(content, -1, template)
+	return result, err
+}
+
+type RenameOptions struct {
+	DryRun    bool //只返回计划, 不实际改名
+	Recursive bool //也处理子目录中的文件(目录本身不改名)
+	Overwrite bool //目标文件已经存在时覆盖它, 否则返回error
+}
+
+type Rename struct {
+	From string
+	To   string
+}
+
+/*
+批量改名: 文件名(不含目录)匹配pattern时, 把第一个匹配按模板替换, 得到新的文件名. 例如:
+
+	zxre.RenameFiles("photos", `^IMG_(?P<date>\d{8})_(?P<seq>\d+)\.(?P<ext>\w+)$`, "${date}-${seq}.${ext|lower}", &zxre.RenameOptions{DryRun: true})
+
+先检查所有的冲突(两个文件改成同一个名字, 或者目标已经存在), 没有冲突才开始改名.
+*/
+func RenameFiles(dir string, pattern string, template string, opts *RenameOptions) (renames []*Rename, err error) {
+	if opts == nil {
+		opts = &RenameOptions{}
+	}
+	var patternObj *Pattern
+	if patternObj, err = Compile(pattern); err != nil {
+		return
+	}
+
+	err = filepath.WalkDir(dir, func(path string, entry os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if entry.IsDir() {
+			if path != dir && !opts.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := entry.Name()
+		if !patternObj.MatchString(name) {
+			return nil
+		}
+		newName, _, err := patternObj.ReplaceTemplate(name, 1, template)
+		if err != nil {
+			return errors.New(fmt.Sprintf("%v: %v", path, err))
+		}
+		if newName == name {
+			return nil
+		}
+		if len(newName) == 0 || strings.ContainsAny(newName, `/\`) {
+			return errors.New(fmt.Sprintf("%v: illegal new name=%v", path, newName))
+		}
+		renames = append(renames, &Rename{From: path, To: filepath.Join(filepath.Dir(path), newName)})
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	sort.Slice(renames, func(i, j int) bool { return renames[i].From < renames[j].From })
+
+	sources := make(map[string]bool)
+	for _, rename := range renames {
+		sources[rename.From] = true
+	}
+	targets := make(map[string]string)
+	for _, rename := range renames {
+		if other, ok := targets[rename.To]; ok {
+			err = errors.New(fmt.Sprintf("%v and %v both rename to %v", other, rename.From, rename.To))
+			return
+		}
+		targets[rename.To] = rename.From
+		if sources[rename.To] { //目标是另一个要改名的源文件(比如a->b,b->c), 这种情况请分两次改名
+			err = errors.New(fmt.Sprintf("%v: target %v is also renamed", rename.From, rename.To))
+			return
+		}
+		if _, statErr := os.Lstat(rename.To); statErr == nil && !opts.Overwrite {
+			err = errors.New(fmt.Sprintf("%v: target %v already exists", rename.From, rename.To))
+			return
+		}
+	}
+
+	if opts.DryRun {
+		return
+	}
+	for idx, rename := range renames {
+		if err = os.Rename(rename.From, rename.To); err != nil {
+			renames = renames[:idx] //返回已经完成的改名
+			return
+		}
+	}
+	return
+}
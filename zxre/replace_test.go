@@ -0,0 +1,228 @@
+package zxre
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	RegisterTransform("reverse", func(value string) string {
+		runes := []rune(value)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes)
+	})
+	match := MustCompile(`(?P<name> *\w+ *)-(?P<ext>\w+)(?P<opt>!)?`).FindMatch(" Hello -TXT")
+
+	cases := []struct {
+		template string
+		want     string
+		err      string
+	}{
+		{"plain", "plain", ""},
+		{"${name}.${ext}", " Hello .TXT", ""},
+		{"[${0}]", "[ Hello -TXT]", ""},
+		{"${ name | trim | lower }", "hello", ""},
+		{"${ext|lower|title}", "Txt", ""},
+		{"${name|trim|reverse}", "olleH", ""},
+		{"${opt}|", "|", ""}, //没有参与匹配的分组是空字符串
+		{"$$${ext}$$", "$TXT$", ""},
+		{"中文${ext|lower}", "中文txt", ""},
+		{"", "", ""},
+		{"${unknown}", "", "unknown group=unknown"},
+		{"${}", "", "unknown group="},
+		{"${ext|unknown}", "", "unknown transform=unknown"},
+		{"${ext", "", "unclosed ${"},
+		{"$ext", "", "illegal template, offset=0"},
+		{"a$", "", "illegal template, offset=1"},
+	}
+	for _, c := range cases {
+		got, err := ExpandTemplate(c.template, match)
+		if len(c.err) != 0 {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%q: err=%v, want %v", c.template, err, c.err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%q: got %q, err=%v, want %q", c.template, got, err, c.want)
+		}
+	}
+}
+
+func replacementsString(replacements []*Replacement) string {
+	items := make([]string, 0, len(replacements))
+	for _, replacement := range replacements {
+		items = append(items, replacement.String())
+	}
+	return strings.Join(items, ", ")
+}
+
+func TestReplaceFunc(t *testing.T) {
+	pattern := MustCompile(`(?s)<(?P<tag>\w+)>.*?</\w+>`)
+	content := "a <b>x</b> <i>y</i>\n中文 <p>multi\nline</p> c <u>u</u>\n\n<s>z</s>"
+	result, replacements, err := pattern.ReplaceFunc(content, -1, func(match *Match) (string, error) {
+		return "[" + match.Group("tag").Value + "]", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "a [b] [i]\n中文 [p] c [u]\n\n[s]" {
+		t.Fatalf("result=%q", result)
+	}
+	//Column是字节偏移, 匹配里的换行也要计算
+	want := "1:3 [<b>x</b>] => [[b]], 1:12 [<i>y</i>] => [[i]], 2:8 [<p>multi\nline</p>] => [[p]], 3:12 [<u>u</u>] => [[u]], 5:1 [<s>z</s>] => [[s]]"
+	if got := replacementsString(replacements); got != want {
+		t.Fatalf("got  %v\nwant %v", got, want)
+	}
+	for _, replacement := range replacements {
+		if content[replacement.Start:replacement.End] != replacement.Old {
+			t.Fatalf("replacement=%+v", replacement)
+		}
+	}
+
+	if result, replacements, err = pattern.ReplaceTemplate(content, 2, "${tag|upper}"); err != nil || len(replacements) != 2 || !strings.HasPrefix(result, "a B I\n中文 <p>") {
+		t.Fatalf("n=2, result=%q, err=%v", result, err)
+	}
+	failure := errors.New("stop")
+	if _, _, err = pattern.ReplaceFunc(content, -1, func(match *Match) (string, error) { return "", failure }); err != failure {
+		t.Fatalf("err=%v", err)
+	}
+	if result, replacements, err = pattern.ReplaceFunc("none", -1, nil); err != nil || result != "none" || len(replacements) != 0 {
+		t.Fatalf("no match, result=%q, err=%v", result, err)
+	}
+
+	if result, err = ReplaceAll(`(?P<word>\w+)@`, "a@ b@", "<${word}>"); err != nil || result != "<a> <b>" {
+		t.Fatalf("result=%q, err=%v", result, err)
+	}
+	if _, err = ReplaceAll(`(`, "a", ""); err == nil {
+		t.Fatal("illegal pattern is not rejected")
+	}
+}
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	names := make([]string, 0)
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			relPath, _ := filepath.Rel(dir, path)
+			names = append(names, filepath.ToSlash(relPath))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const renamePattern = `^IMG_(?P<date>\d{8})_(?P<seq>\d+)\.(?P<ext>\w+)$`
+
+func TestRenameFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "IMG_20240102_1.JPG", "IMG_20240102_2.jpg", "other.txt", "sub/IMG_20240103_1.PNG")
+
+	renames, err := RenameFiles(dir, renamePattern, "${date}-${seq}.${ext|lower}", &RenameOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renames) != 2 || renames[0].To != filepath.Join(dir, "20240102-1.jpg") {
+		t.Fatalf("renames=%+v", renames)
+	}
+	before := listFiles(t, dir)
+	if !reflect.DeepEqual(before, []string{"IMG_20240102_1.JPG", "IMG_20240102_2.jpg", "other.txt", "sub/IMG_20240103_1.PNG"}) {
+		t.Fatalf("DryRun renamed files, files=%v", before)
+	}
+
+	if renames, err = RenameFiles(dir, renamePattern, "${date}-${seq}.${ext|lower}", &RenameOptions{Recursive: true}); err != nil || len(renames) != 3 {
+		t.Fatalf("renames=%+v, err=%v", renames, err)
+	}
+	if got := listFiles(t, dir); !reflect.DeepEqual(got, []string{"20240102-1.jpg", "20240102-2.jpg", "other.txt", "sub/20240103-1.png"}) {
+		t.Fatalf("files=%v", got)
+	}
+	//新旧名字相同的文件不改名
+	if renames, err = RenameFiles(dir, `^(?P<name>other)\.txt$`, "${name}.txt", nil); err != nil || len(renames) != 0 {
+		t.Fatalf("renames=%+v, err=%v", renames, err)
+	}
+}
+
+func TestRenameFilesConflict(t *testing.T) {
+	cases := []struct {
+		name     string
+		files    []string
+		pattern  string
+		template string
+		opts     *RenameOptions
+		err      string
+	}{
+		{"same target", []string{"IMG_20240102_1.jpg", "IMG_20240102_01.jpg"}, `^IMG_(?P<date>\d+)_0*(?P<seq>\d+)\.jpg$`, "${date}-${seq}.jpg", nil, "both rename to"},
+		{"target exists", []string{"IMG_20240102_1.jpg", "20240102-1.jpg"}, renamePattern, "${date}-${seq}.${ext}", nil, "already exists"},
+		{"chain", []string{"a.txt", "b.txt"}, `^(?P<c>[ab])\.txt$`, "${c|upper}b.txt", nil, ""},
+		{"all to one", []string{"a.txt", "b.txt", "c.txt"}, `^[abc]\.txt$`, "x.txt", nil, "both rename to"},
+		{"unchanged target", []string{"a.txt", "b.txt"}, `^a\.txt$|^b\.txt$`, "b.txt", nil, "already exists"}, //b.txt不改名, 还是已经存在的文件
+		{"illegal name", []string{"a.txt"}, `^a\.txt$`, "x/y.txt", nil, "illegal new name"},
+		{"empty name", []string{"a.txt"}, `^a\.txt$`, "", nil, "illegal new name"},
+		{"template error", []string{"a.txt"}, `^a\.txt$`, "${none}", nil, "unknown group"},
+		{"illegal pattern", []string{"a.txt"}, `(`, "b", nil, "missing closing"},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		writeFiles(t, dir, c.files...)
+		renames, err := RenameFiles(dir, c.pattern, c.template, c.opts)
+		if len(c.err) == 0 {
+			if err != nil {
+				t.Errorf("%v: renames=%+v, err=%v", c.name, renames, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%v: err=%v, want %v", c.name, err, c.err)
+		}
+		sort.Strings(c.files)
+		if got := listFiles(t, dir); !reflect.DeepEqual(got, c.files) { //有冲突时一个也不改
+			t.Errorf("%v: files=%v", c.name, got)
+		}
+	}
+
+	//a->b, b->c: 目标是另一个要改名的源文件
+	dir := t.TempDir()
+	writeFiles(t, dir, "1.txt", "2.txt")
+	if _, err := RenameFiles(dir, `^1\.txt$|^2\.txt$`, "${0|next}", nil); err == nil || !strings.Contains(err.Error(), "unknown transform") {
+		t.Fatalf("err=%v", err)
+	}
+	RegisterTransform("next", func(value string) string { return string(value[0]+1) + value[1:] })
+	if _, err := RenameFiles(dir, `^1\.txt$|^2\.txt$`, "${0|next}", nil); err == nil || !strings.Contains(err.Error(), "is also renamed") {
+		t.Fatalf("err=%v", err)
+	}
+	if renames, err := RenameFiles(dir, `^2\.txt$`, "${0|next}", nil); err != nil || len(renames) != 1 {
+		t.Fatalf("renames=%+v, err=%v", renames, err)
+	}
+
+	//Overwrite: 覆盖已经存在的目标
+	dir = t.TempDir()
+	writeFiles(t, dir, "a.txt", "b.txt")
+	if _, err := RenameFiles(dir, `^a\.txt$`, "b.txt", &RenameOptions{Overwrite: true}); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "b.txt")); err != nil || string(content) != "a.txt" {
+		t.Fatalf("content=%q, err=%v", content, err)
+	}
+}
@@ -0,0 +1,143 @@
+package zxre
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/zx9229/zxgo"
+)
+
+// 某一个匹配中缺少的必需分组(分组没有参与匹配, 或者pattern中没有这个分组).
+type MissingGroup struct {
+	Index  int //第几个匹配, 从0开始
+	Groups []string
+}
+
+type MissingGroupsError struct {
+	Missing []*MissingGroup
+}
+
+func (self *MissingGroupsError) Error() string {
+	messages := make([]string, 0, len(self.Missing))
+	for _, missing := range self.Missing {
+		messages = append(messages, fmt.Sprintf("[%v] %v", missing.Index, missing.Groups))
+	}
+	return fmt.Sprintf("missing required groups: %v", strings.Join(messages, "; "))
+}
+
+// 返回所有匹配的命名分组, 没有参与匹配的分组不会出现在map中(这一点和CalcAllGroupDict不同).
+func matchGroups(patternObj *regexp.Regexp, content string, n int) map[int]map[string]string {
+	results := make(map[int]map[string]string)
+	names := patternObj.SubexpNames()
+	for idx, submatches := range patternObj.FindAllStringSubmatchIndex(content, n) {
+		groups := make(map[string]string)
+		for i, name := range names {
+			if len(name) == 0 || submatches[2*i] < 0 {
+				continue
+			}
+			groups[name] = content[submatches[2*i]:submatches[2*i+1]]
+		}
+		results[idx] = groups
+	}
+	return results
+}
+
+/*
+把pattern在content中的所有匹配写入slicePtr(指向[]T或者[]*T, T是结构体).
+分组名对应的字段和类型转换的规则同zxgo.BindByMap, opts为nil时使用默认值. 例如:
+
+	type Record struct {
+		Time  time.Time `zx:"time,required"`
+		Level string    `zx:"level,required"`
+		Cost  int       `zx:"cost"`
+	}
+	var records []Record
+	err := zxre.UnmarshalAll(`(?P<time>\S+ \S+) (?P<level>\w+)( cost=(?P<cost>\d+))?`, content, &records)
+
+标记了required的字段, 对应的分组没有参与匹配时返回 *MissingGroupsError (此时slicePtr仍然会被填充).
+*/
+func UnmarshalAll(pattern string, content string, slicePtr interface{}, opts *zxgo.BindOptions) error {
+	patternObj, err := Compile(pattern)
+	if err != nil {
+		return err
+	}
+	return patternObj.UnmarshalAll(content, -1, slicePtr, opts)
+}
+
+// 同UnmarshalAll, 但是只处理第一个匹配, 写入data(结构体指针). 没有匹配时matched为false.
+func Unmarshal(pattern string, content string, data interface{}, opts *zxgo.BindOptions) (matched bool, err error) {
+	var patternObj *Pattern
+	if patternObj, err = Compile(pattern); err != nil {
+		return
+	}
+	return patternObj.Unmarshal(content, data, opts)
+}
+
+func unmarshalOne(patternObj *regexp.Regexp, content string, data interface{}, opts *zxgo.BindOptions) (matched bool, err error) {
+	elem := reflect.ValueOf(data)
+	if elem.Kind() != reflect.Ptr || elem.IsNil() || elem.Elem().Kind() != reflect.Struct {
+		err = errors.New(fmt.Sprintf("data must be a pointer to struct, type=%T", data))
+		return
+	}
+	slicePtr := reflect.New(reflect.SliceOf(elem.Type()))
+	err = unmarshalGroups(patternObj, content, 1, slicePtr.Interface(), opts)
+	if slice := slicePtr.Elem(); 0 < slice.Len() {
+		matched = true
+		elem.Elem().Set(slice.Index(0).Elem())
+	}
+	return
+}
+
+func unmarshalGroups(patternObj *regexp.Regexp, content string, n int, slicePtr interface{}, opts *zxgo.BindOptions) error {
+	results := matchGroups(patternObj, content, n)
+	if err := zxgo.BindQueryData(slicePtr, results, opts); err != nil {
+		return err
+	}
+
+	tagName, upperKey := zxgo.DefaultTagName, false
+	if opts != nil {
+		if len(opts.TagName) != 0 {
+			tagName = opts.TagName
+		}
+		upperKey = opts.UpperKey
+	}
+	elemType := reflect.TypeOf(slicePtr).Elem().Elem()
+	required := make([]string, 0)
+	for _, fieldKey := range zxgo.FieldKeys(reflect.New(elemType).Interface(), tagName) {
+		for _, option := range strings.Split(fieldKey.Field.Tag.Get(tagName), ",")[1:] {
+			if option == "required" {
+				required = append(required, fieldKey.Key)
+			}
+		}
+	}
+
+	allMissing := make([]*MissingGroup, 0)
+	for i := 0; i < len(results); i++ {
+		groups := results[i]
+		if upperKey {
+			groups = make(map[string]string, len(results[i]))
+			for k, v := range results[i] {
+				groups[strings.ToUpper(k)] = v
+			}
+		}
+		missing := make([]string, 0)
+		for _, key := range required {
+			if upperKey {
+				key = strings.ToUpper(key)
+			}
+			if _, ok := groups[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) != 0 {
+			allMissing = append(allMissing, &MissingGroup{Index: i, Groups: missing})
+		}
+	}
+	if len(allMissing) != 0 {
+		return &MissingGroupsError{allMissing}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package zxre
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/zx9229/zxgo"
+)
+
+type logLine struct {
+	Level string `zx:"level,required"`
+	Cost  int    `zx:"cost"`
+	User  string `zx:"user,required"`
+}
+
+const logPattern = `(?P<level>[A-Z]+)(?: cost=(?P<cost>\d+))?(?: user=(?P<user>\w+))?;`
+
+func TestUnmarshalAll(t *testing.T) {
+	var lines []logLine
+	if err := UnmarshalAll(logPattern, "INFO cost=12 user=tom; WARN user=amy;", &lines, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []logLine{{"INFO", 12, "tom"}, {"WARN", 0, "amy"}}
+	if !reflect.DeepEqual(lines, want) {
+		t.Fatalf("lines=%+v", lines)
+	}
+
+	//required的分组没有参与匹配: 返回MissingGroupsError, 但是slicePtr仍然被填充
+	var ptrs []*logLine
+	err := UnmarshalAll(logPattern, "INFO user=tom; WARN cost=3; ERROR;", &ptrs, nil)
+	var missingErr *MissingGroupsError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("err=%v", err)
+	}
+	if len(missingErr.Missing) != 2 || missingErr.Missing[0].Index != 1 || missingErr.Missing[1].Index != 2 || !reflect.DeepEqual(missingErr.Missing[1].Groups, []string{"user"}) {
+		t.Fatalf("err=%v", err)
+	}
+	if err.Error() != "missing required groups: [1] [user]; [2] [user]" {
+		t.Fatalf("err=%v", err)
+	}
+	if len(ptrs) != 3 || *ptrs[1] != (logLine{"WARN", 3, ""}) {
+		t.Fatalf("ptrs=%+v", ptrs)
+	}
+
+	//pattern中没有这个分组也算缺少
+	if err = UnmarshalAll(`(?P<level>[A-Z]+);`, "INFO;", &lines, nil); !errors.As(err, &missingErr) || missingErr.Error() != "missing required groups: [0] [user]" {
+		t.Fatalf("err=%v", err)
+	}
+	if err = UnmarshalAll(`(`, "", &lines, nil); err == nil {
+		t.Fatal("illegal pattern is not rejected")
+	}
+}
+
+func TestUnmarshalUpperKey(t *testing.T) {
+	type upperLine struct {
+		Level string `zx:"LEVEL,required"`
+		User  string `zx:"User,required"`
+	}
+	var line upperLine
+	matched, err := Unmarshal(logPattern, "x INFO user=tom;", &line, &zxgo.BindOptions{UpperKey: true})
+	if err != nil || !matched || line != (upperLine{"INFO", "tom"}) {
+		t.Fatalf("matched=%v, line=%+v, err=%v", matched, line, err)
+	}
+	var missingErr *MissingGroupsError
+	if _, err = Unmarshal(logPattern, "INFO;", &line, &zxgo.BindOptions{UpperKey: true}); !errors.As(err, &missingErr) || missingErr.Missing[0].Groups[0] != "USER" {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	line := logLine{Level: "old"}
+	matched, err := Unmarshal(logPattern, "none", &line, nil)
+	if err != nil || matched || line.Level != "old" {
+		t.Fatalf("no match, matched=%v, line=%+v, err=%v", matched, line, err)
+	}
+	//只处理第一个匹配
+	if matched, err = MustCompile(logPattern).Unmarshal("DEBUG cost=1 user=a; INFO user=b;", &line, nil); err != nil || !matched || line != (logLine{"DEBUG", 1, "a"}) {
+		t.Fatalf("matched=%v, line=%+v, err=%v", matched, line, err)
+	}
+
+	for _, data := range []interface{}{line, (*logLine)(nil), new(int), nil} {
+		if _, err = Unmarshal(logPattern, "INFO user=a;", data, nil); err == nil {
+			t.Errorf("data=%#v is not rejected", data)
+		}
+	}
+}
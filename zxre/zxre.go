@@ -8,28 +8,13 @@ package zxre
 4. 点击 regexp
 5. 查看 Index 和 Examples
 */
-import "regexp"
 
-func CalcAllGroupDict(pattern string, content string) []map[string]string {
-	all_group_dict_ := make([]map[string]string, 0)
-
-	patternObj := regexp.MustCompile(pattern)
-	for _, submatches := range patternObj.FindAllStringSubmatchIndex(content, -1) {
-		cur_group_dict_ := map[string]string{}
-		for _, key := range patternObj.SubexpNames() {
-			if len(key) == 0 {
-				continue
-			}
-			result := []byte{}
-			result = patternObj.ExpandString(result, "${"+key+"}", content, submatches)
-			cur_group_dict_[key] = string(result)
-		}
-		all_group_dict_ = append(all_group_dict_, cur_group_dict_)
-	}
-
-	if len(all_group_dict_) == 0 {
-		all_group_dict_ = nil
+// 所有匹配的命名分组字典(没有参与匹配的分组是空字符串), 没有匹配时返回nil, pattern有语法错误时返回error.
+// 编译结果会被缓存, 所以可以在循环中反复调用.
+func CalcAllGroupDict(pattern string, content string) ([]map[string]string, error) {
+	patternObj, err := Compile(pattern)
+	if err != nil {
+		return nil, err
 	}
-
-	return all_group_dict_
+	return patternObj.FindAllGroupDict(content, -1), nil
 }
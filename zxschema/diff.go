@@ -0,0 +1,114 @@
+package zxschema
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	TableAdded        string = "TableAdded"
+	TableRemoved      string = "TableRemoved"
+	ColumnAdded       string = "ColumnAdded"
+	ColumnRemoved     string = "ColumnRemoved"
+	ColumnChanged     string = "ColumnChanged"
+	PrimaryKeyChanged string = "PrimaryKeyChanged"
+	IndexAdded        string = "IndexAdded"
+	IndexRemoved      string = "IndexRemoved"
+	IndexChanged      string = "IndexChanged"
+)
+
+// 一处差异. Name是字段名或索引名(表级别的差异时为空), From/To是变化前后的描述.
+type Change struct {
+	Kind  string
+	Table string
+	Name  string
+	From  string
+	To    string
+}
+
+func (self *Change) String() string {
+	if len(self.Name) == 0 {
+		return fmt.Sprintf("%v %v [%v] => [%v]", self.Kind, self.Table, self.From, self.To)
+	}
+	return fmt.Sprintf("%v %v.%v [%v] => [%v]", self.Kind, self.Table, self.Name, self.From, self.To)
+}
+
+// 计算从from变成to需要的变化. 类型名的比较不区分大小写.
+func Diff(from, to *Schema) []*Change {
+	changes := make([]*Change, 0)
+
+	for _, fromTable := range from.Tables {
+		if to.Table(fromTable.Name) == nil {
+			changes = append(changes, &Change{Kind: TableRemoved, Table: fromTable.Name})
+		}
+	}
+	for _, toTable := range to.Tables {
+		fromTable := from.Table(toTable.Name)
+		if fromTable == nil {
+			changes = append(changes, &Change{Kind: TableAdded, Table: toTable.Name})
+			continue
+		}
+		changes = append(changes, DiffTable(fromTable, toTable)...)
+	}
+
+	return changes
+}
+
+func DiffTable(from, to *Table) []*Change {
+	changes := make([]*Change, 0)
+
+	for _, fromColumn := range from.Columns {
+		if to.Column(fromColumn.Name) == nil {
+			changes = append(changes, &Change{Kind: ColumnRemoved, Table: to.Name, Name: fromColumn.Name, From: fromColumn.describe()})
+		}
+	}
+	for _, toColumn := range to.Columns {
+		fromColumn := from.Column(toColumn.Name)
+		if fromColumn == nil {
+			changes = append(changes, &Change{Kind: ColumnAdded, Table: to.Name, Name: toColumn.Name, To: toColumn.describe()})
+		} else if fromColumn.describe() != toColumn.describe() {
+			changes = append(changes, &Change{Kind: ColumnChanged, Table: to.Name, Name: toColumn.Name, From: fromColumn.describe(), To: toColumn.describe()})
+		}
+	}
+
+	if fromPks, toPks := strings.Join(from.PrimaryKeys, ","), strings.Join(to.PrimaryKeys, ","); fromPks != toPks {
+		changes = append(changes, &Change{Kind: PrimaryKeyChanged, Table: to.Name, From: fromPks, To: toPks})
+	}
+
+	for _, fromIndex := range from.Indexes {
+		if to.Index(fromIndex.Name) == nil {
+			changes = append(changes, &Change{Kind: IndexRemoved, Table: to.Name, Name: fromIndex.Name, From: fromIndex.describe()})
+		}
+	}
+	for _, toIndex := range to.Indexes {
+		fromIndex := from.Index(toIndex.Name)
+		if fromIndex == nil {
+			changes = append(changes, &Change{Kind: IndexAdded, Table: to.Name, Name: toIndex.Name, To: toIndex.describe()})
+		} else if fromIndex.describe() != toIndex.describe() {
+			changes = append(changes, &Change{Kind: IndexChanged, Table: to.Name, Name: toIndex.Name, From: fromIndex.describe(), To: toIndex.describe()})
+		}
+	}
+
+	return changes
+}
+
+func (self *Column) describe() string {
+	content := strings.ToUpper(self.Type)
+	if self.Nullable {
+		content += ",NULL"
+	} else {
+		content += ",NOT NULL"
+	}
+	if self.HasDefault {
+		content += ",DEFAULT " + self.Default
+	}
+	return content
+}
+
+func (self *Index) describe() string {
+	content := "(" + strings.Join(self.Columns, ",") + ")"
+	if self.Unique {
+		content = "UNIQUE" + content
+	}
+	return content
+}
@@ -0,0 +1,338 @@
+package zxschema
+
+/*
+读取已连接数据库的表结构(表,字段,类型,主键,索引),输出与数据库无关的模型.
+	sqlite  : PRAGMA table_info / index_list / index_info
+	mysql   : information_schema(当前库,即DATABASE())
+	postgres: information_schema(当前schema,即current_schema()) + pg_catalog(索引)
+*/
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zx9229/zxgo"
+)
+
+const (
+	SQLITE   string = "sqlite"
+	MYSQL    string = "mysql"
+	POSTGRES string = "postgres"
+)
+
+type Schema struct {
+	Dialect string
+	Tables  []*Table
+}
+
+type Table struct {
+	Name        string
+	Columns     []*Column
+	PrimaryKeys []string //按主键内的顺序排列
+	Indexes     []*Index //不包含主键索引
+}
+
+type Column struct {
+	Name       string
+	Type       string
+	Nullable   bool
+	Default    string
+	HasDefault bool //为false时Default无意义(数据库里是NULL)
+	PrimaryKey bool
+	Position   int //从1开始
+}
+
+type Index struct {
+	Name    string
+	Unique  bool
+	Columns []string
+}
+
+// 把driverName(比如"sqlite3","mysql","pgx")转换成本包的方言名,无法识别时返回空字符串.
+func GuessDialect(driverName string) string {
+	driverName = strings.ToLower(driverName)
+	switch {
+	case strings.HasPrefix(driverName, "sqlite"):
+		return SQLITE
+	case strings.HasPrefix(driverName, "mysql"):
+		return MYSQL
+	case strings.HasPrefix(driverName, "postgres"), driverName == "pgx", driverName == "pq":
+		return POSTGRES
+	default:
+		return ""
+	}
+}
+
+func Inspect(db *sql.DB, dialect string) (schema *Schema, err error) {
+	var inspector func(db *sql.DB, name string) (*Table, error)
+	var tablesQuery string
+	switch dialect {
+	case SQLITE:
+		inspector = inspectSqliteTable
+		tablesQuery = "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name"
+	case MYSQL:
+		inspector = inspectMysqlTable
+		tablesQuery = "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME"
+	case POSTGRES:
+		inspector = inspectPostgresTable
+		tablesQuery = "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' ORDER BY table_name"
+	default:
+		err = errors.New(fmt.Sprintf("Unknown dialect=%v", dialect))
+		return
+	}
+
+	var names []string
+	if names, err = queryStrings(db, tablesQuery); err != nil {
+		return
+	}
+
+	schema = &Schema{Dialect: dialect, Tables: make([]*Table, 0, len(names))}
+	for _, name := range names {
+		var table *Table
+		if table, err = inspector(db, name); err != nil {
+			schema = nil
+			return
+		}
+		schema.Tables = append(schema.Tables, table)
+	}
+
+	return
+}
+
+func (self *Schema) Table(name string) *Table {
+	for _, table := range self.Tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+func (self *Table) Column(name string) *Column {
+	for _, column := range self.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+func (self *Table) Index(name string) *Index {
+	for _, index := range self.Indexes {
+		if index.Name == name {
+			return index
+		}
+	}
+	return nil
+}
+
+func queryStrings(db *sql.DB, query string, args ...interface{}) (results []string, err error) {
+	var rows *sql.Rows
+	if rows, err = db.Query(query, args...); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	results = make([]string, 0)
+	for rows.Next() {
+		var value string
+		if err = rows.Scan(&value); err != nil {
+			return
+		}
+		results = append(results, value)
+	}
+	err = rows.Err()
+
+	return
+}
+
+func sqliteQuote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// PRAGMA的返回列因sqlite版本而不同,所以用zxgo.QueryData按列名读取.
+func sqlitePragma(db *sql.DB, pragma string, name string) (rows []map[string]string, err error) {
+	var results map[int]map[string]string
+	if results, err = zxgo.QueryData(db, fmt.Sprintf("PRAGMA %v(%v)", pragma, sqliteQuote(name))); err != nil {
+		return
+	}
+	rows = make([]map[string]string, len(results))
+	for i := range rows {
+		rows[i] = results[i]
+	}
+	return
+}
+
+func inspectSqliteTable(db *sql.DB, name string) (table *Table, err error) {
+	table = &Table{Name: name, Columns: make([]*Column, 0), PrimaryKeys: make([]string, 0), Indexes: make([]*Index, 0)}
+
+	var rows []map[string]string
+	if rows, err = sqlitePragma(db, "table_info", name); err != nil {
+		return
+	}
+	pkOrder := make(map[string]int)
+	for i, row := range rows {
+		column := &Column{Name: row["name"], Type: row["type"], Nullable: row["notnull"] == "0", Position: i + 1}
+		if dflt := row["dflt_value"]; len(dflt) != 0 { //文本类型的默认值带引号,所以空字符串只能是NULL.
+			column.Default = dflt
+			column.HasDefault = true
+		}
+		if pk, _ := strconv.Atoi(row["pk"]); 0 < pk {
+			column.PrimaryKey = true
+			pkOrder[column.Name] = pk
+			table.PrimaryKeys = append(table.PrimaryKeys, column.Name)
+		}
+		table.Columns = append(table.Columns, column)
+	}
+	sort.SliceStable(table.PrimaryKeys, func(i, j int) bool { return pkOrder[table.PrimaryKeys[i]] < pkOrder[table.PrimaryKeys[j]] })
+
+	if rows, err = sqlitePragma(db, "index_list", name); err != nil {
+		return
+	}
+	for _, row := range rows {
+		if row["origin"] == "pk" {
+			continue
+		}
+		index := &Index{Name: row["name"], Unique: row["unique"] == "1", Columns: make([]string, 0)}
+		var infoRows []map[string]string
+		if infoRows, err = sqlitePragma(db, "index_info", index.Name); err != nil {
+			return
+		}
+		sort.SliceStable(infoRows, func(i, j int) bool {
+			a, _ := strconv.Atoi(infoRows[i]["seqno"])
+			b, _ := strconv.Atoi(infoRows[j]["seqno"])
+			return a < b
+		})
+		for _, infoRow := range infoRows {
+			index.Columns = append(index.Columns, infoRow["name"])
+		}
+		table.Indexes = append(table.Indexes, index)
+	}
+	sortIndexes(table.Indexes)
+
+	return
+}
+
+func inspectMysqlTable(db *sql.DB, name string) (table *Table, err error) {
+	table = &Table{Name: name, Columns: make([]*Column, 0), PrimaryKeys: make([]string, 0), Indexes: make([]*Index, 0)}
+
+	query := "SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, ORDINAL_POSITION FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION"
+	if table.Columns, err = queryColumns(db, query, name); err != nil {
+		return
+	}
+
+	query = "SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? ORDER BY INDEX_NAME, SEQ_IN_INDEX"
+	var indexes []*Index
+	if indexes, err = queryIndexes(db, query, name); err != nil {
+		return
+	}
+	for _, index := range indexes {
+		if index.Name == "PRIMARY" {
+			table.PrimaryKeys = index.Columns
+		} else {
+			table.Indexes = append(table.Indexes, index)
+		}
+	}
+	markPrimaryKeys(table)
+
+	return
+}
+
+func inspectPostgresTable(db *sql.DB, name string) (table *Table, err error) {
+	table = &Table{Name: name, Columns: make([]*Column, 0), PrimaryKeys: make([]string, 0), Indexes: make([]*Index, 0)}
+
+	query := "SELECT column_name, data_type, is_nullable, column_default, ordinal_position FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position"
+	if table.Columns, err = queryColumns(db, query, name); err != nil {
+		return
+	}
+
+	query = "SELECT kcu.column_name FROM information_schema.table_constraints tc" +
+		" JOIN information_schema.key_column_usage kcu ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema AND tc.table_name = kcu.table_name" +
+		" WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = current_schema() AND tc.table_name = $1 ORDER BY kcu.ordinal_position"
+	if table.PrimaryKeys, err = queryStrings(db, query, name); err != nil {
+		return
+	}
+	markPrimaryKeys(table)
+
+	//information_schema里面没有索引的信息.
+	query = "SELECT i.relname, CASE WHEN ix.indisunique THEN 0 ELSE 1 END, a.attname FROM pg_class t" +
+		" JOIN pg_namespace n ON n.oid = t.relnamespace" +
+		" JOIN pg_index ix ON ix.indrelid = t.oid" +
+		" JOIN pg_class i ON i.oid = ix.indexrelid" +
+		" JOIN unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true" +
+		" JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum" +
+		" WHERE n.nspname = current_schema() AND t.relname = $1 AND NOT ix.indisprimary ORDER BY i.relname, k.ord"
+	if table.Indexes, err = queryIndexes(db, query, name); err != nil {
+		return
+	}
+
+	return
+}
+
+// 查询结果的列依次是: 字段名,类型,是否可空(YES/NO),默认值,位置.
+func queryColumns(db *sql.DB, query string, args ...interface{}) (columns []*Column, err error) {
+	var rows *sql.Rows
+	if rows, err = db.Query(query, args...); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	columns = make([]*Column, 0)
+	for rows.Next() {
+		column := new(Column)
+		var nullable string
+		var dflt sql.NullString
+		if err = rows.Scan(&column.Name, &column.Type, &nullable, &dflt, &column.Position); err != nil {
+			return
+		}
+		column.Nullable = strings.ToUpper(nullable) == "YES"
+		column.Default = dflt.String
+		column.HasDefault = dflt.Valid
+		columns = append(columns, column)
+	}
+	err = rows.Err()
+
+	return
+}
+
+// 查询结果的列依次是: 索引名,是否不唯一(0/1),字段名,并且已经按(索引名,索引内顺序)排好序.
+func queryIndexes(db *sql.DB, query string, args ...interface{}) (indexes []*Index, err error) {
+	var rows *sql.Rows
+	if rows, err = db.Query(query, args...); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	indexes = make([]*Index, 0)
+	var current *Index = nil
+	for rows.Next() {
+		var indexName, columnName string
+		var nonUnique int
+		if err = rows.Scan(&indexName, &nonUnique, &columnName); err != nil {
+			return
+		}
+		if current == nil || current.Name != indexName {
+			current = &Index{Name: indexName, Unique: nonUnique == 0, Columns: make([]string, 0)}
+			indexes = append(indexes, current)
+		}
+		current.Columns = append(current.Columns, columnName)
+	}
+	err = rows.Err()
+
+	return
+}
+
+func markPrimaryKeys(table *Table) {
+	for _, pk := range table.PrimaryKeys {
+		if column := table.Column(pk); column != nil {
+			column.PrimaryKey = true
+		}
+	}
+}
+
+func sortIndexes(indexes []*Index) {
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+}
@@ -0,0 +1,126 @@
+//go:build cgo
+
+package zxschema
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openSqlite(t *testing.T, statements ...string) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) //每个连接都是一个独立的内存数据库
+	t.Cleanup(func() { db.Close() })
+	for _, statement := range statements {
+		if _, err = db.Exec(statement); err != nil {
+			t.Fatalf("%v: %v", statement, err)
+		}
+	}
+	return db
+}
+
+func TestInspectSqlite(t *testing.T) {
+	db := openSqlite(t,
+		`PRAGMA foreign_keys = ON`,
+		`CREATE TABLE tb_class (
+			id   INTEGER PRIMARY KEY,
+			name VARCHAR(32) NOT NULL UNIQUE
+		)`,
+		`CREATE TABLE tb_student (
+			class_id INTEGER NOT NULL REFERENCES tb_class(id) ON DELETE CASCADE,
+			seq      INTEGER NOT NULL,
+			name     TEXT DEFAULT 'none',
+			age      INT,
+			PRIMARY KEY (class_id, seq),
+			FOREIGN KEY (class_id) REFERENCES tb_class(id)
+		)`,
+		`CREATE INDEX idx_student_name_age ON tb_student(name, age)`,
+		`CREATE UNIQUE INDEX idx_student_age ON tb_student(age)`,
+	)
+
+	schema, err := Inspect(db, SQLITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Tables) != 2 || schema.Tables[0].Name != "tb_class" || schema.Tables[1].Name != "tb_student" {
+		t.Fatalf("tables=%v", schema.Tables)
+	}
+
+	class := schema.Table("tb_class")
+	if !reflect.DeepEqual(class.PrimaryKeys, []string{"id"}) {
+		t.Fatalf("tb_class PrimaryKeys=%v", class.PrimaryKeys)
+	}
+	if len(class.Indexes) != 1 || !class.Indexes[0].Unique || !reflect.DeepEqual(class.Indexes[0].Columns, []string{"name"}) {
+		t.Fatalf("tb_class Indexes=%+v", class.Indexes)
+	}
+
+	student := schema.Table("tb_student")
+	wantColumns := []*Column{
+		{Name: "class_id", Type: "INTEGER", Nullable: false, PrimaryKey: true, Position: 1},
+		{Name: "seq", Type: "INTEGER", Nullable: false, PrimaryKey: true, Position: 2},
+		{Name: "name", Type: "TEXT", Nullable: true, Default: "'none'", HasDefault: true, Position: 3},
+		{Name: "age", Type: "INT", Nullable: true, Position: 4},
+	}
+	if !reflect.DeepEqual(student.Columns, wantColumns) {
+		for _, column := range student.Columns {
+			t.Logf("%+v", column)
+		}
+		t.Fatal("tb_student Columns mismatch")
+	}
+	if !reflect.DeepEqual(student.PrimaryKeys, []string{"class_id", "seq"}) {
+		t.Fatalf("tb_student PrimaryKeys=%v", student.PrimaryKeys)
+	}
+	wantIndexes := []*Index{ //外键本身不是索引, 主键索引不包含在内
+		{Name: "idx_student_age", Unique: true, Columns: []string{"age"}},
+		{Name: "idx_student_name_age", Unique: false, Columns: []string{"name", "age"}},
+	}
+	if !reflect.DeepEqual(student.Indexes, wantIndexes) {
+		t.Fatalf("tb_student Indexes=%+v", student.Indexes)
+	}
+
+	if _, err = db.Exec(`INSERT INTO tb_student (class_id, seq) VALUES (1, 1)`); err == nil {
+		t.Fatal("foreign key is not enforced")
+	}
+}
+
+func TestInspectSqliteDiff(t *testing.T) {
+	db := openSqlite(t,
+		`CREATE TABLE tb_a (id INTEGER PRIMARY KEY, name TEXT)`,
+		`CREATE TABLE tb_b (id INTEGER PRIMARY KEY, a_id INTEGER REFERENCES tb_a(id))`,
+		`CREATE TABLE tb_c (id INTEGER PRIMARY KEY)`,
+	)
+	from, err := Inspect(db, SQLITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		`ALTER TABLE tb_a ADD COLUMN age INT NOT NULL DEFAULT 0`,
+		`CREATE INDEX idx_b_a ON tb_b(a_id)`,
+		`DROP TABLE tb_c`,
+		`CREATE TABLE tb_d (id INTEGER PRIMARY KEY)`,
+	} {
+		if _, err = db.Exec(statement); err != nil {
+			t.Fatalf("%v: %v", statement, err)
+		}
+	}
+	to, err := Inspect(db, SQLITE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := Diff(from, to)
+	kinds := make(map[string]string)
+	for _, change := range changes {
+		kinds[change.Table+"."+change.Name] = change.Kind
+	}
+	want := map[string]string{"tb_a.age": ColumnAdded, "tb_b.idx_b_a": IndexAdded, "tb_c.": TableRemoved, "tb_d.": TableAdded}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("changes=%v", changes)
+	}
+}
@@ -0,0 +1,311 @@
+package zxsql
+
+/*
+使用例子:
+	query, args, err := zxsql.Select("id", "name").From("tb_student").
+		Where(zxsql.Eq("sex", true), zxsql.Or(zxsql.Gt("age", 18), zxsql.In("id", 1, 2, 3))).
+		OrderByDesc("age").Limit(10).Build(zxsql.DOLLAR)
+	// SELECT id, name FROM tb_student WHERE (sex = $1 AND (age > $2 OR id IN ($3, $4, $5))) ORDER BY age DESC LIMIT 10
+表名,字段名等标识符原样拼接(由调用者保证), 值一律通过参数传递.
+*/
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+type join struct {
+	kind  string
+	table string
+	on    Cond
+}
+
+type SelectBuilder struct {
+	columns  []string
+	table    string
+	distinct bool
+	joins    []*join
+	where    []Cond
+	groupBy  []string
+	having   []Cond
+	orderBy  []string
+	limit    int64
+	offset   int64
+}
+
+func Select(columns ...string) *SelectBuilder {
+	return &SelectBuilder{columns: columns, limit: -1, offset: -1}
+}
+
+func (self *SelectBuilder) Distinct() *SelectBuilder {
+	self.distinct = true
+	return self
+}
+
+func (self *SelectBuilder) From(table string) *SelectBuilder {
+	self.table = table
+	return self
+}
+
+// on不能是nil, 否则Build返回错误.
+func (self *SelectBuilder) Join(table string, on Cond) *SelectBuilder {
+	self.joins = append(self.joins, &join{"JOIN", table, on})
+	return self
+}
+
+func (self *SelectBuilder) LeftJoin(table string, on Cond) *SelectBuilder {
+	self.joins = append(self.joins, &join{"LEFT JOIN", table, on})
+	return self
+}
+
+func (self *SelectBuilder) RightJoin(table string, on Cond) *SelectBuilder {
+	self.joins = append(self.joins, &join{"RIGHT JOIN", table, on})
+	return self
+}
+
+// 多次调用时,所有条件用AND连接.
+func (self *SelectBuilder) Where(conds ...Cond) *SelectBuilder {
+	self.where = append(self.where, conds...)
+	return self
+}
+
+func (self *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	self.groupBy = append(self.groupBy, columns...)
+	return self
+}
+
+func (self *SelectBuilder) Having(conds ...Cond) *SelectBuilder {
+	self.having = append(self.having, conds...)
+	return self
+}
+
+func (self *SelectBuilder) OrderBy(columns ...string) *SelectBuilder {
+	self.orderBy = append(self.orderBy, columns...)
+	return self
+}
+
+func (self *SelectBuilder) OrderByDesc(columns ...string) *SelectBuilder {
+	for _, column := range columns {
+		self.orderBy = append(self.orderBy, column+" DESC")
+	}
+	return self
+}
+
+func (self *SelectBuilder) Limit(limit int64) *SelectBuilder {
+	self.limit = limit
+	return self
+}
+
+func (self *SelectBuilder) Offset(offset int64) *SelectBuilder {
+	self.offset = offset
+	return self
+}
+
+func (self *SelectBuilder) Build(style string) (query string, args []interface{}, err error) {
+	if len(self.table) == 0 {
+		err = errors.New("SELECT without table")
+		return
+	}
+
+	b := &argBuilder{style: style, args: make([]interface{}, 0)}
+	var sb strings.Builder
+
+	sb.WriteString("SELECT ")
+	if self.distinct {
+		sb.WriteString("DISTINCT ")
+	}
+	if len(self.columns) == 0 {
+		sb.WriteString("*")
+	} else {
+		sb.WriteString(strings.Join(self.columns, ", "))
+	}
+	sb.WriteString(" FROM " + self.table)
+	for _, j := range self.joins {
+		if j.on == nil {
+			err = errors.New(j.kind + " without ON, table=" + j.table)
+			return
+		}
+		sb.WriteString(" " + j.kind + " " + j.table + " ON " + j.on.build(b))
+	}
+	if len(self.where) != 0 {
+		sb.WriteString(" WHERE " + And(self.where...).build(b))
+	}
+	if len(self.groupBy) != 0 {
+		sb.WriteString(" GROUP BY " + strings.Join(self.groupBy, ", "))
+	}
+	if len(self.having) != 0 {
+		sb.WriteString(" HAVING " + And(self.having...).build(b))
+	}
+	if len(self.orderBy) != 0 {
+		sb.WriteString(" ORDER BY " + strings.Join(self.orderBy, ", "))
+	}
+	if 0 <= self.offset && self.limit < 0 {
+		err = errors.New("OFFSET without LIMIT")
+		return
+	}
+	if style == NAMED { //oracle 12c以后: OFFSET n ROWS FETCH NEXT m ROWS ONLY
+		if 0 <= self.offset {
+			sb.WriteString(" OFFSET " + strconv.FormatInt(self.offset, 10) + " ROWS")
+		}
+		if 0 <= self.limit {
+			sb.WriteString(" FETCH NEXT " + strconv.FormatInt(self.limit, 10) + " ROWS ONLY")
+		}
+	} else {
+		if 0 <= self.limit {
+			sb.WriteString(" LIMIT " + strconv.FormatInt(self.limit, 10))
+		}
+		if 0 <= self.offset {
+			sb.WriteString(" OFFSET " + strconv.FormatInt(self.offset, 10))
+		}
+	}
+	if b.err != nil {
+		err = b.err
+		return
+	}
+
+	query = sb.String()
+	args = b.args
+	return
+}
+
+type InsertBuilder struct {
+	table   string
+	columns []string
+	rows    [][]interface{}
+}
+
+func Insert(table string) *InsertBuilder {
+	return &InsertBuilder{table: table}
+}
+
+func (self *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	self.columns = columns
+	return self
+}
+
+// 每调用一次添加一行, 值的个数必须和Columns的个数相同.
+func (self *InsertBuilder) Values(values ...interface{}) *InsertBuilder {
+	self.rows = append(self.rows, values)
+	return self
+}
+
+// 单行插入时的便捷写法, 等价于依次添加一个字段和它的值.
+func (self *InsertBuilder) Set(column string, value interface{}) *InsertBuilder {
+	if len(self.rows) == 0 {
+		self.rows = append(self.rows, make([]interface{}, 0))
+	}
+	self.columns = append(self.columns, column)
+	self.rows[0] = append(self.rows[0], value)
+	return self
+}
+
+func (self *InsertBuilder) Build(style string) (query string, args []interface{}, err error) {
+	if len(self.table) == 0 || len(self.columns) == 0 || len(self.rows) == 0 {
+		err = errors.New("INSERT without table, columns or values")
+		return
+	}
+
+	b := &argBuilder{style: style, args: make([]interface{}, 0)}
+	tuples := make([]string, 0, len(self.rows))
+	for idx, row := range self.rows {
+		if len(row) != len(self.columns) {
+			err = errors.New("INSERT values count mismatch, row=" + strconv.Itoa(idx))
+			return
+		}
+		holders := make([]string, 0, len(row))
+		for _, value := range row {
+			holders = append(holders, b.bind(value))
+		}
+		tuples = append(tuples, "("+strings.Join(holders, ", ")+")")
+	}
+
+	query = "INSERT INTO " + self.table + " (" + strings.Join(self.columns, ", ") + ") VALUES " + strings.Join(tuples, ", ")
+	args = b.args
+	return
+}
+
+type UpdateBuilder struct {
+	table   string
+	columns []string
+	values  []interface{}
+	where   []Cond
+}
+
+func Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{table: table}
+}
+
+func (self *UpdateBuilder) Set(column string, value interface{}) *UpdateBuilder {
+	self.columns = append(self.columns, column)
+	self.values = append(self.values, value)
+	return self
+}
+
+func (self *UpdateBuilder) Where(conds ...Cond) *UpdateBuilder {
+	self.where = append(self.where, conds...)
+	return self
+}
+
+// 没有WHERE条件(或者WHERE恒真, 比如 Where(nil), Where(NotIn("id")))时返回错误, 确实要更新全表请使用 Where(AllRows()).
+func (self *UpdateBuilder) Build(style string) (query string, args []interface{}, err error) {
+	if len(self.table) == 0 || len(self.columns) == 0 {
+		err = errors.New("UPDATE without table or columns")
+		return
+	}
+
+	b := &argBuilder{style: style, args: make([]interface{}, 0)}
+	sets := make([]string, 0, len(self.columns))
+	for idx, column := range self.columns {
+		sets = append(sets, column+" = "+b.bind(self.values[idx]))
+	}
+	where := And(self.where...).build(b)
+	if b.err != nil {
+		err = b.err
+		return
+	}
+	if where == condTrue && !b.allRows {
+		err = errors.New("UPDATE without WHERE")
+		return
+	}
+
+	query = "UPDATE " + self.table + " SET " + strings.Join(sets, ", ") + " WHERE " + where
+	args = b.args
+	return
+}
+
+type DeleteBuilder struct {
+	table string
+	where []Cond
+}
+
+func Delete(table string) *DeleteBuilder {
+	return &DeleteBuilder{table: table}
+}
+
+func (self *DeleteBuilder) Where(conds ...Cond) *DeleteBuilder {
+	self.where = append(self.where, conds...)
+	return self
+}
+
+// 没有WHERE条件(或者WHERE恒真)时返回错误, 确实要删除全表请使用 Where(AllRows()).
+func (self *DeleteBuilder) Build(style string) (query string, args []interface{}, err error) {
+	if len(self.table) == 0 {
+		err = errors.New("DELETE without table")
+		return
+	}
+
+	b := &argBuilder{style: style, args: make([]interface{}, 0)}
+	where := And(self.where...).build(b)
+	if b.err != nil {
+		err = b.err
+		return
+	}
+	if where == condTrue && !b.allRows {
+		err = errors.New("DELETE without WHERE")
+		return
+	}
+
+	query = "DELETE FROM " + self.table + " WHERE " + where
+	args = b.args
+	return
+}
@@ -0,0 +1,129 @@
+package zxsql
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectBuilder(t *testing.T) {
+	cases := []struct {
+		name    string
+		builder *SelectBuilder
+		style   string
+		query   string
+		args    []interface{}
+	}{
+		{"simple", Select().From("tb_user"), QUESTION, "SELECT * FROM tb_user", []interface{}{}},
+		{"where",
+			Select("id", "name").From("tb_student").Where(Eq("sex", true), Or(Gt("age", 18), In("id", 1, 2, 3))).OrderByDesc("age").Limit(10),
+			DOLLAR,
+			"SELECT id, name FROM tb_student WHERE (sex = $1 AND (age > $2 OR id IN ($3, $4, $5))) ORDER BY age DESC LIMIT 10",
+			[]interface{}{true, 18, 1, 2, 3}},
+		{"join",
+			Select("u.id").Distinct().From("tb_user u").LeftJoin("tb_dept d", Expr("d.id = u.dept_id AND d.state = ?", 1)).Where(Like("u.name", "a%")),
+			QUESTION,
+			"SELECT DISTINCT u.id FROM tb_user u LEFT JOIN tb_dept d ON d.id = u.dept_id AND d.state = ? WHERE u.name LIKE ?",
+			[]interface{}{1, "a%"}},
+		{"group by",
+			Select("dept_id", "COUNT(*)").From("tb_user").GroupBy("dept_id").Having(Expr("COUNT(*) > ?", 5)).OrderBy("dept_id"),
+			DOLLAR,
+			"SELECT dept_id, COUNT(*) FROM tb_user GROUP BY dept_id HAVING COUNT(*) > $1 ORDER BY dept_id",
+			[]interface{}{5}},
+		{"eq nil", Select().From("tb_user").Where(Eq("deleted", nil), Ne("name", nil)), QUESTION,
+			"SELECT * FROM tb_user WHERE (deleted IS NULL AND name IS NOT NULL)", []interface{}{}},
+		{"empty in", Select().From("tb_user").Where(In("id"), NotIn("id")), QUESTION, "SELECT * FROM tb_user WHERE 1 = 0", []interface{}{}},
+		{"or absorbs", Select().From("tb_user").Where(Or(Eq("a", 1), NotIn("id"))), QUESTION, "SELECT * FROM tb_user WHERE 1 = 1", []interface{}{}},
+		{"not", Select().From("tb_user").Where(Not(Between("age", 1, 2)), Not(In("id"))), QUESTION,
+			"SELECT * FROM tb_user WHERE NOT (age BETWEEN ? AND ?)", []interface{}{1, 2}},
+		{"limit offset", Select().From("tb_user").Limit(10).Offset(20), DOLLAR, "SELECT * FROM tb_user LIMIT 10 OFFSET 20", []interface{}{}},
+		{"named",
+			Select().From("tb_user").Where(Eq("name", "a"), Expr("'?' <> ?", "b")).Limit(10).Offset(20),
+			NAMED,
+			"SELECT * FROM tb_user WHERE (name = :p1 AND '?' <> :p2) OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY",
+			[]interface{}{sql.Named("p1", "a"), sql.Named("p2", "b")}},
+		{"named limit", Select().From("tb_user").Limit(1), NAMED, "SELECT * FROM tb_user FETCH NEXT 1 ROWS ONLY", []interface{}{}},
+	}
+	for _, c := range cases {
+		query, args, err := c.builder.Build(c.style)
+		if err != nil {
+			t.Errorf("%v: %v", c.name, err)
+			continue
+		}
+		if query != c.query || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%v:\ngot  %v %v\nwant %v %v", c.name, query, args, c.query, c.args)
+		}
+	}
+}
+
+func TestSelectBuilderErrors(t *testing.T) {
+	cases := []struct {
+		builder *SelectBuilder
+		err     string
+	}{
+		{Select("id"), "SELECT without table"},
+		{Select().From("a").Join("b", nil), "JOIN without ON, table=b"},
+		{Select().From("a").RightJoin("b", nil), "RIGHT JOIN without ON, table=b"},
+		{Select().From("a").Offset(1), "OFFSET without LIMIT"},
+		{Select().From("a").Where(Expr("a = ? AND b = ?", 1)), "placeholder count mismatch"},
+	}
+	for _, c := range cases {
+		if _, _, err := c.builder.Build(QUESTION); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("err=%v, want %v", err, c.err)
+		}
+	}
+}
+
+func TestInsertBuilder(t *testing.T) {
+	query, args, err := Insert("tb_user").Columns("id", "name").Values(1, "a").Values(2, nil).Build(DOLLAR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "INSERT INTO tb_user (id, name) VALUES ($1, $2), ($3, $4)" || !reflect.DeepEqual(args, []interface{}{1, "a", 2, nil}) {
+		t.Fatalf("query=%v, args=%v", query, args)
+	}
+
+	if query, _, err = Insert("tb_user").Set("id", 1).Set("name", "a").Build(QUESTION); err != nil || query != "INSERT INTO tb_user (id, name) VALUES (?, ?)" {
+		t.Fatalf("query=%v, err=%v", query, err)
+	}
+	if _, _, err = Insert("tb_user").Columns("id", "name").Values(1).Build(QUESTION); err == nil {
+		t.Fatal("values count mismatch is not rejected")
+	}
+	if _, _, err = Insert("tb_user").Build(QUESTION); err == nil {
+		t.Fatal("INSERT without columns is not rejected")
+	}
+}
+
+func TestUpdateDeleteBuilder(t *testing.T) {
+	query, args, err := Update("tb_user").Set("name", "a").Set("memo", nil).Where(Eq("id", 1)).Build(QUESTION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//SET里的nil是赋值, 不能变成IS NULL
+	if query != "UPDATE tb_user SET name = ?, memo = ? WHERE id = ?" || !reflect.DeepEqual(args, []interface{}{"a", nil, 1}) {
+		t.Fatalf("query=%v, args=%v", query, args)
+	}
+	if query, _, err = Update("tb_user").Set("state", 0).Where(AllRows()).Build(QUESTION); err != nil || query != "UPDATE tb_user SET state = ? WHERE 1 = 1" {
+		t.Fatalf("query=%v, err=%v", query, err)
+	}
+	for _, where := range [][]Cond{nil, {nil}, {NotIn("id")}} {
+		if _, _, err = Update("tb_user").Set("state", 0).Where(where...).Build(QUESTION); err == nil {
+			t.Errorf("UPDATE without WHERE is not rejected, where=%v", where)
+		}
+		if _, _, err = Delete("tb_user").Where(where...).Build(QUESTION); err == nil {
+			t.Errorf("DELETE without WHERE is not rejected, where=%v", where)
+		}
+	}
+
+	if query, args, err = Delete("tb_user").Where(In("id", 1, 2)).Build(DOLLAR); err != nil || query != "DELETE FROM tb_user WHERE id IN ($1, $2)" || len(args) != 2 {
+		t.Fatalf("query=%v, args=%v, err=%v", query, args, err)
+	}
+}
+
+func TestBuildCond(t *testing.T) {
+	query, args, err := BuildCond(And(Eq("a", 1), IsNotNull("b"), nil), NAMED)
+	if err != nil || query != "(a = :p1 AND b IS NOT NULL)" || !reflect.DeepEqual(args, []interface{}{sql.Named("p1", 1)}) {
+		t.Fatalf("query=%v, args=%v, err=%v", query, args, err)
+	}
+}
@@ -0,0 +1,253 @@
+package zxsql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	QUESTION string = "?" // sqlite, mysql
+	DOLLAR   string = "$" // postgres: $1, $2, ...
+	NAMED    string = ":" // oracle: :p1, :p2, ... (参数是 sql.Named)
+)
+
+// 恒真/恒假的条件.
+const (
+	condTrue  string = "1 = 1"
+	condFalse string = "1 = 0"
+)
+
+// 收集参数并生成占位符.
+type argBuilder struct {
+	style   string
+	args    []interface{}
+	allRows bool  //使用了AllRows()
+	err     error //生成过程中的第一个错误
+}
+
+func (self *argBuilder) bind(value interface{}) string {
+	n := len(self.args) + 1
+	switch self.style {
+	case DOLLAR:
+		self.args = append(self.args, value)
+		return "$" + strconv.Itoa(n)
+	case NAMED:
+		name := "p" + strconv.Itoa(n)
+		self.args = append(self.args, sql.Named(name, value))
+		return ":" + name
+	default:
+		self.args = append(self.args, value)
+		return "?"
+	}
+}
+
+// 把raw里面(引号之外)的"?"依次替换成values对应的占位符, 个数不一致时记录错误.
+func (self *argBuilder) bindRaw(raw string, values []interface{}) string {
+	var result strings.Builder
+	var quote rune = 0
+	idx := 0
+	for _, c := range raw {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			result.WriteRune(c)
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			result.WriteRune(c)
+		case c == '?':
+			if idx < len(values) {
+				result.WriteString(self.bind(values[idx]))
+			}
+			idx++
+		default:
+			result.WriteRune(c)
+		}
+	}
+	if idx != len(values) && self.err == nil {
+		self.err = errors.New(fmt.Sprintf("placeholder count mismatch, expr=%v, placeholders=%v, values=%v", raw, idx, len(values)))
+	}
+	return result.String()
+}
+
+// 一个WHERE/HAVING/ON条件. 值一律作为参数传递,不会拼接进SQL文本.
+type Cond interface {
+	build(b *argBuilder) string
+}
+
+type compareCond struct {
+	col   string
+	op    string
+	value interface{}
+}
+
+// "col = NULL"永远不成立, 和nil比较时(只支持=和<>)生成 IS NULL / IS NOT NULL.
+func (self *compareCond) build(b *argBuilder) string {
+	if self.value == nil {
+		switch self.op {
+		case "=":
+			return self.col + " IS NULL"
+		case "<>":
+			return self.col + " IS NOT NULL"
+		}
+	}
+	return self.col + " " + self.op + " " + b.bind(self.value)
+}
+
+func Eq(col string, value interface{}) Cond { return &compareCond{col, "=", value} }
+func Ne(col string, value interface{}) Cond { return &compareCond{col, "<>", value} }
+func Gt(col string, value interface{}) Cond { return &compareCond{col, ">", value} }
+func Ge(col string, value interface{}) Cond { return &compareCond{col, ">=", value} }
+func Lt(col string, value interface{}) Cond { return &compareCond{col, "<", value} }
+func Le(col string, value interface{}) Cond { return &compareCond{col, "<=", value} }
+func Like(col string, value string) Cond    { return &compareCond{col, "LIKE", value} }
+func NotLike(col string, value string) Cond { return &compareCond{col, "NOT LIKE", value} }
+
+type inCond struct {
+	col    string
+	not    bool
+	values []interface{}
+}
+
+func (self *inCond) build(b *argBuilder) string {
+	if len(self.values) == 0 { //"IN ()"是语法错误.
+		if self.not {
+			return condTrue
+		}
+		return condFalse
+	}
+	holders := make([]string, 0, len(self.values))
+	for _, value := range self.values {
+		holders = append(holders, b.bind(value))
+	}
+	op := " IN ("
+	if self.not {
+		op = " NOT IN ("
+	}
+	return self.col + op + strings.Join(holders, ", ") + ")"
+}
+
+func In(col string, values ...interface{}) Cond    { return &inCond{col, false, values} }
+func NotIn(col string, values ...interface{}) Cond { return &inCond{col, true, values} }
+
+type betweenCond struct {
+	col      string
+	from, to interface{}
+}
+
+func (self *betweenCond) build(b *argBuilder) string {
+	return self.col + " BETWEEN " + b.bind(self.from) + " AND " + b.bind(self.to)
+}
+
+func Between(col string, from, to interface{}) Cond { return &betweenCond{col, from, to} }
+
+type nullCond struct {
+	col string
+	not bool
+}
+
+func (self *nullCond) build(b *argBuilder) string {
+	if self.not {
+		return self.col + " IS NOT NULL"
+	}
+	return self.col + " IS NULL"
+}
+
+func IsNull(col string) Cond    { return &nullCond{col, false} }
+func IsNotNull(col string) Cond { return &nullCond{col, true} }
+
+type groupCond struct {
+	op    string
+	conds []Cond
+}
+
+// 空的AND恒真, 空的OR恒假. 省略不影响结果的恒真(AND)/恒假(OR)的子条件,
+// OR里面有恒真的子条件时整个OR恒真(已经绑定的参数也一并丢弃).
+func (self *groupCond) build(b *argBuilder) string {
+	identity, absorb := condTrue, condFalse
+	if self.op == "OR" {
+		identity, absorb = condFalse, condTrue
+	}
+	argc := len(b.args)
+	parts := make([]string, 0, len(self.conds))
+	for _, cond := range self.conds {
+		if cond == nil {
+			continue
+		}
+		part := cond.build(b)
+		if part == identity {
+			continue
+		}
+		if part == absorb && self.op == "OR" {
+			b.args = b.args[:argc]
+			return absorb
+		}
+		parts = append(parts, part)
+	}
+	switch len(parts) {
+	case 0:
+		return identity
+	case 1:
+		return parts[0]
+	default:
+		return "(" + strings.Join(parts, " "+self.op+" ") + ")"
+	}
+}
+
+func And(conds ...Cond) Cond { return &groupCond{"AND", conds} }
+func Or(conds ...Cond) Cond  { return &groupCond{"OR", conds} }
+
+type notCond struct {
+	cond Cond
+}
+
+func (self *notCond) build(b *argBuilder) string {
+	if self.cond == nil {
+		return condFalse
+	}
+	switch part := self.cond.build(b); part {
+	case condTrue:
+		return condFalse
+	case condFalse:
+		return condTrue
+	default:
+		return "NOT (" + part + ")"
+	}
+}
+
+func Not(cond Cond) Cond { return &notCond{cond} }
+
+type exprCond struct {
+	raw    string
+	values []interface{}
+}
+
+func (self *exprCond) build(b *argBuilder) string {
+	return b.bindRaw(self.raw, self.values)
+}
+
+// 原样使用的SQL片段, 其中的"?"按顺序绑定values, 并按方言转换成对应的占位符.
+// "?"的个数和values的个数不一致时, Build返回错误.
+func Expr(raw string, values ...interface{}) Cond { return &exprCond{raw, values} }
+
+type allRowsCond struct{}
+
+func (self *allRowsCond) build(b *argBuilder) string {
+	b.allRows = true
+	return condTrue
+}
+
+// 明确表示全部的行. UPDATE/DELETE的WHERE恒真时返回错误, 确实要更新/删除全表请使用 Where(AllRows()).
+func AllRows() Cond { return &allRowsCond{} }
+
+// 单独生成一个条件的SQL和参数, 比如给 xorm 的 Where(query, args...) 使用.
+func BuildCond(cond Cond, style string) (query string, args []interface{}, err error) {
+	b := &argBuilder{style: style, args: make([]interface{}, 0)}
+	query = And(cond).build(b)
+	args, err = b.args, b.err
+	return
+}
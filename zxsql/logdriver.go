@@ -0,0 +1,520 @@
+package zxsql
+
+/*
+包装一个database/sql的驱动, 记录每条语句(含参数)的耗时和行数, 标记慢查询, 并提供追踪的钩子.
+使用例子:
+	db, err := zxsql.OpenLogged("sqlite3", "test.db", &zxsql.LogOptions{
+		Logger:        log.New(os.Stderr, "", log.LstdFlags),
+		SlowThreshold: 200 * time.Millisecond,
+		LogAll:        true,
+		Redact:        zxsql.RedactAll,
+	})
+	results, err := zxgo.QueryData(db, "SELECT * FROM tb_student")
+*/
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	KindQuery    string = "query"
+	KindExec     string = "exec"
+	KindBegin    string = "begin"
+	KindCommit   string = "commit"
+	KindRollback string = "rollback"
+)
+
+// *log.Logger 实现了这个接口.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type QueryEvent struct {
+	Kind     string
+	Query    string
+	Args     []interface{} //已经经过Redact处理
+	Start    time.Time
+	Duration time.Duration //query是从开始执行到Rows.Close的时间
+	Rows     int64         //query是读取的行数,exec是影响的行数,未知时为-1
+	Err      error         //为driver.ErrSkip时表示驱动改用Prepare重新执行(之后还有一个事件),只调用After,不输出日志
+	Slow     bool
+}
+
+func (self *QueryEvent) String() string {
+	content := fmt.Sprintf("%v %v rows=%v %v", self.Kind, self.Duration, self.Rows, compactQuery(self.Query))
+	if len(self.Args) != 0 {
+		content += fmt.Sprintf(" args=%v", self.Args)
+	}
+	if self.Err != nil {
+		content += fmt.Sprintf(" err=%v", self.Err)
+	}
+	return content
+}
+
+type LogOptions struct {
+	Logger        Logger        //为nil时不输出日志(只调用钩子)
+	SlowThreshold time.Duration //大于0时,耗时超过它的语句标记为慢查询
+	LogAll        bool          //为false时只输出慢查询和出错的语句
+	//返回参数在日志里的样子(比如把密码替换成"***"), 为nil时原样输出. idx从0开始.
+	Redact func(query string, idx int, value interface{}) interface{}
+	//执行语句之前调用, 返回的ctx会传给After(可以在这里开始一个追踪的span).
+	Before func(ctx context.Context, event *QueryEvent) context.Context
+	//语句结束之后调用(可以在这里结束span).
+	After func(ctx context.Context, event *QueryEvent)
+}
+
+// 把所有参数都替换成"?".
+func RedactAll(query string, idx int, value interface{}) interface{} {
+	return "?"
+}
+
+// 打开driverName对应的驱动, 并用日志驱动包装它.
+func OpenLogged(driverName, dataSourceName string, opts *LogOptions) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	db.Close()
+
+	var connector driver.Connector
+	if dc, ok := d.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dataSourceName); err != nil {
+			return nil, err
+		}
+	} else {
+		connector = &dsnConnector{dsn: dataSourceName, d: d}
+	}
+	return sql.OpenDB(NewLogConnector(connector, opts)), nil
+}
+
+type dsnConnector struct {
+	dsn string
+	d   driver.Driver
+}
+
+func (self *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return self.d.Open(self.dsn)
+}
+
+func (self *dsnConnector) Driver() driver.Driver {
+	return self.d
+}
+
+// 包装connector, 用 sql.OpenDB 打开它.
+func NewLogConnector(connector driver.Connector, opts *LogOptions) driver.Connector {
+	if opts == nil {
+		opts = &LogOptions{}
+	}
+	return &logConnector{connector: connector, opts: opts}
+}
+
+// 包装驱动, 可以用 sql.Register 注册成一个新的驱动名.
+func WrapDriver(d driver.Driver, opts *LogOptions) driver.Driver {
+	if opts == nil {
+		opts = &LogOptions{}
+	}
+	return &logDriver{d: d, opts: opts}
+}
+
+type logDriver struct {
+	d    driver.Driver
+	opts *LogOptions
+}
+
+func (self *logDriver) Open(name string) (driver.Conn, error) {
+	conn, err := self.d.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &logConn{conn: conn, opts: self.opts}, nil
+}
+
+type logConnector struct {
+	connector driver.Connector
+	opts      *LogOptions
+}
+
+func (self *logConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := self.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &logConn{conn: conn, opts: self.opts}, nil
+}
+
+func (self *logConnector) Driver() driver.Driver {
+	return &logDriver{d: self.connector.Driver(), opts: self.opts}
+}
+
+func (self *LogOptions) begin(ctx context.Context, kind, query string, args []driver.NamedValue) (context.Context, *QueryEvent) {
+	event := &QueryEvent{Kind: kind, Query: query, Start: time.Now(), Rows: -1}
+	if len(args) != 0 {
+		event.Args = make([]interface{}, 0, len(args))
+		for idx, arg := range args {
+			var value interface{} = arg.Value
+			if self.Redact != nil {
+				value = self.Redact(query, idx, value)
+			}
+			if len(arg.Name) != 0 {
+				value = fmt.Sprintf("%v=%v", arg.Name, value)
+			}
+			event.Args = append(event.Args, value)
+		}
+	}
+	if self.Before != nil {
+		if newCtx := self.Before(ctx, event); newCtx != nil {
+			ctx = newCtx
+		}
+	}
+	return ctx, event
+}
+
+func (self *LogOptions) end(ctx context.Context, event *QueryEvent, err error) {
+	event.Duration = time.Since(event.Start)
+	if err != nil && err != io.EOF {
+		event.Err = err
+	}
+	event.Slow = 0 < self.SlowThreshold && self.SlowThreshold < event.Duration
+
+	if self.Logger != nil {
+		switch {
+		case event.Err != nil:
+			self.Logger.Printf("[SQL ERROR] %v", event)
+		case event.Slow:
+			self.Logger.Printf("[SLOW SQL] %v", event)
+		case self.LogAll:
+			self.Logger.Printf("[SQL] %v", event)
+		}
+	}
+	if self.After != nil {
+		self.After(ctx, event)
+	}
+}
+
+func (self *LogOptions) skip(ctx context.Context, event *QueryEvent) {
+	event.Duration = time.Since(event.Start)
+	event.Err = driver.ErrSkip
+	if self.After != nil {
+		self.After(ctx, event)
+	}
+}
+
+type logConn struct {
+	conn driver.Conn
+	opts *LogOptions
+}
+
+func (self *logConn) Prepare(query string) (driver.Stmt, error) {
+	return self.PrepareContext(context.Background(), query)
+}
+
+func (self *logConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	if cp, ok := self.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = cp.PrepareContext(ctx, query)
+	} else {
+		stmt, err = self.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &logStmt{stmt: stmt, conn: self.conn, query: query, opts: self.opts}, nil
+}
+
+func (self *logConn) Close() error {
+	return self.conn.Close()
+}
+
+func (self *logConn) Begin() (driver.Tx, error) {
+	return self.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (self *logConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	ctx2, event := self.opts.begin(ctx, KindBegin, "BEGIN", nil)
+	if cb, ok := self.conn.(driver.ConnBeginTx); ok {
+		tx, err = cb.BeginTx(ctx, opts)
+	} else { //旧驱动只有Begin, 和database/sql一样, 不支持的选项返回错误
+		switch {
+		case opts.Isolation != driver.IsolationLevel(sql.LevelDefault):
+			err = errors.New("driver does not support non-default isolation level")
+		case opts.ReadOnly:
+			err = errors.New("driver does not support read-only transactions")
+		default:
+			tx, err = self.conn.Begin()
+		}
+	}
+	self.opts.end(ctx2, event, err)
+	if err != nil {
+		return nil, err
+	}
+	return &logTx{tx: tx, ctx: ctx, opts: self.opts}, nil
+}
+
+func (self *logConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	execer, ok := self.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip //database/sql会改用Prepare,在logStmt里面记录.
+	}
+	ctx2, event := self.opts.begin(ctx, KindExec, query, args)
+	if result, err = execer.ExecContext(ctx, query, args); err == driver.ErrSkip {
+		self.opts.skip(ctx2, event)
+		return
+	}
+	if err == nil {
+		if affected, err2 := result.RowsAffected(); err2 == nil {
+			event.Rows = affected
+		}
+	}
+	self.opts.end(ctx2, event, err)
+	return
+}
+
+func (self *logConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	queryer, ok := self.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx2, event := self.opts.begin(ctx, KindQuery, query, args)
+	if rows, err = queryer.QueryContext(ctx, query, args); err == driver.ErrSkip {
+		self.opts.skip(ctx2, event)
+		return
+	}
+	if err != nil {
+		self.opts.end(ctx2, event, err)
+		return
+	}
+	return &logRows{rows: rows, ctx: ctx2, event: event, opts: self.opts}, nil
+}
+
+func (self *logConn) Ping(ctx context.Context) error {
+	if pinger, ok := self.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (self *logConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := self.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (self *logConn) IsValid() bool {
+	if validator, ok := self.conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (self *logConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := self.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip //使用默认的转换规则.
+}
+
+type logTx struct {
+	tx   driver.Tx
+	ctx  context.Context
+	opts *LogOptions
+}
+
+func (self *logTx) Commit() (err error) {
+	ctx, event := self.opts.begin(self.ctx, KindCommit, "COMMIT", nil)
+	err = self.tx.Commit()
+	self.opts.end(ctx, event, err)
+	return
+}
+
+func (self *logTx) Rollback() (err error) {
+	ctx, event := self.opts.begin(self.ctx, KindRollback, "ROLLBACK", nil)
+	err = self.tx.Rollback()
+	self.opts.end(ctx, event, err)
+	return
+}
+
+type logStmt struct {
+	stmt  driver.Stmt
+	conn  driver.Conn
+	query string
+	opts  *LogOptions
+}
+
+func (self *logStmt) Close() error {
+	return self.stmt.Close()
+}
+
+func (self *logStmt) NumInput() int {
+	return self.stmt.NumInput()
+}
+
+func (self *logStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return self.ExecContext(context.Background(), valuesToNamed(args))
+}
+
+func (self *logStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return self.QueryContext(context.Background(), valuesToNamed(args))
+}
+
+func (self *logStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (result driver.Result, err error) {
+	ctx2, event := self.opts.begin(ctx, KindExec, self.query, args)
+	if execer, ok := self.stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		result, err = self.stmt.Exec(namedToValues(args)) //旧驱动只有Exec
+	}
+	if err == nil {
+		if affected, err2 := result.RowsAffected(); err2 == nil {
+			event.Rows = affected
+		}
+	}
+	self.opts.end(ctx2, event, err)
+	return
+}
+
+func (self *logStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	ctx2, event := self.opts.begin(ctx, KindQuery, self.query, args)
+	if queryer, ok := self.stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		rows, err = self.stmt.Query(namedToValues(args)) //旧驱动只有Query
+	}
+	if err != nil {
+		self.opts.end(ctx2, event, err)
+		return
+	}
+	return &logRows{rows: rows, ctx: ctx2, event: event, opts: self.opts}, nil
+}
+
+func (self *logStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := self.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if checker, ok := self.conn.(driver.NamedValueChecker); ok { //stmt实现了这个接口时,database/sql不会再问conn.
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func (self *logStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if converter, ok := self.stmt.(driver.ColumnConverter); ok { //兼容旧驱动
+		return converter.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+// 在Close的时候记录日志, 此时才知道读取了多少行.
+type logRows struct {
+	rows   driver.Rows
+	ctx    context.Context
+	event  *QueryEvent
+	opts   *LogOptions
+	count  int64
+	err    error
+	closed bool
+}
+
+func (self *logRows) Columns() []string {
+	return self.rows.Columns()
+}
+
+func (self *logRows) Next(dest []driver.Value) error {
+	err := self.rows.Next(dest)
+	if err == nil {
+		self.count++
+	} else if err != io.EOF {
+		self.err = err
+	}
+	return err
+}
+
+func (self *logRows) Close() error {
+	err := self.rows.Close()
+	if !self.closed {
+		self.closed = true
+		self.event.Rows = self.count
+		if self.err == nil {
+			self.err = err
+		}
+		self.opts.end(self.ctx, self.event, self.err)
+	}
+	return err
+}
+
+func (self *logRows) HasNextResultSet() bool {
+	if rs, ok := self.rows.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+func (self *logRows) NextResultSet() error {
+	if rs, ok := self.rows.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (self *logRows) ColumnTypeDatabaseTypeName(index int) string {
+	if ct, ok := self.rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return ct.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (self *logRows) ColumnTypeScanType(index int) reflect.Type {
+	if ct, ok := self.rows.(driver.RowsColumnTypeScanType); ok {
+		return ct.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (self *logRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if ct, ok2 := self.rows.(driver.RowsColumnTypeNullable); ok2 {
+		return ct.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (self *logRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if ct, ok2 := self.rows.(driver.RowsColumnTypeLength); ok2 {
+		return ct.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (self *logRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if ct, ok2 := self.rows.(driver.RowsColumnTypePrecisionScale); ok2 {
+		return ct.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func valuesToNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for idx, arg := range args {
+		named[idx] = driver.NamedValue{Ordinal: idx + 1, Value: arg}
+	}
+	return named
+}
+
+func namedToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for idx, arg := range args {
+		values[idx] = arg.Value
+	}
+	return values
+}
+
+// 让日志里的SQL保持一行.
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -0,0 +1,81 @@
+package zxsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// 只实现了必需方法的旧驱动: 没有BeginTx, ExecContext等.
+type stubDriver struct {
+	begins int
+}
+
+func (self *stubDriver) Open(name string) (driver.Conn, error) {
+	return &stubConn{d: self}, nil
+}
+
+type stubConn struct {
+	d *stubDriver
+}
+
+func (self *stubConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (self *stubConn) Close() error {
+	return nil
+}
+
+func (self *stubConn) Begin() (driver.Tx, error) {
+	self.d.begins++
+	return &stubTx{}, nil
+}
+
+type stubTx struct{}
+
+func (self *stubTx) Commit() error   { return nil }
+func (self *stubTx) Rollback() error { return nil }
+
+func TestLogConnBeginTxLegacyDriver(t *testing.T) {
+	stub := &stubDriver{}
+	events := make([]*QueryEvent, 0)
+	opts := &LogOptions{After: func(ctx context.Context, event *QueryEvent) {
+		events = append(events, event)
+	}}
+	db := sql.OpenDB(&dsnConnector{d: WrapDriver(stub, opts)})
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}); err == nil || !strings.Contains(err.Error(), "isolation level") {
+		t.Fatalf("isolation level, err=%v", err)
+	}
+	if _, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true}); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Fatalf("read-only, err=%v", err)
+	}
+	if stub.begins != 0 {
+		t.Fatalf("Begin was called %v times", stub.begins)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if stub.begins != 1 {
+		t.Fatalf("Begin was called %v times", stub.begins)
+	}
+
+	kinds := make([]string, 0, len(events))
+	for _, event := range events {
+		kinds = append(kinds, event.Kind)
+	}
+	if strings.Join(kinds, ",") != "begin,begin,begin,commit" || events[0].Err == nil || events[2].Err != nil {
+		t.Fatalf("events=%v", events)
+	}
+}
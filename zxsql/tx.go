@@ -0,0 +1,206 @@
+package zxsql
+
+/*
+使用例子:
+	err := zxsql.WithTx(ctx, db, nil, func(tx *zxsql.Tx) error {
+		if _, err := tx.Exec("UPDATE ..."); err != nil {
+			return err
+		}
+		//嵌套事务(SAVEPOINT): 内层失败只回滚内层.
+		return zxsql.WithTx(tx.Context(), db, nil, func(tx *zxsql.Tx) error { ... })
+	})
+*/
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TxOptions struct {
+	Isolation      sql.IsolationLevel
+	ReadOnly       bool
+	MaxRetries     int              //出现暂时性错误时的最大重试次数, 0表示不重试.
+	InitialBackoff time.Duration    //第一次重试前的等待时间, 之后每次翻倍.
+	MaxBackoff     time.Duration    //等待时间的上限.
+	IsRetryable    func(error) bool //为nil时使用IsTransientError.
+}
+
+// opts为nil时使用的默认值.
+var DefaultTxOptions = TxOptions{MaxRetries: 5, InitialBackoff: 10 * time.Millisecond, MaxBackoff: time.Second}
+
+// 这些错误信息表示"稍后重试可能成功"(锁冲突,死锁,序列化失败).
+var TransientErrorMessages = []string{
+	"database is locked",         //sqlite SQLITE_BUSY
+	"database table is locked",   //sqlite SQLITE_LOCKED
+	"SQLITE_BUSY",                //sqlite
+	"Error 1213",                 //mysql ER_LOCK_DEADLOCK
+	"Error 1205",                 //mysql ER_LOCK_WAIT_TIMEOUT
+	"Deadlock found",             //mysql
+	"deadlock detected",          //postgres 40P01
+	"could not serialize access", //postgres 40001
+	"could not obtain lock",      //postgres 55P03
+}
+
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	message := err.Error()
+	for _, item := range TransientErrorMessages {
+		if strings.Contains(message, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// 事务里的函数发生panic时,返回这个错误(而不是继续panic).
+type PanicError struct {
+	Value interface{}
+	Stack string
+}
+
+func (self *PanicError) Error() string {
+	return fmt.Sprintf("panic in transaction: %v", self.Value)
+}
+
+type Tx struct {
+	*sql.Tx
+	ctx   context.Context
+	depth int //0是最外层事务, 大于0时是SAVEPOINT.
+}
+
+type txContextKey struct{}
+
+// 返回携带了本事务的ctx, 用它调用WithTx会创建嵌套事务.
+func (self *Tx) Context() context.Context {
+	return self.ctx
+}
+
+func (self *Tx) Depth() int {
+	return self.depth
+}
+
+func TxFromContext(ctx context.Context) *Tx {
+	if tx, ok := ctx.Value(txContextKey{}).(*Tx); ok {
+		return tx
+	}
+	return nil
+}
+
+// 在事务中执行fn: fn返回nil则提交, 返回错误或panic则回滚.
+// 如果ctx里已经有事务(见Tx.Context), 就用SAVEPOINT执行嵌套事务, 此时不重试(由最外层决定是否重试).
+func WithTx(ctx context.Context, db *sql.DB, opts *TxOptions, fn func(tx *Tx) error) error {
+	if opts == nil {
+		opts = &DefaultTxOptions
+	}
+
+	if parent := TxFromContext(ctx); parent != nil {
+		return withSavepoint(parent, fn)
+	}
+
+	return Retry(ctx, opts, func() error {
+		return withTxOnce(ctx, db, opts, fn)
+	})
+}
+
+func withTxOnce(ctx context.Context, db *sql.DB, opts *TxOptions, fn func(tx *Tx) error) (err error) {
+	var sqlTx *sql.Tx
+	if sqlTx, err = db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}); err != nil {
+		return
+	}
+
+	tx := &Tx{Tx: sqlTx, depth: 0}
+	tx.ctx = context.WithValue(ctx, txContextKey{}, tx)
+
+	if err = callTxFunc(tx, fn); err != nil {
+		if err2 := sqlTx.Rollback(); err2 != nil && !errors.Is(err2, sql.ErrTxDone) {
+			err = fmt.Errorf("%w (rollback fail: %v)", err, err2)
+		}
+		return
+	}
+
+	err = sqlTx.Commit()
+	return
+}
+
+func withSavepoint(parent *Tx, fn func(tx *Tx) error) (err error) {
+	name := "zx_sp_" + strconv.Itoa(parent.depth+1)
+	if _, err = parent.ExecContext(parent.ctx, "SAVEPOINT "+name); err != nil {
+		return
+	}
+
+	tx := &Tx{Tx: parent.Tx, depth: parent.depth + 1}
+	tx.ctx = context.WithValue(parent.ctx, txContextKey{}, tx)
+
+	if err = callTxFunc(tx, fn); err != nil {
+		if _, err2 := parent.ExecContext(parent.ctx, "ROLLBACK TO SAVEPOINT "+name); err2 != nil {
+			err = fmt.Errorf("%w (rollback to savepoint fail: %v)", err, err2)
+		}
+		return
+	}
+
+	_, err = parent.ExecContext(parent.ctx, "RELEASE SAVEPOINT "+name)
+	return
+}
+
+func callTxFunc(tx *Tx, fn func(tx *Tx) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: string(debug.Stack())}
+		}
+	}()
+	err = fn(tx)
+	return
+}
+
+// 执行fn, 遇到可重试的错误时按指数退避重试. ctx被取消时立即返回.
+// zxxorm等不使用*sql.DB的地方也可以用它实现同样的重试策略.
+func Retry(ctx context.Context, opts *TxOptions, fn func() error) (err error) {
+	if opts == nil {
+		opts = &DefaultTxOptions
+	}
+	isRetryable := opts.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsTransientError
+	}
+
+	backoff := opts.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		var panicErr *PanicError
+		if errors.As(err, &panicErr) { //fn可能包装了PanicError
+			return
+		}
+		if err == nil || opts.MaxRetries <= attempt || !isRetryable(err) {
+			return
+		}
+
+		wait := backoff
+		if 0 < wait {
+			wait += time.Duration(rand.Int63n(int64(wait)/2 + 1)) //加一点随机,避免多个写者同时重试.
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = fmt.Errorf("%w (retry aborted: %v)", err, ctx.Err())
+			return
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if 0 < opts.MaxBackoff && opts.MaxBackoff < backoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
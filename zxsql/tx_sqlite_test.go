@@ -0,0 +1,166 @@
+//go:build cgo
+
+package zxsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openSqlite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err = db.Exec("CREATE TABLE tb_user (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func userIds(t *testing.T, db *sql.DB) string {
+	rows, err := db.Query("SELECT id FROM tb_user ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	ids := ""
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids += fmt.Sprintf("%v,", id)
+	}
+	return ids
+}
+
+func insertUser(tx *Tx, id int64) error {
+	_, err := tx.ExecContext(tx.Context(), "INSERT INTO tb_user (id, name) VALUES (?, ?)", id, "u")
+	return err
+}
+
+func TestWithTx(t *testing.T) {
+	db := openSqlite(t)
+	ctx := context.Background()
+
+	err := WithTx(ctx, db, nil, func(tx *Tx) error {
+		if tx.Depth() != 0 || TxFromContext(tx.Context()) != tx {
+			return errors.New("illegal outer tx")
+		}
+		if err := insertUser(tx, 1); err != nil {
+			return err
+		}
+		//内层失败: 只回滚到SAVEPOINT
+		innerErr := WithTx(tx.Context(), db, nil, func(inner *Tx) error {
+			if inner.Depth() != 1 {
+				return errors.New("illegal inner depth")
+			}
+			if err := insertUser(inner, 2); err != nil {
+				return err
+			}
+			//更深一层
+			return WithTx(inner.Context(), db, nil, func(deeper *Tx) error {
+				if deeper.Depth() != 2 {
+					return errors.New("illegal deeper depth")
+				}
+				if err := insertUser(deeper, 3); err != nil {
+					return err
+				}
+				return errors.New("deeper fail")
+			})
+		})
+		if innerErr == nil || innerErr.Error() != "deeper fail" {
+			return fmt.Errorf("innerErr=%v", innerErr)
+		}
+		return WithTx(tx.Context(), db, nil, func(inner *Tx) error {
+			return insertUser(inner, 4)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(t, db); ids != "1,4," {
+		t.Fatalf("ids=%v", ids)
+	}
+
+	//外层失败: 已经RELEASE的内层也一起回滚
+	err = WithTx(ctx, db, nil, func(tx *Tx) error {
+		if err := WithTx(tx.Context(), db, nil, func(inner *Tx) error { return insertUser(inner, 5) }); err != nil {
+			return err
+		}
+		return errors.New("outer fail")
+	})
+	if err == nil || err.Error() != "outer fail" {
+		t.Fatalf("err=%v", err)
+	}
+
+	//panic: 回滚并返回PanicError
+	err = WithTx(ctx, db, nil, func(tx *Tx) error {
+		if err := insertUser(tx, 6); err != nil {
+			return err
+		}
+		panic("boom")
+	})
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Fatalf("err=%v", err)
+	}
+
+	//fn自己Commit之后返回错误: 回滚时的ErrTxDone不算错误
+	err = WithTx(ctx, db, nil, func(tx *Tx) error {
+		if err := insertUser(tx, 7); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return errors.New("after commit")
+	})
+	if err == nil || err.Error() != "after commit" {
+		t.Fatalf("err=%v", err)
+	}
+	if ids := userIds(t, db); ids != "1,4,7," {
+		t.Fatalf("ids=%v", ids)
+	}
+}
+
+func TestWithTxRetry(t *testing.T) {
+	db := openSqlite(t)
+	attempts := 0
+	err := WithTx(context.Background(), db, &TxOptions{MaxRetries: 3}, func(tx *Tx) error {
+		attempts++
+		if err := insertUser(tx, 1); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return errors.New("database is locked") //回滚之后重试, 所以不会主键冲突
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("attempts=%v, err=%v", attempts, err)
+	}
+
+	//嵌套事务不重试, 由最外层决定
+	inner := 0
+	err = WithTx(context.Background(), db, &TxOptions{MaxRetries: 0}, func(tx *Tx) error {
+		return WithTx(tx.Context(), db, &TxOptions{MaxRetries: 3}, func(tx *Tx) error {
+			inner++
+			return errors.New("database is locked")
+		})
+	})
+	if err == nil || inner != 1 {
+		t.Fatalf("inner=%v, err=%v", inner, err)
+	}
+	if ids := userIds(t, db); ids != "1," {
+		t.Fatalf("ids=%v", ids)
+	}
+}
@@ -0,0 +1,96 @@
+package zxsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsTransientError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("database is locked"), true},
+		{fmt.Errorf("exec: %w", errors.New("Error 1213: Deadlock found when trying to get lock")), true},
+		{errors.New("pq: could not serialize access due to concurrent update"), true},
+		{errors.New("no such table: tb_user"), false},
+		{fmt.Errorf("database is locked: %w", context.Canceled), false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, c := range cases {
+		if got := IsTransientError(c.err); got != c.want {
+			t.Errorf("err=%v, got %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	locked := errors.New("database is locked")
+	opts := &TxOptions{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	attempts := 0
+	err := Retry(ctx, opts, func() error {
+		attempts++
+		if attempts < 3 {
+			return locked
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("attempts=%v, err=%v", attempts, err)
+	}
+
+	//超过MaxRetries: 一共执行MaxRetries+1次, 返回最后的错误
+	attempts = 0
+	err = Retry(ctx, opts, func() error {
+		attempts++
+		return locked
+	})
+	if err != locked || attempts != 4 {
+		t.Fatalf("attempts=%v, err=%v", attempts, err)
+	}
+
+	//不可重试的错误和(包装过的)PanicError不重试
+	for _, failure := range []error{errors.New("syntax error"), fmt.Errorf("wrapped: %w", &PanicError{Value: "database is locked"})} {
+		attempts = 0
+		err = Retry(ctx, opts, func() error {
+			attempts++
+			return failure
+		})
+		if err != failure || attempts != 1 {
+			t.Errorf("failure=%v, attempts=%v, err=%v", failure, attempts, err)
+		}
+	}
+
+	//自定义IsRetryable
+	attempts = 0
+	custom := &TxOptions{MaxRetries: 1, IsRetryable: func(err error) bool { return err.Error() == "again" }}
+	err = Retry(ctx, custom, func() error {
+		attempts++
+		return errors.New("again")
+	})
+	if err == nil || attempts != 2 {
+		t.Fatalf("attempts=%v, err=%v", attempts, err)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := &TxOptions{MaxRetries: 10, InitialBackoff: time.Hour}
+	attempts := 0
+	err := Retry(ctx, opts, func() error {
+		attempts++
+		cancel()
+		return errors.New("database is locked")
+	})
+	//等待时ctx被取消: 立即返回, 错误里同时有原来的错误和取消的原因
+	if err == nil || attempts != 1 || !strings.Contains(err.Error(), "database is locked") || !strings.Contains(err.Error(), "retry aborted") {
+		t.Fatalf("attempts=%v, err=%v", attempts, err)
+	}
+}
@@ -0,0 +1,416 @@
+package zxvalid
+
+/*
+根据tag检查结构体的字段, 一般在 zxgo.BindByMap 之后调用.
+	type Config struct {
+		Port  int      `validate:"required,min=1,max=65535"`
+		Mode  string   `validate:"oneof=NAME RELNAME ABSNAME"`
+		Name  string   `validate:"omitempty,regexp=^[a-z_]+$"`
+		Mail  string   `validate:"email"`
+		Home  string   `validate:"dir"`
+		Tags  []string `validate:"min=1,max=10"`
+		Items []Item   //元素是结构体时会逐个检查
+	}
+	err := zxvalid.Validate(cfg)
+内置规则:
+	required   不能是零值(nil指针,空字符串,空slice等)
+	omitempty  值为零值时跳过后面的规则
+	min/max    数值比较大小; string,slice,map比较长度; time.Duration的参数可以写成"1s"
+	len        长度(或数值)必须相等
+	oneof      值必须是参数之一(参数用空格分隔)
+	regexp     字符串必须匹配(必须是最后一个规则, tag剩下的部分都是它的参数, 可以包含",")
+	email      字符串是邮件地址
+	dir/file   字符串是已经存在的目录/文件
+nil指针除了required之外不检查其它规则. 匿名结构体(包括未导出的)的字段就像是外层的字段, 和 zxgo.BindByMap 一致.
+同一个指针(或者map)指向的对象只检查一次, 所以循环引用不会导致死循环. 可以用Register注册自己的规则.
+*/
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+const DefaultTagName string = "validate"
+
+// 检查value(已经解引用)是否符合规则, 不符合时返回错误的说明.
+type Func func(value reflect.Value, param string) error
+
+type FieldError struct {
+	Path  string //比如"Db.Port", "Items[2].Name", "Labels[k1]"
+	Rule  string
+	Param string
+	Err   error
+}
+
+func (self *FieldError) Error() string {
+	if len(self.Param) == 0 {
+		return fmt.Sprintf("%v: %v: %v", self.Path, self.Rule, self.Err)
+	}
+	return fmt.Sprintf("%v: %v=%v: %v", self.Path, self.Rule, self.Param, self.Err)
+}
+
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (self *ValidationError) Error() string {
+	messages := make([]string, 0, len(self.Errors))
+	for _, fieldErr := range self.Errors {
+		messages = append(messages, fieldErr.Error())
+	}
+	return fmt.Sprintf("validate fail (%v fields): %v", len(self.Errors), strings.Join(messages, "; "))
+}
+
+type Validator struct {
+	TagName string
+	funcs   map[string]Func
+	mutex   sync.RWMutex
+}
+
+func New() *Validator {
+	validator := &Validator{TagName: DefaultTagName, funcs: make(map[string]Func)}
+	validator.funcs["min"] = checkMin
+	validator.funcs["max"] = checkMax
+	validator.funcs["len"] = checkLen
+	validator.funcs["oneof"] = checkOneOf
+	validator.funcs["regexp"] = checkRegexp
+	validator.funcs["email"] = checkEmail
+	validator.funcs["dir"] = checkDir
+	validator.funcs["file"] = checkFile
+	return validator
+}
+
+var defaultValidator = New()
+
+// 注册(或者覆盖)一个规则. required和omitempty不能覆盖.
+func (self *Validator) Register(name string, fn Func) {
+	self.mutex.Lock()
+	self.funcs[name] = fn
+	self.mutex.Unlock()
+}
+
+func Register(name string, fn Func) {
+	defaultValidator.Register(name, fn)
+}
+
+func Validate(data interface{}) error {
+	return defaultValidator.Validate(data)
+}
+
+// 检查data(结构体或者结构体指针)的所有字段, 返回 *ValidationError.
+func (self *Validator) Validate(data interface{}) error {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return errors.New("data is nil")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf("data must be a struct, type=%T", data))
+	}
+
+	allErrors := make([]*FieldError, 0)
+	self.walk(reflect.ValueOf(data), "", &allErrors, make(map[visitKey]bool)) //data本身也要记录到visited里面
+	if len(allErrors) != 0 {
+		return &ValidationError{allErrors}
+	}
+	return nil
+}
+
+type visitKey struct {
+	ptr uintptr
+	t   reflect.Type
+}
+
+// 未导出的匿名结构体(或者结构体指针), 它的导出字段被提升到外层.
+func isPromoted(structField reflect.StructField) bool {
+	t := structField.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return structField.Anonymous && t.Kind() == reflect.Struct
+}
+
+// 进入结构体,slice,array,map的内部检查其中的结构体. visited记录已经检查过的指针和map.
+func (self *Validator) walk(v reflect.Value, path string, allErrors *[]*FieldError, visited map[visitKey]bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			key := visitKey{v.Pointer(), v.Type()}
+			if visited[key] {
+				return
+			}
+			visited[key] = true
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if !v.CanAddr() { //读取未导出的匿名结构体的字段时, 需要它可以取地址
+			addressable := reflect.New(v.Type()).Elem()
+			addressable.Set(v)
+			v = addressable
+		}
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			structField := t.Field(i)
+			field := v.Field(i)
+			if !structField.IsExported() {
+				if !isPromoted(structField) {
+					continue
+				}
+				field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem() //只读取, 不修改
+			}
+			fieldPath := structField.Name
+			if structField.Anonymous {
+				fieldPath = path //匿名结构体的字段就像是外层的字段
+			} else if len(path) != 0 {
+				fieldPath = path + "." + structField.Name
+			}
+			self.checkField(field, fieldPath, structField.Tag.Get(self.TagName), allErrors)
+			self.walk(field, fieldPath, allErrors, visited)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			self.walk(v.Index(i), fmt.Sprintf("%v[%v]", path, i), allErrors, visited)
+		}
+	case reflect.Map:
+		key := visitKey{v.Pointer(), v.Type()}
+		if visited[key] {
+			return
+		}
+		visited[key] = true
+		iter := v.MapRange()
+		for iter.Next() {
+			self.walk(iter.Value(), fmt.Sprintf("%v[%v]", path, iter.Key().Interface()), allErrors, visited)
+		}
+	}
+}
+
+// 按","分隔规则, regexp的参数是tag剩下的全部内容.
+func splitRules(tag string) []string {
+	rules := make([]string, 0)
+	for len(tag) != 0 {
+		if strings.HasPrefix(strings.TrimSpace(tag), "regexp=") {
+			return append(rules, tag)
+		}
+		idx := strings.Index(tag, ",")
+		if idx < 0 {
+			return append(rules, tag)
+		}
+		rules = append(rules, tag[:idx])
+		tag = tag[idx+1:]
+	}
+	return rules
+}
+
+func (self *Validator) checkField(field reflect.Value, path, tag string, allErrors *[]*FieldError) {
+	if len(tag) == 0 || tag == "-" {
+		return
+	}
+
+	value := field
+	isNil := false
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			isNil = true
+			break
+		}
+		value = value.Elem()
+	}
+
+	for _, rule := range splitRules(tag) {
+		rule = strings.TrimSpace(rule)
+		if len(rule) == 0 {
+			continue
+		}
+		name, param := rule, ""
+		if idx := strings.Index(rule, "="); 0 <= idx {
+			name, param = rule[:idx], rule[idx+1:]
+		}
+
+		switch name {
+		case "required":
+			if isNil || value.IsZero() {
+				*allErrors = append(*allErrors, &FieldError{Path: path, Rule: name, Err: errors.New("is required")})
+				return
+			}
+			continue
+		case "omitempty":
+			if isNil || value.IsZero() {
+				return
+			}
+			continue
+		}
+		if isNil {
+			return
+		}
+
+		self.mutex.RLock()
+		fn, ok := self.funcs[name]
+		self.mutex.RUnlock()
+		if !ok {
+			*allErrors = append(*allErrors, &FieldError{Path: path, Rule: name, Param: param, Err: errors.New("unknown rule")})
+			continue
+		}
+		if err := fn(value, param); err != nil {
+			*allErrors = append(*allErrors, &FieldError{Path: path, Rule: name, Param: param, Err: err})
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// 返回用于比较的数值: 数值类型是它自己, string/slice/map是长度.
+func measure(value reflect.Value, param string) (actual float64, limit float64, err error) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+		if value.Type() == durationType {
+			var d time.Duration
+			if d, err = time.ParseDuration(param); err == nil {
+				limit = float64(d)
+				return
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		actual = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+	case reflect.String:
+		actual = float64(len([]rune(value.String())))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual = float64(value.Len())
+	default:
+		err = errors.New(fmt.Sprintf("unsupported type=%v", value.Type()))
+		return
+	}
+	if limit, err = strconv.ParseFloat(param, 64); err != nil {
+		err = errors.New(fmt.Sprintf("illegal param=%v", param))
+	}
+	return
+}
+
+// 错误信息里的实际值: 长度或者值本身.
+func describe(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("len(%v)=%v", value.String(), len([]rune(value.String())))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fmt.Sprintf("len=%v", value.Len())
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+func checkMin(value reflect.Value, param string) error {
+	actual, limit, err := measure(value, param)
+	if err != nil {
+		return err
+	}
+	if actual < limit {
+		return errors.New(fmt.Sprintf("must be at least %v, actual=%v", param, describe(value)))
+	}
+	return nil
+}
+
+func checkMax(value reflect.Value, param string) error {
+	actual, limit, err := measure(value, param)
+	if err != nil {
+		return err
+	}
+	if limit < actual {
+		return errors.New(fmt.Sprintf("must be at most %v, actual=%v", param, describe(value)))
+	}
+	return nil
+}
+
+func checkLen(value reflect.Value, param string) error {
+	actual, limit, err := measure(value, param)
+	if err != nil {
+		return err
+	}
+	if actual != limit {
+		return errors.New(fmt.Sprintf("must be equal to %v, actual=%v", param, describe(value)))
+	}
+	return nil
+}
+
+func checkOneOf(value reflect.Value, param string) error {
+	actual := fmt.Sprint(value.Interface())
+	for _, item := range strings.Fields(param) {
+		if item == actual {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("must be one of [%v], actual=%v", param, actual))
+}
+
+var regexpCache sync.Map //map[string]*regexp.Regexp
+
+func checkRegexp(value reflect.Value, param string) error {
+	if value.Kind() != reflect.String {
+		return errors.New(fmt.Sprintf("unsupported type=%v", value.Type()))
+	}
+	var pattern *regexp.Regexp
+	if cached, ok := regexpCache.Load(param); ok {
+		pattern = cached.(*regexp.Regexp)
+	} else {
+		var err error
+		if pattern, err = regexp.Compile(param); err != nil {
+			return err
+		}
+		regexpCache.Store(param, pattern)
+	}
+	if !pattern.MatchString(value.String()) {
+		return errors.New(fmt.Sprintf("does not match, actual=%v", value.String()))
+	}
+	return nil
+}
+
+func checkEmail(value reflect.Value, param string) error {
+	if value.Kind() != reflect.String {
+		return errors.New(fmt.Sprintf("unsupported type=%v", value.Type()))
+	}
+	address, err := mail.ParseAddress(value.String())
+	if err != nil || address.Address != value.String() { //不接受"Name <a@b.c>"这种形式
+		return errors.New(fmt.Sprintf("is not an email address, actual=%v", value.String()))
+	}
+	return nil
+}
+
+func checkPath(value reflect.Value, wantDir bool) error {
+	if value.Kind() != reflect.String {
+		return errors.New(fmt.Sprintf("unsupported type=%v", value.Type()))
+	}
+	info, err := os.Stat(value.String())
+	if err != nil {
+		return err
+	}
+	if wantDir && !info.IsDir() {
+		return errors.New(fmt.Sprintf("is not a directory, actual=%v", value.String()))
+	}
+	if !wantDir && !info.Mode().IsRegular() {
+		return errors.New(fmt.Sprintf("is not a regular file, actual=%v", value.String()))
+	}
+	return nil
+}
+
+func checkDir(value reflect.Value, param string) error {
+	return checkPath(value, true)
+}
+
+func checkFile(value reflect.Value, param string) error {
+	return checkPath(value, false)
+}
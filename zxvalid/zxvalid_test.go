@@ -0,0 +1,248 @@
+package zxvalid
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 返回"Path:Rule"的列表, 没有错误时返回"".
+func errorsString(t *testing.T, err error) string {
+	if err == nil {
+		return ""
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("err is not *ValidationError, err=%v", err)
+	}
+	items := make([]string, 0, len(validationErr.Errors))
+	for _, fieldErr := range validationErr.Errors {
+		items = append(items, fieldErr.Path+":"+fieldErr.Rule)
+	}
+	return strings.Join(items, ",")
+}
+
+func TestRules(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(filename, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	zero, one := 0, 1
+
+	cases := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{"required", struct {
+			A int    `validate:"required"`
+			B string `validate:"required"`
+			C *int   `validate:"required"`
+			D []int  `validate:"required"`
+			E *int   `validate:"required"`
+		}{E: &one}, "A:required,B:required,C:required,D:required"},
+		{"omitempty", struct {
+			A string `validate:"omitempty,min=3"`
+			B string `validate:"omitempty,min=3"`
+			C *int   `validate:"omitempty,min=1"`
+		}{B: "ab"}, "B:min"},
+		{"nil pointer skips rules", struct {
+			A *int `validate:"min=1"`
+		}{}, ""},
+		{"pointer is dereferenced", struct {
+			A *int `validate:"min=1"`
+			B *int `validate:"min=1"`
+		}{A: &zero, B: &one}, "A:min"},
+		{"min max number", struct {
+			A int           `validate:"min=1,max=10"`
+			B uint8         `validate:"min=1,max=10"`
+			C float64       `validate:"min=0.5,max=1.5"`
+			D int           `validate:"min=1,max=10"`
+			E time.Duration `validate:"min=1s,max=1m"`
+			F time.Duration `validate:"min=1s,max=1m"`
+			G time.Duration `validate:"max=1000"`
+		}{A: 0, B: 11, C: 1.5, D: 10, E: time.Second, F: time.Hour, G: time.Microsecond}, "A:min,B:max,F:max"},
+		{"min max length", struct {
+			A string         `validate:"min=2,max=3"`
+			B string         `validate:"min=2,max=3"`
+			C []int          `validate:"min=1"`
+			D map[string]int `validate:"max=1"`
+			E [2]int         `validate:"max=1"`
+		}{A: "中文", B: "abcd", C: []int{}, D: map[string]int{"a": 1}}, "B:max,C:min,E:max"},
+		{"len", struct {
+			A string `validate:"len=2"`
+			B []int  `validate:"len=2"`
+			C int    `validate:"len=2"`
+		}{A: "ab", B: []int{1}, C: 2}, "B:len"},
+		{"oneof", struct {
+			A string `validate:"oneof=NAME RELNAME ABSNAME"`
+			B string `validate:"oneof=NAME RELNAME ABSNAME"`
+			C int    `validate:"oneof=1 2"`
+		}{A: "RELNAME", B: "NAM", C: 3}, "B:oneof,C:oneof"},
+		{"regexp", struct {
+			A string `validate:"regexp=^[a-z_]+$"`
+			B string `validate:"regexp=^[a-z_]+$"`
+			C int    `validate:"regexp=^1$"`
+		}{A: "a_b", B: "A", C: 1}, "B:regexp,C:regexp"},
+		{"regexp with comma", struct {
+			A string `validate:"min=1,regexp=^a{1,3}$"`
+			B string `validate:"min=1,regexp=^a{1,3}$"`
+			C string `validate:"regexp=^[a,b]+$"`
+		}{A: "aaa", B: "aaaa", C: "a,b"}, "B:regexp"},
+		{"illegal regexp", struct {
+			A string `validate:"regexp=^(a$"`
+		}{A: "a"}, "A:regexp"},
+		{"email", struct {
+			A string `validate:"email"`
+			B string `validate:"email"`
+			C string `validate:"email"`
+		}{A: "a@b.c", B: "Name <a@b.c>", C: "a.b.c"}, "B:email,C:email"},
+		{"dir file", struct {
+			A string `validate:"dir"`
+			B string `validate:"dir"`
+			C string `validate:"file"`
+			D string `validate:"file"`
+			E string `validate:"file"`
+		}{A: dir, B: filename, C: filename, D: dir, E: filepath.Join(dir, "none")}, "B:dir,D:file,E:file"},
+		{"unknown rule and illegal param", struct {
+			A int  `validate:"unknown"`
+			B int  `validate:"min=x"`
+			C bool `validate:"min=1"`
+		}{}, "A:unknown,B:min,C:min"},
+		{"skip", struct {
+			A int `validate:"-"`
+			B int `validate:" , ,"`
+			c int `validate:"required"`
+		}{}, ""},
+	}
+	for _, c := range cases {
+		if got := errorsString(t, Validate(c.data)); got != c.want {
+			t.Errorf("%v: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFieldError(t *testing.T) {
+	err := Validate(&struct {
+		Port int    `validate:"required"`
+		Name string `validate:"max=1"`
+	}{Name: "abc"})
+	want := "validate fail (2 fields): Port: required: is required; Name: max=1: must be at most 1, actual=len(abc)=3"
+	if err == nil || err.Error() != want {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+type validDb struct {
+	Host string `validate:"required"`
+	Port int    `validate:"min=1,max=65535"`
+}
+
+type validItem struct {
+	Name string `validate:"required"`
+}
+
+type validBase struct {
+	Id int64 `validate:"min=1"`
+}
+
+type validExtra struct {
+	Memo string `validate:"max=2"`
+}
+
+type validConfig struct {
+	validBase
+	*validExtra
+	Db     validDb
+	DbPtr  *validDb
+	Items  []validItem
+	Ptrs   []*validItem
+	Labels map[string]*validItem
+	Any    interface{}
+	hidden validDb //未导出的非匿名字段不检查
+}
+
+func TestNested(t *testing.T) {
+	cfg := &validConfig{
+		validBase:  validBase{Id: 0},
+		validExtra: &validExtra{Memo: "abc"},
+		Db:         validDb{Host: "localhost", Port: 0},
+		DbPtr:      &validDb{Port: 80},
+		Items:      []validItem{{"a"}, {}},
+		Ptrs:       []*validItem{nil, {}},
+		Labels:     map[string]*validItem{"k1": {}},
+		Any:        &validDb{Host: "h", Port: 70000},
+	}
+	want := "Id:min,Memo:max,Db.Port:min,DbPtr.Host:required,Items[1].Name:required,Ptrs[1].Name:required,Labels[k1].Name:required,Any.Port:max"
+	if got := errorsString(t, Validate(cfg)); got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+
+	//值和指针都可以, nil的匿名结构体指针跳过
+	valid := validConfig{validBase: validBase{Id: 1}, Db: validDb{Host: "h", Port: 1}}
+	if err := Validate(valid); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(&valid); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type validNode struct {
+	Name     string `validate:"required"`
+	Next     *validNode
+	Children map[string]*validNode
+}
+
+func TestCycle(t *testing.T) {
+	node := &validNode{}
+	node.Next = node
+	node.Children = map[string]*validNode{"self": node}
+	if got := errorsString(t, Validate(node)); got != "Name:required" {
+		t.Fatalf("got %q", got)
+	}
+
+	other := &validNode{Name: "other"}
+	node = &validNode{Name: "root", Next: other, Children: map[string]*validNode{"a": other, "b": {}}}
+	other.Next = node
+	if got := errorsString(t, Validate(node)); got != "Children[b].Name:required" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestValidator(t *testing.T) {
+	validator := New()
+	validator.TagName = "check"
+	validator.Register("even", func(value reflect.Value, param string) error {
+		if value.Int()%2 != 0 {
+			return errors.New("must be even")
+		}
+		return nil
+	})
+	data := struct {
+		A int `check:"even" validate:"min=10"`
+		B int `check:"even"`
+		C int `validate:"even"`
+	}{A: 2, B: 3}
+	if got := errorsString(t, validator.Validate(data)); got != "B:even" {
+		t.Fatalf("got %q", got)
+	}
+	//默认的Validator没有注册even
+	if got := errorsString(t, Validate(data)); got != "A:min,C:even" {
+		t.Fatalf("got %q", got)
+	}
+
+	var nilData *validNode
+	for _, data := range []interface{}{nil, nilData, 1, []validNode{}} {
+		if err := Validate(data); err == nil {
+			t.Errorf("data=%#v is not rejected", data)
+		} else if errors.As(err, new(*ValidationError)) {
+			t.Errorf("data=%#v, err=%v", data, err)
+		}
+	}
+}
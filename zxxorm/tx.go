@@ -0,0 +1,116 @@
+package zxxorm
+
+/*
+使用例子:
+	err := zxxorm.WithSession(ctx, engine, nil, func(session *zxxorm.Session) error {
+		if _, err := session.Insert(&user); err != nil {
+			return err
+		}
+		//嵌套事务(SAVEPOINT): 内层失败只回滚内层.
+		return zxxorm.WithSession(session.TxContext(), engine, nil, func(session *zxxorm.Session) error { ... })
+	})
+*/
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/go-xorm/xorm"
+	"github.com/zx9229/zxgo/zxsql"
+)
+
+type Session struct {
+	*xorm.Session
+	engine *xorm.Engine
+	ctx    context.Context
+	depth  int //0是最外层事务, 大于0时是SAVEPOINT.
+}
+
+type sessionContextKey struct{}
+
+// 返回携带了本事务的ctx, 用它调用WithSession会创建嵌套事务.
+// (不叫Context, 以免覆盖xorm.Session的Context方法)
+func (self *Session) TxContext() context.Context {
+	return self.ctx
+}
+
+func (self *Session) Depth() int {
+	return self.depth
+}
+
+func SessionFromContext(ctx context.Context) *Session {
+	if session, ok := ctx.Value(sessionContextKey{}).(*Session); ok {
+		return session
+	}
+	return nil
+}
+
+// 在xorm的事务中执行fn: fn返回nil则提交, 返回错误或panic则回滚.
+// 重试策略和zxsql.WithTx相同(opts为nil时使用zxsql.DefaultTxOptions), 只使用opts里面和重试有关的字段.
+// 如果ctx里已经有事务(见Session.TxContext), 就用SAVEPOINT执行嵌套事务, 此时不重试(由最外层决定是否重试), engine必须和外层相同.
+func WithSession(ctx context.Context, engine *xorm.Engine, opts *zxsql.TxOptions, fn func(session *Session) error) error {
+	if parent := SessionFromContext(ctx); parent != nil {
+		if parent.engine != engine {
+			return errors.New("nested WithSession must use the same engine")
+		}
+		return withSavepoint(parent, fn)
+	}
+
+	return zxsql.Retry(ctx, opts, func() error {
+		return withSessionOnce(ctx, engine, fn)
+	})
+}
+
+func withSessionOnce(ctx context.Context, engine *xorm.Engine, fn func(session *Session) error) (err error) {
+	xormSession := engine.NewSession()
+	defer xormSession.Close()
+
+	if err = xormSession.Begin(); err != nil {
+		return
+	}
+
+	session := &Session{Session: xormSession, engine: engine, depth: 0}
+	session.ctx = context.WithValue(ctx, sessionContextKey{}, session)
+
+	if err = callSessionFunc(session, fn); err != nil {
+		if err2 := xormSession.Rollback(); err2 != nil {
+			err = fmt.Errorf("%w (rollback fail: %v)", err, err2)
+		}
+		return
+	}
+
+	err = xormSession.Commit()
+	return
+}
+
+func withSavepoint(parent *Session, fn func(session *Session) error) (err error) {
+	name := "zx_sp_" + strconv.Itoa(parent.depth+1)
+	if _, err = parent.Exec("SAVEPOINT " + name); err != nil {
+		return
+	}
+
+	session := &Session{Session: parent.Session, engine: parent.engine, depth: parent.depth + 1}
+	session.ctx = context.WithValue(parent.ctx, sessionContextKey{}, session)
+
+	if err = callSessionFunc(session, fn); err != nil {
+		if _, err2 := parent.Exec("ROLLBACK TO SAVEPOINT " + name); err2 != nil {
+			err = fmt.Errorf("%w (rollback to savepoint fail: %v)", err, err2)
+		}
+		return
+	}
+
+	_, err = parent.Exec("RELEASE SAVEPOINT " + name)
+	return
+}
+
+func callSessionFunc(session *Session, fn func(session *Session) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &zxsql.PanicError{Value: r, Stack: string(debug.Stack())}
+		}
+	}()
+	err = fn(session)
+	return
+}
@@ -0,0 +1,109 @@
+//go:build cgo
+
+package zxxorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-xorm/xorm"
+	"github.com/zx9229/zxgo/zxsql"
+)
+
+func hasUser(t *testing.T, engine *xorm.Engine, id int64) bool {
+	has, err := engine.Where("id = ?", id).Get(&tbUser{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return has
+}
+
+func TestWithSession(t *testing.T) {
+	engine := openEngine(t, new(tbUser))
+	ctx := context.Background()
+
+	err := WithSession(ctx, engine, nil, func(session *Session) error {
+		if session.Depth() != 0 || SessionFromContext(session.TxContext()) != session {
+			return errors.New("illegal outer session")
+		}
+		if _, err := session.Insert(&tbUser{Id: 1, Name: "outer"}); err != nil {
+			return err
+		}
+		//内层失败: 只回滚内层
+		innerErr := WithSession(session.TxContext(), engine, nil, func(inner *Session) error {
+			if inner.Depth() != 1 {
+				return errors.New("illegal inner depth")
+			}
+			if _, err := inner.Insert(&tbUser{Id: 2, Name: "inner"}); err != nil {
+				return err
+			}
+			return errors.New("inner fail")
+		})
+		if innerErr == nil || innerErr.Error() != "inner fail" {
+			return fmt.Errorf("innerErr=%v", innerErr)
+		}
+		return WithSession(session.TxContext(), engine, nil, func(inner *Session) error {
+			_, err := inner.Insert(&tbUser{Id: 3, Name: "inner"})
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasUser(t, engine, 1) || hasUser(t, engine, 2) || !hasUser(t, engine, 3) {
+		t.Fatal("inner rollback is wrong")
+	}
+
+	//外层失败: 内层已经RELEASE的修改也一起回滚
+	err = WithSession(ctx, engine, nil, func(session *Session) error {
+		if err := WithSession(session.TxContext(), engine, nil, func(inner *Session) error {
+			_, err := inner.Insert(&tbUser{Id: 4, Name: "inner"})
+			return err
+		}); err != nil {
+			return err
+		}
+		return errors.New("outer fail")
+	})
+	if err == nil || hasUser(t, engine, 4) {
+		t.Fatalf("outer rollback, err=%v", err)
+	}
+
+	err = WithSession(ctx, engine, nil, func(session *Session) error {
+		if _, err := session.Insert(&tbUser{Id: 5, Name: "panic"}); err != nil {
+			return err
+		}
+		panic("boom")
+	})
+	var panicErr *zxsql.PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" || hasUser(t, engine, 5) {
+		t.Fatalf("panic, err=%v", err)
+	}
+
+	other := openEngine(t, new(tbUser))
+	err = WithSession(ctx, engine, nil, func(session *Session) error {
+		return WithSession(session.TxContext(), other, nil, func(inner *Session) error { return nil })
+	})
+	if err == nil {
+		t.Fatal("nested session with another engine is not rejected")
+	}
+}
+
+func TestWithSessionRetry(t *testing.T) {
+	engine := openEngine(t, new(tbUser))
+	attempts := 0
+	err := WithSession(context.Background(), engine, &zxsql.TxOptions{MaxRetries: 3}, func(session *Session) error {
+		attempts++
+		if _, err := session.Insert(&tbUser{Id: 1, Name: "retry"}); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return errors.New("database is locked") //回滚之后重试
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 || !hasUser(t, engine, 1) {
+		t.Fatalf("attempts=%v, err=%v", attempts, err)
+	}
+}
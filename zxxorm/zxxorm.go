@@ -296,3 +296,69 @@ func GuessColName(engine *xorm.Engine, bean interface{}, offset uintptr, panicWh
 	}
 	return colName
 }
+
+// 通过字段的指针找到列名, 支持匿名(extends)的结构体. 例如 ColName(engine, data, &data.CreateTime).
+func ColName(engine *xorm.Engine, bean interface{}, fieldPtr interface{}) (colName string, err error) {
+	var info *zxgo.FieldInfo
+	if info, err = zxgo.ResolveField(bean, fieldPtr); err != nil {
+		return
+	}
+	var tbInfo *xorm.Table = engine.TableInfo(bean)
+	if tbInfo == nil {
+		err = errors.New(fmt.Sprintf("No table info, type=%T", bean))
+		return
+	}
+	for _, col := range tbInfo.Columns() { //extends的字段, xorm的FieldName是"Base.CreateTime"这种形式.
+		if col.FieldName == info.Path {
+			colName = col.Name
+			return
+		}
+	}
+	for _, col := range tbInfo.Columns() {
+		if col.FieldName == info.Field.Name {
+			colName = col.Name
+			return
+		}
+	}
+	err = errors.New(fmt.Sprintf("No columns found to match, field=%v", info.Path))
+	return
+}
+
+// 比较oldBean和newBean, 按oldBean的主键只更新变化了的列(包括变成零值的列). 没有变化时不执行SQL.
+// 不允许修改主键(返回错误), 否则WHERE和SET里的主键对不上, 可能改到别的记录.
+func EngineUpdateChanged(engine *xorm.Engine, oldBean interface{}, newBean interface{}) (affected int64, err error) {
+	var changes []*zxgo.Change
+	if changes, err = zxgo.Diff(oldBean, newBean); err != nil {
+		return
+	}
+	var tbInfo *xorm.Table = engine.TableInfo(newBean)
+	if tbInfo == nil {
+		err = errors.New(fmt.Sprintf("No table info, type=%T", newBean))
+		return
+	}
+	cols := make([]string, 0)
+	for _, col := range tbInfo.Columns() {
+		for _, change := range changes {
+			path := strings.Join(change.Path, ".")
+			if path == col.FieldName || strings.HasPrefix(path, col.FieldName+".") {
+				if col.IsPrimaryKey {
+					err = errors.New(fmt.Sprintf("primary key can not be changed, column=%v", col.Name))
+					return
+				}
+				cols = append(cols, col.Name)
+				break
+			}
+		}
+	}
+	if len(cols) == 0 {
+		return
+	}
+
+	var query string
+	var args []interface{}
+	if query, args, err = calcPkQueryStatement(engine, oldBean); err != nil {
+		return
+	}
+	affected, err = engine.Cols(cols...).MustCols(cols...).Where(query, args...).Update(newBean)
+	return
+}
@@ -0,0 +1,69 @@
+//go:build cgo
+
+package zxxorm
+
+import (
+	"testing"
+
+	"github.com/go-xorm/xorm"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type tbUser struct {
+	Id   int64 `xorm:"pk"`
+	Name string
+	Age  int
+}
+
+func openEngine(t *testing.T, beans ...interface{}) *xorm.Engine {
+	engine, err := xorm.NewEngine("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetMaxOpenConns(1) //每个连接都是一个独立的内存数据库
+	t.Cleanup(func() { engine.Close() })
+	if err = engine.Sync2(beans...); err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+func getUser(t *testing.T, engine *xorm.Engine, id int64) *tbUser {
+	user := &tbUser{}
+	if has, err := engine.Where("id = ?", id).Get(user); err != nil || !has {
+		t.Fatalf("id=%v, has=%v, err=%v", id, has, err)
+	}
+	return user
+}
+
+func TestEngineUpdateChanged(t *testing.T) {
+	engine := openEngine(t, new(tbUser))
+	if _, err := engine.Insert(&tbUser{Id: 1, Name: "a", Age: 10}, &tbUser{Id: 2, Name: "b", Age: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	oldUser := &tbUser{Id: 1, Name: "a", Age: 10}
+	newUser := &tbUser{Id: 1, Name: "a", Age: 0} //变成零值的列也要更新
+	if affected, err := EngineUpdateChanged(engine, oldUser, newUser); err != nil || affected != 1 {
+		t.Fatalf("affected=%v, err=%v", affected, err)
+	}
+	if user := getUser(t, engine, 1); *user != *newUser {
+		t.Fatalf("user=%+v", user)
+	}
+
+	if affected, err := EngineUpdateChanged(engine, newUser, newUser); err != nil || affected != 0 {
+		t.Fatalf("no change, affected=%v, err=%v", affected, err)
+	}
+
+	//修改主键: 不能按新主键改到id=2的记录上
+	keyChanged := &tbUser{Id: 2, Name: "c", Age: 0}
+	if affected, err := EngineUpdateChanged(engine, newUser, keyChanged); err == nil || affected != 0 {
+		t.Fatalf("primary key changed, affected=%v, err=%v", affected, err)
+	}
+	if user := getUser(t, engine, 1); *user != *newUser {
+		t.Fatalf("user=%+v", user)
+	}
+	if user := getUser(t, engine, 2); user.Name != "b" || user.Age != 20 {
+		t.Fatalf("user=%+v", user)
+	}
+}